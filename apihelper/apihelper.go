@@ -1,187 +1,356 @@
 package apihelper
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
-	"strconv"
 
 	"github.com/cloudfoundry/cli/plugin"
-	"github.com/krujos/cfcurl"
 )
 
 var (
 	ErrOrgNotFound = errors.New("organization not found")
+	ErrNoQuota     = errors.New("organization has no quota definition")
+	ErrNoDroplet   = errors.New("app has no current droplet")
 )
 
 // Organization representation
 type Organization struct {
+	GUID      string
 	URL       string
 	Name      string
+	QuotaGUID string
 	QuotaURL  string
 	SpacesURL string
 }
 
-// Space representation
+// Space representation. QuotaGUID is empty if no space quota applies.
 type Space struct {
-	Name    string
-	AppsURL string
+	GUID      string
+	Name      string
+	AppsURL   string
+	QuotaGUID string
 }
 
-// App representation
+// App representation. Instances, RAM and Disk are the ones of the web
+// process.
 type App struct {
 	Instances          float64
 	RAM                float64
+	Disk               float64 // MB per instance
 	Running            bool
 	Name               string
 	ServiceBindingsURL string
 	GUID               string
+	SpaceGUID          string
+
+	// Processes are all process types of the app, web first. v2 apps have
+	// the web process only.
+	Processes []Process
+}
+
+// Quota representation of an organization or space quota definition
+type Quota struct {
+	GUID        string
+	Name        string
+	MemoryLimit float64 // -1 means unlimited
+
+	// the other limits of the quota, -1 means unlimited as well
+	InstanceMemoryLimit     float64
+	AppInstanceLimit        float64
+	TotalServices           float64
+	TotalServiceKeys        float64
+	TotalRoutes             float64
+	TotalReservedRoutePorts float64
 }
 
-// CFAPIHelper to wrap cf curl results
+// CFAPIHelper to wrap cf curl results. Requests are aborted when the
+// context is done.
 type CFAPIHelper interface {
-	GetOrgs() ([]Organization, error)
-	GetOrg(string) (Organization, error)
-	GetQuotaMemoryLimit(string) (float64, error)
-	GetOrgMemoryUsage(Organization) (float64, error)
-	GetOrgSpaces(string) ([]Space, error)
-	GetSpaceApps(string) ([]App, error)
-	GetServiceBindings(string) ([]ServiceBindings, error)
-	GetServiceInstanceMap() (map[string]ServiceInstance, error)
-	GetServiceMap() (map[string]Service, error)
-	GetServicePlanMap() (map[string]ServicePlan, error)
-	GetUserProvidedServiceMap() (map[string]UserProvidedService, error)
-	GetServiceBindingsList() ([]ServiceBinding, error)
-	GetSpaceMap() (map[string]SpaceDetails, error)
-	GetOrgMap() (map[string]OrgDetails, error)
+	GetOrgs(context.Context) ([]Organization, error)
+	GetOrg(context.Context, string) (Organization, error)
+	GetQuotaMemoryLimit(context.Context, string) (float64, error)
+	GetOrgMemoryUsage(context.Context, Organization) (float64, error)
+	GetOrgSpaces(context.Context, string) ([]Space, error)
+	GetSpaceApps(context.Context, string) ([]App, error)
+	GetApps(context.Context) ([]App, error)
+	GetQuotaMap(context.Context) (map[string]Quota, error)
+	GetSpaceQuotaMap(context.Context) (map[string]Quota, error)
+	GetServiceBindings(context.Context, string) ([]ServiceBindings, error)
+	GetServiceInstanceMap(context.Context) (map[string]ServiceInstance, error)
+	GetServiceMap(context.Context) (map[string]Service, error)
+	GetServicePlanMap(context.Context) (map[string]ServicePlan, error)
+	GetUserProvidedServiceMap(context.Context) (map[string]UserProvidedService, error)
+	GetServiceBindingsList(context.Context) ([]ServiceBinding, error)
+	GetSpaces(context.Context) ([]SpaceDetails, error)
+	GetSpaceMap(context.Context) (map[string]SpaceDetails, error)
+	GetOrgMap(context.Context) (map[string]OrgDetails, error)
+	GetLabels(context.Context) (Labels, error)
+	GetRoutes(context.Context) ([]Route, error)
+	GetServiceKeys(context.Context) ([]ServiceKey, error)
+	GetAppStats(context.Context, string, string) ([]InstanceStats, error)
+	GetAppSidecars(context.Context, string) ([]Sidecar, error)
+	GetRunningTasks(context.Context) ([]Task, error)
+	GetBuilds(context.Context) ([]Build, error)
+	GetAppBuild(context.Context, string) (Build, error)
 }
 
 // APIHelper implementation
 type APIHelper struct {
-	cli plugin.CliConnection
+	transport      Transport
+	resultsPerPage int
+	scope          Scope
 }
 
+// New returns a CFAPIHelper using the v2 API through cf curl.
 func New(cli plugin.CliConnection) CFAPIHelper {
-	return &APIHelper{cli}
+	return NewWithTransport(NewCurlTransport(cli))
 }
 
-// GetOrgs returns a struct that represents critical fields in the JSON
-func (api *APIHelper) GetOrgs() ([]Organization, error) {
-	orgsJSON, err := cfcurl.Curl(api.cli, "/v2/organizations")
-	if nil != err {
-		return nil, err
+// NewWithTransport returns a CFAPIHelper using the v2 API through t.
+func NewWithTransport(t Transport) CFAPIHelper {
+	return &APIHelper{transport: t, resultsPerPage: DefaultResultsPerPage}
+}
+
+// API versions accepted by NewForAPIVersion
+const (
+	APIVersionAuto = "auto"
+	APIVersionV2   = "v2"
+	APIVersionV3   = "v3"
+)
+
+// NewForAPIVersion returns the CFAPIHelper for the given Cloud Controller API
+// version. APIVersionAuto probes the API root and prefers v2 as long as the
+// foundation still serves it.
+func NewForAPIVersion(ctx context.Context, t Transport, version string) (CFAPIHelper, error) {
+	if version == APIVersionAuto || version == "" {
+		var err error
+		if version, err = DetectAPIVersion(ctx, t); err != nil {
+			return nil, err
+		}
 	}
-	pages := int(orgsJSON["total_pages"].(float64))
+	switch version {
+	case APIVersionV2:
+		return NewWithTransport(t), nil
+	case APIVersionV3:
+		return NewV3WithTransport(t), nil
+	}
+	return nil, fmt.Errorf("unknown API version %q", version)
+}
+
+// GetOrgs returns a struct that represents critical fields in the JSON
+func (api *APIHelper) GetOrgs(ctx context.Context) ([]Organization, error) {
 	orgs := []Organization{}
-	for i := 1; i <= pages; i++ {
-		if 1 != i {
-			orgsJSON, err = cfcurl.Curl(api.cli, "/v2/organizations?page="+strconv.Itoa(i))
-		}
-		for _, o := range orgsJSON["resources"].([]interface{}) {
-			theOrg := o.(map[string]interface{})
-			entity := theOrg["entity"].(map[string]interface{})
-			metadata := theOrg["metadata"].(map[string]interface{})
-			orgs = append(orgs,
-				Organization{
-					Name:      entity["name"].(string),
-					URL:       metadata["url"].(string),
-					QuotaURL:  entity["quota_definition_url"].(string),
-					SpacesURL: entity["spaces_url"].(string),
-				})
+	err := api.eachOrg(ctx, func(r v2Resource) error {
+		org, err := orgResourceToOrg(r)
+		if nil != err {
+			return err
 		}
+		orgs = append(orgs, org)
+		return nil
+	})
+	if nil != err {
+		return nil, err
 	}
 	return orgs, nil
 }
 
 // GetOrg returns a struct that represents critical fields in the JSON
-func (api *APIHelper) GetOrg(name string) (Organization, error) {
+func (api *APIHelper) GetOrg(ctx context.Context, name string) (Organization, error) {
 	query := fmt.Sprintf("name:%s", name)
 	path := fmt.Sprintf("/v2/organizations?q=%s&inline-relations-depth=1", url.QueryEscape(query))
-	orgsJSON, err := cfcurl.Curl(api.cli, path)
-	if nil != err {
+
+	var page v2Page
+	if err := getJSON(ctx, api.transport, path, "organization list", &page); nil != err {
 		return Organization{}, err
 	}
 
-	results := int(orgsJSON["total_results"].(float64))
-	if results == 0 {
+	if page.TotalResults == 0 || len(page.Resources) == 0 {
 		return Organization{}, ErrOrgNotFound
 	}
 
-	orgResource := orgsJSON["resources"].([]interface{})[0]
-	org := api.orgResourceToOrg(orgResource)
-
-	return org, nil
+	return orgResourceToOrg(page.Resources[0])
 }
 
-func (api *APIHelper) orgResourceToOrg(o interface{}) Organization {
-	theOrg := o.(map[string]interface{})
-	entity := theOrg["entity"].(map[string]interface{})
-	metadata := theOrg["metadata"].(map[string]interface{})
-	return Organization{
-		Name:      entity["name"].(string),
-		URL:       metadata["url"].(string),
-		QuotaURL:  entity["quota_definition_url"].(string),
-		SpacesURL: entity["spaces_url"].(string),
+func orgResourceToOrg(r v2Resource) (Organization, error) {
+	var entity v2OrgEntity
+	if err := decodeEntity("organization", r, &entity); nil != err {
+		return Organization{}, err
+	}
+	if err := requireFields("organization", r.Metadata.GUID,
+		"name", entity.Name,
+		"spaces_url", entity.SpacesURL,
+		"url", r.Metadata.URL); nil != err {
+		return Organization{}, err
 	}
+	return Organization{
+		GUID:      r.Metadata.GUID,
+		Name:      entity.Name,
+		URL:       r.Metadata.URL,
+		QuotaGUID: entity.QuotaDefinitionGUID,
+		QuotaURL:  entity.QuotaDefinitionURL,
+		SpacesURL: entity.SpacesURL,
+	}, nil
 }
 
 // GetQuotaMemoryLimit retruns the amount of memory (in MB) that the org is allowed
-func (api *APIHelper) GetQuotaMemoryLimit(quotaURL string) (float64, error) {
-	quotaJSON, err := cfcurl.Curl(api.cli, quotaURL)
-	if nil != err {
+func (api *APIHelper) GetQuotaMemoryLimit(ctx context.Context, quotaURL string) (float64, error) {
+	if quotaURL == "" {
+		return 0, ErrNoQuota
+	}
+	var quota v2Resource
+	if err := getJSON(ctx, api.transport, quotaURL, "quota definition", &quota); nil != err {
 		return 0, err
 	}
-	return quotaJSON["entity"].(map[string]interface{})["memory_limit"].(float64), nil
+	var entity v2QuotaEntity
+	if err := decodeEntity("quota definition", quota, &entity); nil != err {
+		return 0, err
+	}
+	if err := requireFields("quota definition", quota.Metadata.GUID,
+		"memory_limit", entity.MemoryLimit); nil != err {
+		return 0, err
+	}
+	return *entity.MemoryLimit, nil
 }
 
-// GetOrgMemoryUsage returns the amount of memory (in MB) that the org is consuming
-func (api *APIHelper) GetOrgMemoryUsage(org Organization) (float64, error) {
-	usageJSON, err := cfcurl.Curl(api.cli, org.URL+"/memory_usage")
+// GetQuotaMap returns all organization quota definitions by GUID.
+func (api *APIHelper) GetQuotaMap(ctx context.Context) (map[string]Quota, error) {
+	qmap := make(map[string]Quota, 8)
+
+	err := api.eachQuota(ctx, func(r v2Resource) error {
+		var entity v2QuotaEntity
+		if err := decodeEntity("quota definition", r, &entity); nil != err {
+			return err
+		}
+		if err := requireFields("quota definition", r.Metadata.GUID,
+			"guid", r.Metadata.GUID,
+			"memory_limit", entity.MemoryLimit); nil != err {
+			return err
+		}
+		qmap[r.Metadata.GUID] = entity.quota(r.Metadata.GUID)
+		return nil
+	})
 	if nil != err {
-		return 0, err
+		return nil, err
 	}
-	return usageJSON["memory_usage_in_mb"].(float64), nil
+	return qmap, nil
 }
 
-// GetOrgSpaces returns the spaces in an org.
-func (api *APIHelper) GetOrgSpaces(spacesURL string) ([]Space, error) {
-	spacesJSON, err := cfcurl.Curl(api.cli, spacesURL)
+// GetSpaceQuotaMap returns all space quota definitions by GUID.
+func (api *APIHelper) GetSpaceQuotaMap(ctx context.Context) (map[string]Quota, error) {
+	qmap := make(map[string]Quota, 8)
+
+	path := "/v2/space_quota_definitions"
+	if api.scope.OrgGUID != "" {
+		path = "/v2/organizations/" + api.scope.OrgGUID + "/space_quota_definitions"
+	}
+	err := api.getAllPages(ctx, path, "space quota definition", func(r v2Resource) error {
+		var entity v2QuotaEntity
+		if err := decodeEntity("space quota definition", r, &entity); nil != err {
+			return err
+		}
+		if err := requireFields("space quota definition", r.Metadata.GUID,
+			"guid", r.Metadata.GUID,
+			"memory_limit", entity.MemoryLimit); nil != err {
+			return err
+		}
+		qmap[r.Metadata.GUID] = entity.quota(r.Metadata.GUID)
+		return nil
+	})
 	if nil != err {
 		return nil, err
 	}
+	return qmap, nil
+}
+
+// GetOrgMemoryUsage returns the amount of memory (in MB) that the org is consuming
+func (api *APIHelper) GetOrgMemoryUsage(ctx context.Context, org Organization) (float64, error) {
+	var usage v2MemoryUsage
+	if err := getJSON(ctx, api.transport, org.URL+"/memory_usage", "organization memory usage", &usage); nil != err {
+		return 0, err
+	}
+	if usage.MemoryUsageInMB == nil {
+		return 0, &DecodeError{Resource: "organization memory usage", GUID: org.URL,
+			Field: "memory_usage_in_mb", Err: errFieldNull}
+	}
+	return *usage.MemoryUsageInMB, nil
+}
+
+// GetOrgSpaces returns the spaces in an org.
+func (api *APIHelper) GetOrgSpaces(ctx context.Context, spacesURL string) ([]Space, error) {
 	spaces := []Space{}
-	for _, s := range spacesJSON["resources"].([]interface{}) {
-		theSpace := s.(map[string]interface{})
-		entity := theSpace["entity"].(map[string]interface{})
+	err := api.getAllPages(ctx, spacesURL, "space", func(r v2Resource) error {
+		var entity v2SpaceEntity
+		if err := decodeEntity("space", r, &entity); nil != err {
+			return err
+		}
+		if err := requireFields("space", r.Metadata.GUID,
+			"name", entity.Name,
+			"apps_url", entity.AppsURL); nil != err {
+			return err
+		}
 		spaces = append(spaces,
 			Space{
-				AppsURL: entity["apps_url"].(string),
-				Name:    entity["name"].(string),
+				GUID:      r.Metadata.GUID,
+				AppsURL:   entity.AppsURL,
+				Name:      entity.Name,
+				QuotaGUID: entity.SpaceQuotaDefinitionGUID,
 			})
+		return nil
+	})
+	if nil != err {
+		return nil, err
 	}
 	return spaces, nil
 }
 
 // GetSpaceApps returns the apps in a space
-func (api *APIHelper) GetSpaceApps(appsURL string) ([]App, error) {
-	appsJSON, err := cfcurl.Curl(api.cli, appsURL)
-	if nil != err {
-		return nil, err
-	}
+func (api *APIHelper) GetSpaceApps(ctx context.Context, appsURL string) ([]App, error) {
+	return api.getApps(ctx, appsURL)
+}
+
+// GetApps returns all apps of the foundation with the GUID of their space.
+func (api *APIHelper) GetApps(ctx context.Context) ([]App, error) {
+	return api.getApps(ctx, api.scope.v2List("/v2/apps"))
+}
+
+func (api *APIHelper) getApps(ctx context.Context, path string) ([]App, error) {
 	apps := []App{}
-	for _, a := range appsJSON["resources"].([]interface{}) {
-		theApp := a.(map[string]interface{})
-		meta := theApp["metadata"].(map[string]interface{})
-		entity := theApp["entity"].(map[string]interface{})
+	err := api.getAllPages(ctx, path, "app", func(r v2Resource) error {
+		var entity v2AppEntity
+		if err := decodeEntity("app", r, &entity); nil != err {
+			return err
+		}
+		if err := requireFields("app", r.Metadata.GUID,
+			"guid", r.Metadata.GUID,
+			"name", entity.Name,
+			"instances", entity.Instances,
+			"memory", entity.Memory); nil != err {
+			return err
+		}
+		web := Process{
+			Type:      webProcess,
+			Instances: *entity.Instances,
+			RAM:       *entity.Memory,
+			Disk:      valueOf(entity.DiskQuota),
+		}
 		apps = append(apps,
 			App{
-				Instances:          entity["instances"].(float64),
-				RAM:                entity["memory"].(float64),
-				Running:            "STARTED" == entity["state"].(string),
-				ServiceBindingsURL: entity["service_bindings_url"].(string),
-				Name:               entity["name"].(string),
-				GUID:               meta["guid"].(string),
+				Instances:          web.Instances,
+				RAM:                web.RAM,
+				Disk:               web.Disk,
+				Running:            "STARTED" == entity.State,
+				ServiceBindingsURL: entity.ServiceBindingsURL,
+				Name:               entity.Name,
+				GUID:               r.Metadata.GUID,
+				SpaceGUID:          entity.SpaceGUID,
+				Processes:          []Process{web},
 			})
+		return nil
+	})
+	if nil != err {
+		return nil, err
 	}
 	return apps, nil
 }
@@ -190,20 +359,25 @@ type ServiceBindings struct {
 	ServiceInstanceGUID string
 }
 
-func (api *APIHelper) GetServiceBindings(serviceBindingsURL string) ([]ServiceBindings, error) {
-	appsJSON, err := cfcurl.Curl(api.cli, serviceBindingsURL)
-	if nil != err {
-		return nil, err
-	}
+func (api *APIHelper) GetServiceBindings(ctx context.Context, serviceBindingsURL string) ([]ServiceBindings, error) {
 	sbs := []ServiceBindings{}
-
-	for _, a := range appsJSON["resources"].([]interface{}) {
-		theSvc := a.(map[string]interface{})
-		entity := theSvc["entity"].(map[string]interface{})
+	err := api.getAllPages(ctx, serviceBindingsURL, "service binding", func(r v2Resource) error {
+		var entity v2ServiceBindingEntity
+		if err := decodeEntity("service binding", r, &entity); nil != err {
+			return err
+		}
+		if err := requireFields("service binding", r.Metadata.GUID,
+			"service_instance_guid", entity.ServiceInstanceGUID); nil != err {
+			return err
+		}
 		sbs = append(sbs,
 			ServiceBindings{
-				ServiceInstanceGUID: entity["service_instance_guid"].(string),
+				ServiceInstanceGUID: entity.ServiceInstanceGUID,
 			})
+		return nil
+	})
+	if nil != err {
+		return nil, err
 	}
 	return sbs, nil
 }
@@ -213,27 +387,32 @@ type ServiceBinding struct {
 	ServiceInstanceGUID string
 }
 
-// GetServiceBindingsMap returns a list of service bindings (app guid to service instance guid)
-func (api *APIHelper) GetServiceBindingsList() ([]ServiceBinding, error) {
-	sbJSON, err := cfcurl.Curl(api.cli, "/v2/service_bindings")
+// GetServiceBindingsList returns a list of service bindings (app guid to service instance guid)
+func (api *APIHelper) GetServiceBindingsList(ctx context.Context) ([]ServiceBinding, error) {
+	paths, err := api.serviceBindingsPaths(ctx)
 	if nil != err {
 		return nil, err
 	}
-
 	silist := make([]ServiceBinding, 0, 64)
-	pages := int(sbJSON["total_pages"].(float64))
-	for i := 1; i <= pages; i++ {
-		if 1 != i {
-			sbJSON, err = cfcurl.Curl(api.cli, "/v2/service_bindings?page="+strconv.Itoa(i))
-		}
-		for _, a := range sbJSON["resources"].([]interface{}) {
-			theSvc := a.(map[string]interface{})
-			entity := theSvc["entity"].(map[string]interface{})
-
+	for _, path := range paths {
+		err := api.getAllPages(ctx, path, "service binding", func(r v2Resource) error {
+			var entity v2ServiceBindingEntity
+			if err := decodeEntity("service binding", r, &entity); nil != err {
+				return err
+			}
+			if err := requireFields("service binding", r.Metadata.GUID,
+				"app_guid", entity.AppGUID,
+				"service_instance_guid", entity.ServiceInstanceGUID); nil != err {
+				return err
+			}
 			silist = append(silist, ServiceBinding{
-				AppGUID:             entity["app_guid"].(string),
-				ServiceInstanceGUID: entity["service_instance_guid"].(string),
+				AppGUID:             entity.AppGUID,
+				ServiceInstanceGUID: entity.ServiceInstanceGUID,
 			})
+			return nil
+		})
+		if nil != err {
+			return nil, err
 		}
 	}
 	return silist, nil
@@ -250,33 +429,32 @@ type ServiceInstance struct {
 }
 
 // GetServiceInstanceMap returns a map from Service Instance GUID to a Service Instance.
-func (api *APIHelper) GetServiceInstanceMap() (map[string]ServiceInstance, error) {
-	siJSON, err := cfcurl.Curl(api.cli, "/v2/service_instances")
-	if nil != err {
-		return nil, err
-	}
-
+// The ServicePlanGUID is empty for instances whose plan was removed together
+// with its broker.
+func (api *APIHelper) GetServiceInstanceMap(ctx context.Context) (map[string]ServiceInstance, error) {
 	simap := make(map[string]ServiceInstance, 32)
 
-	pages := int(siJSON["total_pages"].(float64))
-	for i := 1; i <= pages; i++ {
-		if 1 != i {
-			siJSON, err = cfcurl.Curl(api.cli, "/v2/service_instances?page="+strconv.Itoa(i))
+	err := api.getAllPages(ctx, api.scope.v2List("/v2/service_instances"), "service instance", func(r v2Resource) error {
+		var entity v2ServiceInstanceEntity
+		if err := decodeEntity("service instance", r, &entity); nil != err {
+			return err
 		}
-		for _, a := range siJSON["resources"].([]interface{}) {
-			theSvc := a.(map[string]interface{})
-
-			meta := theSvc["metadata"].(map[string]interface{})
-			entity := theSvc["entity"].(map[string]interface{})
-
-			simap[meta["guid"].(string)] = ServiceInstance{
-				GUID:            meta["guid"].(string),
-				Name:            entity["name"].(string),
-				Type:            entity["type"].(string),
-				ServicePlanGUID: entity["service_plan_guid"].(string),
-				SpaceGUID:       entity["space_guid"].(string),
-			}
+		if err := requireFields("service instance", r.Metadata.GUID,
+			"guid", r.Metadata.GUID,
+			"name", entity.Name); nil != err {
+			return err
+		}
+		simap[r.Metadata.GUID] = ServiceInstance{
+			GUID:            r.Metadata.GUID,
+			Name:            entity.Name,
+			Type:            entity.Type,
+			ServicePlanGUID: entity.ServicePlanGUID,
+			SpaceGUID:       entity.SpaceGUID,
 		}
+		return nil
+	})
+	if nil != err {
+		return nil, err
 	}
 	return simap, nil
 }
@@ -288,31 +466,27 @@ type ServicePlan struct {
 }
 
 // GetServicePlanMap maps a ServicePlan GUID to a Service GUID.
-func (api *APIHelper) GetServicePlanMap() (map[string]ServicePlan, error) {
-	spJSON, err := cfcurl.Curl(api.cli, "/v2/service_plans")
-	if nil != err {
-		return nil, err
-	}
-
+func (api *APIHelper) GetServicePlanMap(ctx context.Context) (map[string]ServicePlan, error) {
 	spMap := make(map[string]ServicePlan, 32)
 
-	pages := int(spJSON["total_pages"].(float64))
-	for i := 1; i <= pages; i++ {
-		if 1 != i {
-			spJSON, err = cfcurl.Curl(api.cli, "/v2/service_plans?page="+strconv.Itoa(i))
+	err := api.getAllPages(ctx, "/v2/service_plans", "service plan", func(r v2Resource) error {
+		var entity v2ServicePlanEntity
+		if err := decodeEntity("service plan", r, &entity); nil != err {
+			return err
 		}
-		for _, a := range spJSON["resources"].([]interface{}) {
-			theSvc := a.(map[string]interface{})
-
-			meta := theSvc["metadata"].(map[string]interface{})
-			entity := theSvc["entity"].(map[string]interface{})
-
-			spMap[meta["guid"].(string)] = ServicePlan{
-				GUID:        meta["guid"].(string),
-				Name:        entity["name"].(string),
-				ServiceGUID: entity["service_guid"].(string),
-			}
+		if err := requireFields("service plan", r.Metadata.GUID,
+			"guid", r.Metadata.GUID); nil != err {
+			return err
 		}
+		spMap[r.Metadata.GUID] = ServicePlan{
+			GUID:        r.Metadata.GUID,
+			Name:        entity.Name,
+			ServiceGUID: entity.ServiceGUID,
+		}
+		return nil
+	})
+	if nil != err {
+		return nil, err
 	}
 	return spMap, nil
 }
@@ -323,30 +497,26 @@ type Service struct {
 }
 
 // GetServiceMap maps a Service GUID to a Service Name (label).
-func (api *APIHelper) GetServiceMap() (map[string]Service, error) {
-	siJSON, err := cfcurl.Curl(api.cli, "/v2/services")
-	if nil != err {
-		return nil, err
-	}
-
+func (api *APIHelper) GetServiceMap(ctx context.Context) (map[string]Service, error) {
 	simap := make(map[string]Service, 32)
 
-	pages := int(siJSON["total_pages"].(float64))
-	for i := 1; i <= pages; i++ {
-		if 1 != i {
-			siJSON, err = cfcurl.Curl(api.cli, "/v2/services?page="+strconv.Itoa(i))
+	err := api.getAllPages(ctx, api.servicesPath(), "service", func(r v2Resource) error {
+		var entity v2ServiceEntity
+		if err := decodeEntity("service", r, &entity); nil != err {
+			return err
 		}
-		for _, a := range siJSON["resources"].([]interface{}) {
-			theSvc := a.(map[string]interface{})
-
-			meta := theSvc["metadata"].(map[string]interface{})
-			entity := theSvc["entity"].(map[string]interface{})
-
-			simap[meta["guid"].(string)] = Service{
-				GUID:  meta["guid"].(string),
-				Label: entity["label"].(string),
-			}
+		if err := requireFields("service", r.Metadata.GUID,
+			"guid", r.Metadata.GUID); nil != err {
+			return err
+		}
+		simap[r.Metadata.GUID] = Service{
+			GUID:  r.Metadata.GUID,
+			Label: entity.Label,
 		}
+		return nil
+	})
+	if nil != err {
+		return nil, err
 	}
 	return simap, nil
 }
@@ -357,98 +527,106 @@ type UserProvidedService struct {
 	Type string
 }
 
-func (api *APIHelper) GetUserProvidedServiceMap() (map[string]UserProvidedService, error) {
-	siJSON, err := cfcurl.Curl(api.cli, "/v2/user_provided_service_instances")
-	if nil != err {
-		return nil, err
-	}
-
+func (api *APIHelper) GetUserProvidedServiceMap(ctx context.Context) (map[string]UserProvidedService, error) {
 	simap := make(map[string]UserProvidedService)
 
-	pages := int(siJSON["total_pages"].(float64))
-	for i := 1; i <= pages; i++ {
-		if 1 != i {
-			siJSON, err = cfcurl.Curl(api.cli, "/v2/user_provided_service_instances?page="+strconv.Itoa(i))
+	err := api.getAllPages(ctx, api.scope.v2List("/v2/user_provided_service_instances"), "user provided service instance", func(r v2Resource) error {
+		var entity v2UserProvidedServiceEntity
+		if err := decodeEntity("user provided service instance", r, &entity); nil != err {
+			return err
 		}
-		for _, a := range siJSON["resources"].([]interface{}) {
-			theSvc := a.(map[string]interface{})
-
-			meta := theSvc["metadata"].(map[string]interface{})
-			entity := theSvc["entity"].(map[string]interface{})
-
-			simap[meta["guid"].(string)] = UserProvidedService{
-				GUID: meta["guid"].(string),
-				Name: entity["name"].(string),
-				Type: entity["type"].(string),
-			}
+		if err := requireFields("user provided service instance", r.Metadata.GUID,
+			"guid", r.Metadata.GUID); nil != err {
+			return err
+		}
+		simap[r.Metadata.GUID] = UserProvidedService{
+			GUID: r.Metadata.GUID,
+			Name: entity.Name,
+			Type: entity.Type,
 		}
+		return nil
+	})
+	if nil != err {
+		return nil, err
 	}
 	return simap, nil
 }
 
 type SpaceDetails struct {
-	GUID    string
-	Name    string
-	OrgGUID string
+	GUID      string
+	Name      string
+	OrgGUID   string
+	QuotaGUID string // space quota, empty if none applies
 }
 
-// GetSpaceMap returns a map which has the GUID as key and the Name and GUID as value.
-func (api *APIHelper) GetSpaceMap() (map[string]SpaceDetails, error) {
-	sJSON, err := cfcurl.Curl(api.cli, "/v2/spaces")
+// GetSpaces returns all spaces of the foundation in the order of the API.
+func (api *APIHelper) GetSpaces(ctx context.Context) ([]SpaceDetails, error) {
+	spaces := make([]SpaceDetails, 0, 32)
+
+	err := api.eachSpace(ctx, func(r v2Resource) error {
+		var entity v2SpaceEntity
+		if err := decodeEntity("space", r, &entity); nil != err {
+			return err
+		}
+		if err := requireFields("space", r.Metadata.GUID,
+			"guid", r.Metadata.GUID); nil != err {
+			return err
+		}
+		spaces = append(spaces, SpaceDetails{
+			GUID:      r.Metadata.GUID,
+			Name:      entity.Name,
+			OrgGUID:   entity.OrganizationGUID,
+			QuotaGUID: entity.SpaceQuotaDefinitionGUID,
+		})
+		return nil
+	})
 	if nil != err {
 		return nil, err
 	}
+	return spaces, nil
+}
 
-	smap := make(map[string]SpaceDetails, 32)
-
-	pages := int(sJSON["total_pages"].(float64))
-	for i := 1; i <= pages; i++ {
-		if 1 != i {
-			sJSON, err = cfcurl.Curl(api.cli, "/v2/spaces?page="+strconv.Itoa(i))
-		}
-		for _, a := range sJSON["resources"].([]interface{}) {
-			theSvc := a.(map[string]interface{})
-
-			meta := theSvc["metadata"].(map[string]interface{})
-			entity := theSvc["entity"].(map[string]interface{})
+// GetSpaceMap returns a map which has the GUID as key and the Name and GUID as value.
+func (api *APIHelper) GetSpaceMap(ctx context.Context) (map[string]SpaceDetails, error) {
+	spaces, err := api.GetSpaces(ctx)
+	if nil != err {
+		return nil, err
+	}
+	return SpaceMap(spaces), nil
+}
 
-			smap[meta["guid"].(string)] = SpaceDetails{
-				GUID:    meta["guid"].(string),
-				Name:    entity["name"].(string),
-				OrgGUID: entity["organization_guid"].(string),
-			}
-		}
+// SpaceMap indexes spaces by their GUID.
+func SpaceMap(spaces []SpaceDetails) map[string]SpaceDetails {
+	smap := make(map[string]SpaceDetails, len(spaces))
+	for _, s := range spaces {
+		smap[s.GUID] = s
 	}
-	return smap, nil
+	return smap
 }
 
 type OrgDetails struct {
 	Name string
 }
 
-func (api *APIHelper) GetOrgMap() (map[string]OrgDetails, error) {
-	oJSON, err := cfcurl.Curl(api.cli, "/v2/organizations")
-	if nil != err {
-		return nil, err
-	}
-
+func (api *APIHelper) GetOrgMap(ctx context.Context) (map[string]OrgDetails, error) {
 	omap := make(map[string]OrgDetails, 32)
 
-	pages := int(oJSON["total_pages"].(float64))
-	for i := 1; i <= pages; i++ {
-		if 1 != i {
-			oJSON, err = cfcurl.Curl(api.cli, "/v2/organizations?page="+strconv.Itoa(i))
+	err := api.eachOrg(ctx, func(r v2Resource) error {
+		var entity v2OrgEntity
+		if err := decodeEntity("organization", r, &entity); nil != err {
+			return err
 		}
-		for _, a := range oJSON["resources"].([]interface{}) {
-			theSvc := a.(map[string]interface{})
-
-			meta := theSvc["metadata"].(map[string]interface{})
-			entity := theSvc["entity"].(map[string]interface{})
-
-			omap[meta["guid"].(string)] = OrgDetails{
-				Name: entity["name"].(string),
-			}
+		if err := requireFields("organization", r.Metadata.GUID,
+			"guid", r.Metadata.GUID); nil != err {
+			return err
+		}
+		omap[r.Metadata.GUID] = OrgDetails{
+			Name: entity.Name,
 		}
+		return nil
+	})
+	if nil != err {
+		return nil, err
 	}
 	return omap, nil
 }
@@ -2,14 +2,19 @@ package apihelper
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"os"
+	"strings"
 
 	"github.com/cloudfoundry/cli/plugin/pluginfakes"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
+// ctx is the context of requests which are not canceled
+var ctx = context.Background()
+
 func slurp(filename string) []string {
 	var b []string
 	file, _ := os.Open(filename)
@@ -39,54 +44,116 @@ var _ = Describe("UsageReport", func() {
 
 		It("should return two orgs", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(orgsJSON, nil)
-			orgs, _ := api.GetOrgs()
+			orgs, _ := api.GetOrgs(ctx)
 			Expect(len(orgs)).To(Equal(2))
 		})
 
 		It("does something intellegent when cf curl fails", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(
 				nil, errors.New("bad things"))
-			_, err := api.GetOrgs()
+			_, err := api.GetOrgs(ctx)
 			Expect(err).ToNot(BeNil())
 		})
 
 		It("populates the url", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(orgsJSON, nil)
-			orgs, _ := api.GetOrgs()
+			orgs, _ := api.GetOrgs(ctx)
 			org := orgs[0]
 			Expect(org.URL).To(Equal("/v2/organizations/b1a23fd6-ac8d-4304-a3b4-815745417acd"))
 		})
 
+		It("populates the guids of the org and its quota", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(orgsJSON, nil)
+			orgs, _ := api.GetOrgs(ctx)
+			Expect(orgs[0].GUID).To(Equal("b1a23fd6-ac8d-4304-a3b4-815745417acd"))
+			Expect(orgs[0].QuotaGUID).To(Equal("2066e394-09e2-4fa1-a450-233b1198737f"))
+		})
+
 		It("calls /v2/orgs", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(orgsJSON, nil)
-			api.GetOrgs()
+			api.GetOrgs(ctx)
 			args := fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)
-			Expect(args[1]).To(Equal("/v2/organizations"))
+			Expect(args[1]).To(Equal("/v2/organizations?results-per-page=100"))
 		})
 
 	})
 
 	Describe("paged org output", func() {
-		var orgsPage1 []string
+		var orgsPage1, orgsPage2 []string
 
 		BeforeEach(func() {
 			orgsPage1 = slurp("test-data/paged-orgs-page-1.json")
+			orgsPage2 = slurp("test-data/paged-orgs-page-2.json")
+			fakeCliConnection.CliCommandWithoutTerminalOutputStub = func(args ...string) ([]string, error) {
+				if strings.Contains(args[1], "page=2") {
+					return orgsPage2, nil
+				}
+				return orgsPage1, nil
+			}
 		})
 
 		It("deals with paged output", func() {
-			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(orgsPage1, nil)
-			api.GetOrgs()
+			api.GetOrgs(ctx)
 			args := fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)
-			Expect(args[1]).To(Equal("/v2/organizations"))
+			Expect(args[1]).To(Equal("/v2/organizations?results-per-page=100"))
 			Ω(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(2))
 		})
 
 		It("Should have 100 orgs", func() {
-			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(orgsPage1, nil)
-			orgs, _ := api.GetOrgs()
+			orgs, _ := api.GetOrgs(ctx)
 			args := fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(1)
-			Expect(args[1]).To(Equal("/v2/organizations?page=2"))
+			Expect(args[1]).To(Equal("/v2/organizations?order-direction=asc&page=2&results-per-page=50"))
+			Ω(orgs).To(HaveLen(100))
+		})
+
+		It("stops at total_pages even if next_url is always set", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(orgsPage1, nil)
+			orgs, err := api.GetOrgs(ctx)
+			Expect(err).To(BeNil())
 			Ω(orgs).To(HaveLen(100))
+			Ω(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(2))
+		})
+
+		It("returns an error when a later page fails", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputStub = func(args ...string) ([]string, error) {
+				if strings.Contains(args[1], "page=2") {
+					return nil, errors.New("Bad Things")
+				}
+				return orgsPage1, nil
+			}
+			orgs, err := api.GetOrgs(ctx)
+			Expect(orgs).To(BeNil())
+			Expect(err).ToNot(BeNil())
+			pageErr, ok := err.(*PageError)
+			Expect(ok).To(BeTrue())
+			Expect(pageErr.Page).To(Equal(2))
+			Expect(pageErr.TotalPages).To(Equal(0))
+		})
+
+	})
+
+	Describe("paged space output", func() {
+		var spacesPage1, spacesPage2 []string
+
+		BeforeEach(func() {
+			spacesPage1 = slurp("test-data/paged-spaces-page-1.json")
+			spacesPage2 = slurp("test-data/paged-spaces-page-2.json")
+			fakeCliConnection.CliCommandWithoutTerminalOutputStub = func(args ...string) ([]string, error) {
+				if strings.Contains(args[1], "page=2") {
+					return spacesPage2, nil
+				}
+				return spacesPage1, nil
+			}
+		})
+
+		It("follows next_url for the spaces of an org", func() {
+			spaces, err := api.GetOrgSpaces(ctx, "/v2/organizations/12345/spaces")
+			Expect(err).To(BeNil())
+			Ω(spaces).To(HaveLen(2))
+			Expect(spaces[1].Name).To(Equal("jdk-space-2"))
+			args := fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)
+			Expect(args[1]).To(Equal("/v2/organizations/12345/spaces?results-per-page=100"))
+			Ω(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(2))
 		})
 	})
 
@@ -98,7 +165,7 @@ var _ = Describe("UsageReport", func() {
 		})
 
 		It("should return an error when it can't fetch the memory limit", func() {
-			_, err := api.GetQuotaMemoryLimit("/v2/somequota")
+			_, err := api.GetQuotaMemoryLimit(ctx, "/v2/somequota")
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(
 				nil, errors.New("Bad Things"))
 			Expect(err).ToNot(BeNil())
@@ -107,7 +174,7 @@ var _ = Describe("UsageReport", func() {
 		It("should reutrn 10240 as the memory limit", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(
 				quotaJSON, nil)
-			limit, _ := api.GetQuotaMemoryLimit("/v2/quotas/")
+			limit, _ := api.GetQuotaMemoryLimit(ctx, "/v2/quotas/")
 			Expect(limit).To(Equal(float64(10240)))
 		})
 	})
@@ -123,14 +190,14 @@ var _ = Describe("UsageReport", func() {
 		It("should return an error when it can't fetch the orgs memory usage", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil,
 				errors.New("Bad things"))
-			_, err := api.GetOrgMemoryUsage(org)
+			_, err := api.GetOrgMemoryUsage(ctx, org)
 			Expect(err).ToNot(BeNil())
 		})
 
 		It("should return the memory usage", func() {
 			org.URL = "/v2/organizations/1234"
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(usageJSON, nil)
-			usage, _ := api.GetOrgMemoryUsage(org)
+			usage, _ := api.GetOrgMemoryUsage(ctx, org)
 			Expect(usage).To(Equal(float64(512)))
 		})
 	})
@@ -144,19 +211,19 @@ var _ = Describe("UsageReport", func() {
 
 		It("should error when the the spaces url fails", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("Bad Things"))
-			_, err := api.GetOrgSpaces("/v2/organizations/12345/spaces")
+			_, err := api.GetOrgSpaces(ctx, "/v2/organizations/12345/spaces")
 			Expect(err).ToNot(BeNil())
 		})
 
 		It("should return two spaces", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(spacesJSON, nil)
-			spaces, _ := api.GetOrgSpaces("/v2/organizations/12345/spaces")
+			spaces, _ := api.GetOrgSpaces(ctx, "/v2/organizations/12345/spaces")
 			Expect(len(spaces)).To(Equal(2))
 		})
 
 		It("should have name jdk-space", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(spacesJSON, nil)
-			spaces, _ := api.GetOrgSpaces("/v2/organizations/12345/spaces")
+			spaces, _ := api.GetOrgSpaces(ctx, "/v2/organizations/12345/spaces")
 			Expect(spaces[0].Name).To(Equal("jdk-space"))
 			Expect(spaces[0].AppsURL).To(Equal("/v2/spaces/81c310ed-d258-48d7-a57a-6522d93a4217/apps"))
 		})
@@ -171,18 +238,113 @@ var _ = Describe("UsageReport", func() {
 
 		It("should return an error when the apps url fails", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("Bad Things"))
-			_, err := api.GetSpaceApps("/v2/whateverapps")
+			_, err := api.GetSpaceApps(ctx, "/v2/whateverapps")
 			Expect(err).ToNot(BeNil())
 		})
 
 		It("should return one app with 1 instance and 1024 mb of ram", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(appsJSON, nil)
-			apps, _ := api.GetSpaceApps("/v2/whateverapps")
+			apps, _ := api.GetSpaceApps(ctx, "/v2/whateverapps")
 			Expect(len(apps)).To(Equal(1))
 			Expect(apps[0].Instances).To(Equal(float64(1)))
 			Expect(apps[0].RAM).To(Equal(float64(1024)))
+			Expect(apps[0].Disk).To(Equal(float64(1024)))
 			Expect(apps[0].Running).To(BeTrue())
 		})
+
+		It("lists all apps of the foundation with their space", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(appsJSON, nil)
+			apps, err := api.GetApps(ctx)
+			Expect(err).To(BeNil())
+			Expect(apps).To(HaveLen(1))
+			Expect(apps[0].SpaceGUID).To(Equal("2fd3c1e0-3058-4eb1-be22-5c5cb5aa44f1"))
+			args := fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)
+			Expect(args[1]).To(Equal("/v2/apps?results-per-page=100"))
+		})
+	})
+
+	Describe("get quota map", func() {
+		It("should return an error when the quota definitions url fails", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("Bad Things"))
+			_, err := api.GetQuotaMap(ctx)
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("returns the memory limit of all quota definitions", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(slurp("test-data/quota_definitions.json"), nil)
+			qm, err := api.GetQuotaMap(ctx)
+			Expect(err).To(BeNil())
+			Expect(qm).To(HaveLen(2))
+			Expect(qm["ea556f14-34d6-4a01-ac72-149b58af02e0"].Name).To(Equal("runaway"))
+			Expect(qm["ea556f14-34d6-4a01-ac72-149b58af02e0"].MemoryLimit).To(Equal(float64(102400)))
+			Expect(qm["2066e394-09e2-4fa1-a450-233b1198737f"].TotalServices).To(Equal(float64(100)))
+			Expect(qm["2066e394-09e2-4fa1-a450-233b1198737f"].TotalRoutes).To(Equal(float64(1000)))
+			Expect(qm["2066e394-09e2-4fa1-a450-233b1198737f"].InstanceMemoryLimit).To(Equal(float64(-1)))
+			// limits unknown to older Cloud Controllers are unlimited
+			Expect(qm["2066e394-09e2-4fa1-a450-233b1198737f"].AppInstanceLimit).To(Equal(float64(-1)))
+			args := fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)
+			Expect(args[1]).To(Equal("/v2/quota_definitions?results-per-page=100"))
+		})
+	})
+
+	Describe("null and malformed fields", func() {
+		It("accepts docker apps without a buildpack", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(slurp("test-data/docker-apps.json"), nil)
+			apps, err := api.GetSpaceApps(ctx, "/v2/whateverapps")
+			Expect(err).To(BeNil())
+			Expect(apps).To(HaveLen(1))
+			Expect(apps[0].Name).To(Equal("docker-app"))
+		})
+
+		It("names the app and field when the memory is null", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(slurp("test-data/apps-null-memory.json"), nil)
+			_, err := api.GetSpaceApps(ctx, "/v2/whateverapps")
+			Expect(err).ToNot(BeNil())
+			pageErr, ok := err.(*PageError)
+			Expect(ok).To(BeTrue())
+			decodeErr, ok := pageErr.Err.(*DecodeError)
+			Expect(ok).To(BeTrue())
+			Expect(decodeErr.GUID).To(Equal("9a2b7f0e-5c7e-4a0c-8d5f-3e1f2b6c7d8e"))
+			Expect(decodeErr.Field).To(Equal("memory"))
+			Expect(err.Error()).To(ContainSubstring("9a2b7f0e-5c7e-4a0c-8d5f-3e1f2b6c7d8e"))
+		})
+
+		It("names the field when it has the wrong type", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(
+				[]string{`{"entity": {"memory_limit": "lots"}, "metadata": {"guid": "q1"}}`}, nil)
+			_, err := api.GetQuotaMemoryLimit(ctx, "/v2/quota_definitions/q1")
+			decodeErr, ok := err.(*DecodeError)
+			Expect(ok).To(BeTrue())
+			Expect(decodeErr.GUID).To(Equal("q1"))
+			Expect(decodeErr.Field).To(Equal("memory_limit"))
+		})
+
+		It("returns orgs without a quota definition", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(slurp("test-data/org-without-quota.json"), nil)
+			orgs, err := api.GetOrgs(ctx)
+			Expect(err).To(BeNil())
+			Expect(orgs).To(HaveLen(1))
+			Expect(orgs[0].QuotaURL).To(Equal(""))
+
+			_, err = api.GetQuotaMemoryLimit(ctx, orgs[0].QuotaURL)
+			Expect(err).To(Equal(ErrNoQuota))
+		})
+
+		It("returns service instances without a service plan", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(slurp("test-data/service-instances-without-plan.json"), nil)
+			siMap, err := api.GetServiceInstanceMap(ctx)
+			Expect(err).To(BeNil())
+			Expect(siMap["215b97be-ec77-4224-9c38-c4f2d86b56c1"].ServicePlanGUID).To(Equal(""))
+		})
+
+		It("returns Cloud Controller error responses as APIError", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(slurp("test-data/not-authorized.json"), nil)
+			_, err := api.GetOrgMemoryUsage(ctx, Organization{URL: "/v2/organizations/1234"})
+			apiErr, ok := err.(*APIError)
+			Expect(ok).To(BeTrue())
+			Expect(apiErr.ErrorCode).To(Equal("CF-NotAuthorized"))
+			Expect(apiErr.Path).To(Equal("/v2/organizations/1234/memory_usage"))
+		})
 	})
 
 	// TODO need tests for no spaces and no apps in org.
@@ -196,13 +358,13 @@ var _ = Describe("UsageReport", func() {
 
 		It("should return an error when the service binding url fails", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("Bad Things"))
-			_, err := api.GetServiceBindings("/v2/whateverapps")
+			_, err := api.GetServiceBindings(ctx, "/v2/whateverapps")
 			Expect(err).ToNot(BeNil())
 		})
 
 		It("should return one service binding with the service instance GUID to be set", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(sbJSON, nil)
-			bindings, err := api.GetServiceBindings("/v2/whateverapps")
+			bindings, err := api.GetServiceBindings(ctx, "/v2/whateverapps")
 			Expect(err).To(BeNil())
 			Expect(len(bindings)).To(Equal(1))
 			Expect(bindings[0].ServiceInstanceGUID).To(Equal("92f0f510-dbb1-4c04-aa7c-28a8dc0797b4"))
@@ -219,13 +381,13 @@ var _ = Describe("UsageReport", func() {
 
 		It("should return an error when the service instance url fails", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("Bad Things"))
-			_, err := api.GetServiceInstanceMap()
+			_, err := api.GetServiceInstanceMap(ctx)
 			Expect(err).ToNot(BeNil())
 		})
 
 		It("should return a map containing a specific element with all entries set", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(serviceInstancesJSON, nil)
-			siMap, err := api.GetServiceInstanceMap()
+			siMap, err := api.GetServiceInstanceMap(ctx)
 
 			Expect(err).To(BeNil())
 			Expect(siMap).NotTo(BeNil())
@@ -247,13 +409,13 @@ var _ = Describe("UsageReport", func() {
 
 		It("should return an error when the service plan url fails", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("Bad Things"))
-			_, err := api.GetServicePlanMap()
+			_, err := api.GetServicePlanMap(ctx)
 			Expect(err).ToNot(BeNil())
 		})
 
 		It("should return a map containing a specific element with all entries set", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(servicePlanJSON, nil)
-			spMap, err := api.GetServicePlanMap()
+			spMap, err := api.GetServicePlanMap(ctx)
 
 			Expect(err).To(BeNil())
 			Expect(spMap).NotTo(BeNil())
@@ -276,13 +438,13 @@ var _ = Describe("UsageReport", func() {
 
 		It("should return an error when the services url call fails", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("Bad Things"))
-			_, err := api.GetServiceInstanceMap()
+			_, err := api.GetServiceInstanceMap(ctx)
 			Expect(err).ToNot(BeNil())
 		})
 
 		It("should return a map containing a specific element with all entries set", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(serviceJSON, nil)
-			spMap, err := api.GetServiceMap()
+			spMap, err := api.GetServiceMap(ctx)
 
 			Expect(err).To(BeNil())
 			Expect(spMap).NotTo(BeNil())
@@ -305,13 +467,13 @@ var _ = Describe("UsageReport", func() {
 
 		It("should return an error when the user provided services url call fails", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("Bad Things"))
-			_, err := api.GetUserProvidedServiceMap()
+			_, err := api.GetUserProvidedServiceMap(ctx)
 			Expect(err).ToNot(BeNil())
 		})
 
 		It("should return a map containing a specific element with all entries set", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(serviceJSON, nil)
-			sMap, err := api.GetUserProvidedServiceMap()
+			sMap, err := api.GetUserProvidedServiceMap(ctx)
 
 			Expect(err).To(BeNil())
 			Expect(sMap).NotTo(BeNil())
@@ -333,13 +495,13 @@ var _ = Describe("UsageReport", func() {
 
 		It("should return an error when the service bindings url fails", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("Bad Things"))
-			_, err := api.GetServiceBindingsList()
+			_, err := api.GetServiceBindingsList(ctx)
 			Expect(err).ToNot(BeNil())
 		})
 
 		It("should return a list of service bindings with all required entries set", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(serviceBindingJSON, nil)
-			sb, err := api.GetServiceBindingsList()
+			sb, err := api.GetServiceBindingsList(ctx)
 
 			Expect(err).To(BeNil())
 			Expect(sb).NotTo(BeNil())
@@ -359,13 +521,13 @@ var _ = Describe("UsageReport", func() {
 
 		It("should return an error when the service bindings url fails", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("Bad Things"))
-			_, err := api.GetSpaceMap()
+			_, err := api.GetSpaceMap(ctx)
 			Expect(err).ToNot(BeNil())
 		})
 
 		It("should return a list of service bindings with all required entries set", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(spaceJSON, nil)
-			sm, err := api.GetSpaceMap()
+			sm, err := api.GetSpaceMap(ctx)
 
 			Expect(err).To(BeNil())
 			Expect(sm).NotTo(BeNil())
@@ -375,6 +537,15 @@ var _ = Describe("UsageReport", func() {
 			Expect(space.Name).To(Equal("jdk-space-2"))
 			Expect(space.OrgGUID).To(Equal("b1a23fd6-ac8d-4304-a3b4-815745417acd"))
 		})
+
+		It("lists the spaces in the order of the API", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(spaceJSON, nil)
+			spaces, err := api.GetSpaces(ctx)
+			Expect(err).To(BeNil())
+			Expect(spaces).To(HaveLen(2))
+			Expect(spaces[0].Name).To(Equal("jdk-space"))
+			Expect(spaces[1].Name).To(Equal("jdk-space-2"))
+		})
 	})
 
 	Describe("get org map", func() {
@@ -386,13 +557,13 @@ var _ = Describe("UsageReport", func() {
 
 		It("should return an error when the org url call fails", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("Bad Things"))
-			_, err := api.GetOrgMap()
+			_, err := api.GetOrgMap(ctx)
 			Expect(err).ToNot(BeNil())
 		})
 
 		It("should return a list of organisations with all required entries set", func() {
 			fakeCliConnection.CliCommandWithoutTerminalOutputReturns(orgJSON, nil)
-			om, err := api.GetOrgMap()
+			om, err := api.GetOrgMap(ctx)
 
 			Expect(err).To(BeNil())
 			Expect(om).NotTo(BeNil())
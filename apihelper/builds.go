@@ -0,0 +1,184 @@
+package apihelper
+
+import (
+	"context"
+	"regexp"
+)
+
+// Build is how an app is built: with buildpacks on a stack, or from a
+// docker image. Apps which were never staged lack the detected buildpacks.
+type Build struct {
+	AppGUID     string
+	Stack       string
+	Buildpacks  []Buildpack
+	DockerImage string
+}
+
+// Buildpack is a buildpack an app is built with. Version is empty if the
+// API does not tell it.
+type Buildpack struct {
+	Name    string
+	Version string
+}
+
+type v2BuildEntity struct {
+	StackGUID             string `json:"stack_guid"`
+	Buildpack             string `json:"buildpack"`
+	DetectedBuildpack     string `json:"detected_buildpack"`
+	DetectedBuildpackGUID string `json:"detected_buildpack_guid"`
+	DockerImage           string `json:"docker_image"`
+}
+
+type v2BuildpackEntity struct {
+	Name     string `json:"name"`
+	Filename string `json:"filename"`
+}
+
+type v2StackEntity struct {
+	Name string `json:"name"`
+}
+
+type v3Lifecycle struct {
+	Type string `json:"type"`
+	Data struct {
+		Buildpacks []string `json:"buildpacks"`
+		Stack      string   `json:"stack"`
+	} `json:"data"`
+}
+
+type v3Droplet struct {
+	GUID       string `json:"guid"`
+	Stack      string `json:"stack"`
+	Image      string `json:"image"`
+	Buildpacks []struct {
+		Name          string `json:"name"`
+		BuildpackName string `json:"buildpack_name"`
+		Version       string `json:"version"`
+	} `json:"buildpacks"`
+}
+
+// buildpackVersion finds the version in the file name of an admin
+// buildpack, like 1.7.5 in ruby_buildpack-cached-cflinuxfs3-v1.7.5.zip.
+var buildpackVersion = regexp.MustCompile(`[-_]v?(\d+(?:\.\d+)+)`)
+
+// GetBuilds returns the builds of the apps of the scope. The detected
+// admin buildpack of an app wins over the configured one, whose version v2
+// does not know. The version of admin buildpacks is the one in their file
+// name.
+func (api *APIHelper) GetBuilds(ctx context.Context) ([]Build, error) {
+	stacks := make(map[string]string)
+	err := api.getAllPages(ctx, "/v2/stacks", "stack", func(r v2Resource) error {
+		var entity v2StackEntity
+		if err := decodeEntity("stack", r, &entity); nil != err {
+			return err
+		}
+		stacks[r.Metadata.GUID] = entity.Name
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+
+	buildpacks := make(map[string]Buildpack)
+	err = api.getAllPages(ctx, "/v2/buildpacks", "buildpack", func(r v2Resource) error {
+		var entity v2BuildpackEntity
+		if err := decodeEntity("buildpack", r, &entity); nil != err {
+			return err
+		}
+		bp := Buildpack{Name: entity.Name}
+		if m := buildpackVersion.FindStringSubmatch(entity.Filename); m != nil {
+			bp.Version = m[1]
+		}
+		buildpacks[r.Metadata.GUID] = bp
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+
+	builds := []Build{}
+	err = api.getAllPages(ctx, api.scope.v2List("/v2/apps"), "app", func(r v2Resource) error {
+		var entity v2BuildEntity
+		if err := decodeEntity("app", r, &entity); nil != err {
+			return err
+		}
+		build := Build{AppGUID: r.Metadata.GUID}
+		if entity.DockerImage != "" {
+			build.DockerImage = entity.DockerImage
+			builds = append(builds, build)
+			return nil
+		}
+		build.Stack = stacks[entity.StackGUID]
+		if bp, exists := buildpacks[entity.DetectedBuildpackGUID]; exists {
+			build.Buildpacks = []Buildpack{bp}
+		} else if entity.Buildpack != "" {
+			build.Buildpacks = []Buildpack{{Name: entity.Buildpack}}
+		} else if entity.DetectedBuildpack != "" {
+			build.Buildpacks = []Buildpack{{Name: entity.DetectedBuildpack}}
+		}
+		builds = append(builds, build)
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return builds, nil
+}
+
+// GetAppBuild returns ErrNoDroplet, v2 tells the build of an app in
+// GetBuilds already.
+func (api *APIHelper) GetAppBuild(ctx context.Context, appGUID string) (Build, error) {
+	return Build{}, ErrNoDroplet
+}
+
+// GetBuilds returns the configured lifecycle of the apps of the scope. What
+// an app is actually built with is its current droplet, which GetAppBuild
+// returns.
+func (api *APIHelperV3) GetBuilds(ctx context.Context) ([]Build, error) {
+	rawApps, err := api.getApps(ctx, api.scope.v3List("/v3/apps", "organization_guids", "space_guids"))
+	if nil != err {
+		return nil, err
+	}
+
+	builds := make([]Build, 0, len(rawApps))
+	for _, a := range rawApps {
+		build := Build{AppGUID: a.GUID}
+		if a.Lifecycle.Type != "docker" {
+			build.Stack = a.Lifecycle.Data.Stack
+			for _, name := range a.Lifecycle.Data.Buildpacks {
+				build.Buildpacks = append(build.Buildpacks, Buildpack{Name: name})
+			}
+		}
+		builds = append(builds, build)
+	}
+	return builds, nil
+}
+
+// GetAppBuild returns the build of the current droplet of the app, which
+// need not be the newest one after a rollback, or ErrNoDroplet if it has
+// none.
+func (api *APIHelperV3) GetAppBuild(ctx context.Context, appGUID string) (Build, error) {
+	var d v3Droplet
+	err := getJSON(ctx, api.transport, "/v3/apps/"+appGUID+"/droplets/current", "droplet", &d)
+	if isNotFound(err) {
+		return Build{}, ErrNoDroplet
+	}
+	if nil != err {
+		return Build{}, err
+	}
+
+	build := Build{AppGUID: appGUID}
+	if d.Image != "" {
+		build.DockerImage = d.Image
+		return build, nil
+	}
+	build.Stack = d.Stack
+	for _, bp := range d.Buildpacks {
+		name := bp.Name
+		if name == "" {
+			name = bp.BuildpackName
+		}
+		build.Buildpacks = append(build.Buildpacks, Buildpack{Name: name, Version: bp.Version})
+	}
+	return build, nil
+}
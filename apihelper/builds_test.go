@@ -0,0 +1,63 @@
+package apihelper
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/cloudfoundry/cli/plugin/pluginfakes"
+	"github.com/dgruber/usagereport-plugin/apihelper/fakecc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Builds", func() {
+	It("resolves the stacks and buildpacks of the apps of the scope with v2", func() {
+		server := fakecc.New(fakecc.Foundation{
+			Orgs:   []fakecc.Org{{GUID: "o-1", Name: "org"}, {GUID: "o-2", Name: "other"}},
+			Spaces: []fakecc.Space{{GUID: "s-1", Name: "dev", OrgGUID: "o-1"}, {GUID: "s-2", Name: "dev", OrgGUID: "o-2"}},
+			Stacks: []fakecc.Stack{{GUID: "st-1", Name: "cflinuxfs3"}},
+			Buildpacks: []fakecc.Buildpack{
+				{GUID: "bp-1", Name: "java_buildpack_offline", Filename: "java-buildpack-offline-cflinuxfs3-v4.16.1.zip"},
+				{GUID: "bp-2", Name: "custom", Filename: "custom.zip"},
+			},
+			Apps: []fakecc.App{
+				{GUID: "a-1", Name: "java", SpaceGUID: "s-1", StackGUID: "st-1", BuildpackGUID: "bp-1"},
+				{GUID: "a-2", Name: "custom", SpaceGUID: "s-1", StackGUID: "st-1", BuildpackGUID: "bp-2"},
+				{GUID: "a-3", Name: "git", SpaceGUID: "s-1", StackGUID: "st-1", Buildpack: "https://github.com/cloudfoundry/go-buildpack"},
+				{GUID: "a-4", Name: "docker", SpaceGUID: "s-1", StackGUID: "st-1", DockerImage: "nginx:1.19"},
+				{GUID: "a-5", Name: "other", SpaceGUID: "s-2", StackGUID: "st-1", BuildpackGUID: "bp-1"},
+			},
+		})
+		defer server.Close()
+		fakeCliConnection := &pluginfakes.FakeCliConnection{}
+		fakeCliConnection.ApiEndpointReturns(server.URL, nil)
+		fakeCliConnection.AccessTokenReturns("bearer fake-token", nil)
+		transport, err := NewHTTPTransport(fakeCliConnection)
+		Expect(err).To(BeNil())
+
+		api, err := NewScopedForAPIVersion(ctx, transport, APIVersionV2, Scope{OrgGUID: "o-1"})
+		Expect(err).To(BeNil())
+		builds, err := api.GetBuilds(ctx)
+		Expect(err).To(BeNil())
+		Expect(builds).To(Equal([]Build{
+			{AppGUID: "a-1", Stack: "cflinuxfs3", Buildpacks: []Buildpack{{Name: "java_buildpack_offline", Version: "4.16.1"}}},
+			{AppGUID: "a-2", Stack: "cflinuxfs3", Buildpacks: []Buildpack{{Name: "custom"}}},
+			{AppGUID: "a-3", Stack: "cflinuxfs3", Buildpacks: []Buildpack{{Name: "https://github.com/cloudfoundry/go-buildpack"}}},
+			{AppGUID: "a-4", DockerImage: "nginx:1.19"},
+		}))
+	})
+
+	It("falls back to the detected buildpack of v2 apps", func() {
+		api := &APIHelper{transport: transportFunc(func(path string) ([]byte, error) {
+			if strings.HasPrefix(path, "/v2/apps") {
+				return ioutil.ReadFile("test-data/apps.json")
+			}
+			return []byte(`{"total_results": 0, "total_pages": 1, "next_url": null, "resources": []}`), nil
+		})}
+		builds, err := api.GetBuilds(ctx)
+		Expect(err).To(BeNil())
+		Expect(builds).To(HaveLen(1))
+		Expect(builds[0].Buildpacks).To(Equal([]Buildpack{{Name: "Node.js"}}))
+		Expect(builds[0].Stack).To(Equal(""))
+	})
+})
@@ -0,0 +1,137 @@
+package apihelper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is the time cached responses are used for.
+const DefaultCacheTTL = 10 * time.Minute
+
+// cacheEntry is a cached response as stored on disk.
+type cacheEntry struct {
+	Path      string    `json:"path"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Body      string    `json:"body"`
+}
+
+// CacheTransport stores successful responses in a directory and serves
+// them again until they are older than the TTL. Entries are keyed by API
+// endpoint, user and request path, so different foundations and users can
+// share a directory.
+type CacheTransport struct {
+	transport Transport
+	dir       string
+	endpoint  string
+	user      string
+	ttl       time.Duration
+	refresh   bool
+
+	now func() time.Time
+
+	mu     sync.Mutex
+	oldest time.Time
+}
+
+// NewCacheTransport wraps t with a response cache in dir. With refresh set
+// cached responses are not used but replaced.
+func NewCacheTransport(t Transport, dir, endpoint, user string, ttl time.Duration, refresh bool) (*CacheTransport, error) {
+	if err := os.MkdirAll(dir, 0700); nil != err {
+		return nil, fmt.Errorf("creating cache directory: %v", err)
+	}
+	return &CacheTransport{
+		transport: t,
+		dir:       dir,
+		endpoint:  endpoint,
+		user:      user,
+		ttl:       ttl,
+		refresh:   refresh,
+		now:       time.Now,
+	}, nil
+}
+
+// Get returns the cached response for path if it is recent enough,
+// otherwise it issues the request and caches the response.
+func (t *CacheTransport) Get(ctx context.Context, path string) ([]byte, error) {
+	file := t.file(path)
+	if !t.refresh {
+		if entry, ok := t.read(file); ok && t.now().Sub(entry.FetchedAt) <= t.ttl {
+			t.served(entry.FetchedAt)
+			return []byte(entry.Body), nil
+		}
+	}
+
+	body, err := t.transport.Get(ctx, path)
+	if nil != err {
+		return nil, err
+	}
+	// cf curl returns error bodies without an error, never cache them
+	if checkAPIError(path, body) == nil && json.Valid(body) {
+		t.write(file, cacheEntry{Path: path, FetchedAt: t.now(), Body: string(body)})
+	}
+	return body, nil
+}
+
+// CachedSince returns the time the oldest cached response served so far was
+// fetched. ok is false if all responses came from the API.
+func (t *CacheTransport) CachedSince() (since time.Time, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.oldest, !t.oldest.IsZero()
+}
+
+func (t *CacheTransport) served(fetchedAt time.Time) {
+	t.mu.Lock()
+	if t.oldest.IsZero() || fetchedAt.Before(t.oldest) {
+		t.oldest = fetchedAt
+	}
+	t.mu.Unlock()
+}
+
+func (t *CacheTransport) file(path string) string {
+	sum := sha256.Sum256([]byte(t.endpoint + "\n" + t.user + "\n" + path))
+	return filepath.Join(t.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (t *CacheTransport) read(file string) (cacheEntry, bool) {
+	var entry cacheEntry
+	data, err := ioutil.ReadFile(file)
+	if nil != err {
+		return entry, false
+	}
+	if err := json.Unmarshal(data, &entry); nil != err {
+		return entry, false
+	}
+	return entry, true
+}
+
+// write stores the entry through a temporary file so that concurrent runs
+// never read a partial entry. Failures only cost a cache miss later.
+func (t *CacheTransport) write(file string, entry cacheEntry) {
+	data, err := json.Marshal(entry)
+	if nil != err {
+		return
+	}
+	tmp, err := ioutil.TempFile(t.dir, ".tmp-")
+	if nil != err {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if nil != err {
+		os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,97 @@
+package apihelper
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Response cache", func() {
+	var dir string
+	var calls int
+	var body string
+	var clock time.Time
+	var backend Transport
+
+	newCache := func(endpoint, user string, refresh bool) *CacheTransport {
+		cache, err := NewCacheTransport(backend, dir, endpoint, user, 10*time.Minute, refresh)
+		Expect(err).To(BeNil())
+		cache.now = func() time.Time { return clock }
+		return cache
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "usagereport-cache")
+		Expect(err).To(BeNil())
+		calls = 0
+		body = `{"memory_usage_in_mb": 512}`
+		clock = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+		backend = transportFunc(func(path string) ([]byte, error) {
+			calls++
+			return []byte(body), nil
+		})
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("serves repeated requests from disk until the TTL expired", func() {
+		newCache("https://api.example.com", "user-1", false).Get(ctx, "/v2/organizations")
+
+		cache := newCache("https://api.example.com", "user-1", false)
+		clock = clock.Add(5 * time.Minute)
+		data, err := cache.Get(ctx, "/v2/organizations")
+		Expect(err).To(BeNil())
+		Expect(string(data)).To(Equal(body))
+		Expect(calls).To(Equal(1))
+
+		since, ok := cache.CachedSince()
+		Expect(ok).To(BeTrue())
+		Expect(since).To(Equal(clock.Add(-5 * time.Minute)))
+
+		clock = clock.Add(6 * time.Minute)
+		cache.Get(ctx, "/v2/organizations")
+		Expect(calls).To(Equal(2))
+	})
+
+	It("keys the entries by endpoint and user", func() {
+		newCache("https://api.example.com", "user-1", false).Get(ctx, "/v2/organizations")
+		newCache("https://api.example.com", "user-2", false).Get(ctx, "/v2/organizations")
+		newCache("https://api.other.com", "user-1", false).Get(ctx, "/v2/organizations")
+		Expect(calls).To(Equal(3))
+	})
+
+	It("bypasses and updates the cache on refresh", func() {
+		newCache("https://api.example.com", "user-1", false).Get(ctx, "/v2/organizations")
+		body = `{"memory_usage_in_mb": 1024}`
+
+		cache := newCache("https://api.example.com", "user-1", true)
+		data, _ := cache.Get(ctx, "/v2/organizations")
+		Expect(string(data)).To(Equal(body))
+		_, ok := cache.CachedSince()
+		Expect(ok).To(BeFalse())
+
+		data, _ = newCache("https://api.example.com", "user-1", false).Get(ctx, "/v2/organizations")
+		Expect(string(data)).To(Equal(body))
+		Expect(calls).To(Equal(2))
+	})
+
+	It("does not cache errors", func() {
+		body = `{"code":10003,"description":"You are not authorized","error_code":"CF-NotAuthorized"}`
+		newCache("https://api.example.com", "user-1", false).Get(ctx, "/v2/organizations")
+		newCache("https://api.example.com", "user-1", false).Get(ctx, "/v2/organizations")
+		Expect(calls).To(Equal(2))
+
+		backend = transportFunc(func(path string) ([]byte, error) {
+			return nil, errors.New("Bad Things")
+		})
+		_, err := newCache("https://api.example.com", "user-1", false).Get(ctx, "/v2/spaces")
+		Expect(err).ToNot(BeNil())
+	})
+})
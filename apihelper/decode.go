@@ -0,0 +1,256 @@
+package apihelper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DecodeError is returned when a Cloud Controller response does not have the
+// expected shape. It names the resource and the field which could not be
+// decoded.
+type DecodeError struct {
+	Resource string // kind of resource, like "app" or "organization"
+	GUID     string // GUID of the resource if known
+	Field    string // JSON field which failed to decode if known
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	what := e.Resource
+	if e.GUID != "" {
+		what = fmt.Sprintf("%s %s", e.Resource, e.GUID)
+	}
+	if e.Field != "" {
+		return fmt.Sprintf("decoding %s: field %s: %v", what, e.Field, e.Err)
+	}
+	return fmt.Sprintf("decoding %s: %v", what, e.Err)
+}
+
+// APIError is an error response returned by the Cloud Controller.
+// StatusCode and RetryAfter are only known when talking HTTP directly,
+// cf curl hides them.
+type APIError struct {
+	Path        string
+	StatusCode  int
+	Code        int
+	ErrorCode   string
+	Description string
+	RetryAfter  time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.ErrorCode == "" {
+		return fmt.Sprintf("%s returned %d %s", e.Path, e.StatusCode, e.Description)
+	}
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s returned %d %s (%d): %s", e.Path, e.StatusCode, e.ErrorCode, e.Code, e.Description)
+	}
+	return fmt.Sprintf("%s returned %s (%d): %s", e.Path, e.ErrorCode, e.Code, e.Description)
+}
+
+var errFieldNull = errors.New("is missing or null")
+
+// apiErrorResponse covers the error bodies of the v2 and v3 API.
+type apiErrorResponse struct {
+	Code        int    `json:"code"`
+	ErrorCode   string `json:"error_code"`
+	Description string `json:"description"`
+	Errors      []struct {
+		Code   int    `json:"code"`
+		Title  string `json:"title"`
+		Detail string `json:"detail"`
+	} `json:"errors"`
+}
+
+// v2Metadata is the metadata part of every v2 resource.
+type v2Metadata struct {
+	GUID string `json:"guid"`
+	URL  string `json:"url"`
+}
+
+// v2Resource is a v2 resource with its entity not decoded yet.
+type v2Resource struct {
+	Metadata v2Metadata      `json:"metadata"`
+	Entity   json.RawMessage `json:"entity"`
+}
+
+// v2Page is a single page of a v2 list response.
+type v2Page struct {
+	TotalResults int          `json:"total_results"`
+	TotalPages   int          `json:"total_pages"`
+	NextURL      string       `json:"next_url"`
+	Resources    []v2Resource `json:"resources"`
+}
+
+type v2OrgEntity struct {
+	Name                string `json:"name"`
+	QuotaDefinitionGUID string `json:"quota_definition_guid"`
+	QuotaDefinitionURL  string `json:"quota_definition_url"`
+	SpacesURL           string `json:"spaces_url"`
+}
+
+type v2SpaceEntity struct {
+	Name                     string `json:"name"`
+	OrganizationGUID         string `json:"organization_guid"`
+	AppsURL                  string `json:"apps_url"`
+	SpaceQuotaDefinitionGUID string `json:"space_quota_definition_guid"`
+}
+
+type v2AppEntity struct {
+	Name               string   `json:"name"`
+	SpaceGUID          string   `json:"space_guid"`
+	Instances          *float64 `json:"instances"`
+	Memory             *float64 `json:"memory"`
+	DiskQuota          *float64 `json:"disk_quota"`
+	State              string   `json:"state"`
+	ServiceBindingsURL string   `json:"service_bindings_url"`
+}
+
+type v2QuotaEntity struct {
+	Name                    string   `json:"name"`
+	MemoryLimit             *float64 `json:"memory_limit"`
+	InstanceMemoryLimit     *float64 `json:"instance_memory_limit"`
+	AppInstanceLimit        *float64 `json:"app_instance_limit"`
+	TotalServices           *float64 `json:"total_services"`
+	TotalServiceKeys        *float64 `json:"total_service_keys"`
+	TotalRoutes             *float64 `json:"total_routes"`
+	TotalReservedRoutePorts *float64 `json:"total_reserved_route_ports"`
+}
+
+// quota returns the quota definition of the entity. Limits unknown to older
+// Cloud Controllers are unlimited.
+func (e v2QuotaEntity) quota(guid string) Quota {
+	return Quota{
+		GUID:                    guid,
+		Name:                    e.Name,
+		MemoryLimit:             limit(e.MemoryLimit),
+		InstanceMemoryLimit:     limit(e.InstanceMemoryLimit),
+		AppInstanceLimit:        limit(e.AppInstanceLimit),
+		TotalServices:           limit(e.TotalServices),
+		TotalServiceKeys:        limit(e.TotalServiceKeys),
+		TotalRoutes:             limit(e.TotalRoutes),
+		TotalReservedRoutePorts: limit(e.TotalReservedRoutePorts),
+	}
+}
+
+// valueOf returns the value of an optional field or 0 if it is missing.
+func valueOf(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// limit returns the value of a quota limit or -1 if it is missing.
+func limit(v *float64) float64 {
+	if v == nil {
+		return -1
+	}
+	return *v
+}
+
+type v2MemoryUsage struct {
+	MemoryUsageInMB *float64 `json:"memory_usage_in_mb"`
+}
+
+type v2ServiceBindingEntity struct {
+	AppGUID             string `json:"app_guid"`
+	ServiceInstanceGUID string `json:"service_instance_guid"`
+}
+
+type v2ServiceInstanceEntity struct {
+	Name            string `json:"name"`
+	Type            string `json:"type"`
+	ServicePlanGUID string `json:"service_plan_guid"`
+	SpaceGUID       string `json:"space_guid"`
+}
+
+type v2ServicePlanEntity struct {
+	Name        string `json:"name"`
+	ServiceGUID string `json:"service_guid"`
+}
+
+type v2ServiceEntity struct {
+	Label string `json:"label"`
+}
+
+type v2UserProvidedServiceEntity struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// getJSON fetches path and decodes the JSON response into v. Error
+// responses of the Cloud Controller are returned as *APIError.
+func getJSON(ctx context.Context, t Transport, path, resource string, v interface{}) error {
+	data, err := t.Get(ctx, path)
+	if nil != err {
+		return err
+	}
+	if err := checkAPIError(path, data); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return newDecodeError(resource, "", err)
+	}
+	return nil
+}
+
+// checkAPIError returns an *APIError when data is an error response.
+func checkAPIError(path string, data []byte) error {
+	var r apiErrorResponse
+	if err := json.Unmarshal(data, &r); err != nil {
+		// not an object at all; reported by the caller when decoding
+		return nil
+	}
+	if r.ErrorCode != "" {
+		return &APIError{Path: path, Code: r.Code, ErrorCode: r.ErrorCode, Description: r.Description}
+	}
+	if len(r.Errors) > 0 {
+		return &APIError{Path: path, Code: r.Errors[0].Code, ErrorCode: r.Errors[0].Title, Description: r.Errors[0].Detail}
+	}
+	return nil
+}
+
+// decodeEntity decodes the entity of a v2 resource into v.
+func decodeEntity(resource string, r v2Resource, v interface{}) error {
+	if len(r.Entity) == 0 || string(r.Entity) == "null" {
+		return &DecodeError{Resource: resource, GUID: r.Metadata.GUID, Field: "entity", Err: errFieldNull}
+	}
+	if err := json.Unmarshal(r.Entity, v); err != nil {
+		return newDecodeError(resource, r.Metadata.GUID, err)
+	}
+	return nil
+}
+
+// requireFields returns a *DecodeError for the first empty field. fields
+// alternates between field names and their decoded values.
+func requireFields(resource, guid string, fields ...interface{}) error {
+	for i := 0; i+1 < len(fields); i += 2 {
+		empty := false
+		switch v := fields[i+1].(type) {
+		case string:
+			empty = v == ""
+		case *float64:
+			empty = v == nil
+		}
+		if empty {
+			return &DecodeError{Resource: resource, GUID: guid, Field: fields[i].(string), Err: errFieldNull}
+		}
+	}
+	return nil
+}
+
+func newDecodeError(resource, guid string, err error) error {
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+		return &DecodeError{
+			Resource: resource,
+			GUID:     guid,
+			Field:    typeErr.Field,
+			Err:      fmt.Errorf("expected %s, got %s", typeErr.Type, typeErr.Value),
+		}
+	}
+	return &DecodeError{Resource: resource, GUID: guid, Err: err}
+}
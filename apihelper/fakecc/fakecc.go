@@ -0,0 +1,959 @@
+// Package fakecc provides an in-process fake Cloud Controller serving the
+// v2 endpoints used by the usage report from a configurable foundation, and
+// the v3 lists of orgs, spaces and apps for their labels and of tasks.
+// Lists are paginated like the real Cloud Controller, so tests exercise the
+// same JSON decoding and next_url handling as a real foundation.
+package fakecc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultPageSize is the number of resources per page if the request does
+// not ask for fewer.
+const DefaultPageSize = 50
+
+// Quota is an organization quota definition. Limits holds its other limits
+// by their v2 field like total_routes, the missing ones are not rendered
+// like by older Cloud Controllers.
+type Quota struct {
+	GUID        string
+	Name        string
+	MemoryLimit int // MB, -1 means unlimited
+	Limits      map[string]int
+}
+
+// Org is an organization. QuotaGUID may be empty.
+type Org struct {
+	GUID      string
+	Name      string
+	QuotaGUID string
+	Labels    map[string]string
+}
+
+// SpaceQuota is a space quota definition of an organization.
+type SpaceQuota struct {
+	GUID        string
+	Name        string
+	OrgGUID     string
+	MemoryLimit int // MB, -1 means unlimited
+}
+
+// Space is a space of an organization. QuotaGUID may be empty.
+type Space struct {
+	GUID      string
+	Name      string
+	OrgGUID   string
+	QuotaGUID string
+	Labels    map[string]string
+}
+
+// App is an app of a space. State is STARTED or STOPPED.
+type App struct {
+	GUID      string
+	Name      string
+	SpaceGUID string
+	State     string
+	Instances int
+	Memory    int // MB per instance
+	Disk      int // MB per instance
+	Labels    map[string]string
+	Stats     []InstanceStats // of the first instances, the others are down
+
+	// how the app is built, StackGUID and BuildpackGUID are the ones of the
+	// stack and detected admin buildpack, Buildpack a configured one
+	StackGUID     string
+	BuildpackGUID string
+	Buildpack     string
+	DockerImage   string
+}
+
+// Stack is a stack apps run on.
+type Stack struct {
+	GUID string
+	Name string
+}
+
+// Buildpack is an admin buildpack, Filename is the one of its package.
+type Buildpack struct {
+	GUID     string
+	Name     string
+	Filename string
+}
+
+// InstanceStats is the usage of a running app instance.
+type InstanceStats struct {
+	MemoryMB    int
+	DiskMB      int
+	DiskQuotaMB int
+	CPU         float64 // share of a core
+}
+
+// Service is a service offering of a broker.
+type Service struct {
+	GUID  string
+	Label string
+}
+
+// ServicePlan is a plan of a service.
+type ServicePlan struct {
+	GUID        string
+	Name        string
+	ServiceGUID string
+}
+
+// ServiceInstance is a managed service instance.
+type ServiceInstance struct {
+	GUID            string
+	Name            string
+	ServicePlanGUID string
+	SpaceGUID       string
+}
+
+// UserProvidedServiceInstance is a user provided service instance.
+type UserProvidedServiceInstance struct {
+	GUID      string
+	Name      string
+	SpaceGUID string
+}
+
+// ServiceBinding binds an app to a managed or user provided service instance.
+type ServiceBinding struct {
+	GUID                string
+	AppGUID             string
+	ServiceInstanceGUID string
+}
+
+// Route is a route of a space. Port is 0 for HTTP routes.
+type Route struct {
+	GUID      string
+	SpaceGUID string
+	Port      int
+}
+
+// ServiceKey is a key of a managed service instance.
+type ServiceKey struct {
+	GUID                string
+	ServiceInstanceGUID string
+}
+
+// Task is a task of an app. State is RUNNING, SUCCEEDED or FAILED.
+type Task struct {
+	GUID    string
+	Name    string
+	AppGUID string
+	State   string
+	Memory  int // MB
+	Disk    int // MB
+}
+
+// Foundation is the content served by the fake Cloud Controller. Resources
+// are listed in the given order.
+type Foundation struct {
+	Quotas                       []Quota
+	SpaceQuotas                  []SpaceQuota
+	Orgs                         []Org
+	Spaces                       []Space
+	Apps                         []App
+	Services                     []Service
+	ServicePlans                 []ServicePlan
+	ServiceInstances             []ServiceInstance
+	UserProvidedServiceInstances []UserProvidedServiceInstance
+	ServiceBindings              []ServiceBinding
+	Routes                       []Route
+	ServiceKeys                  []ServiceKey
+	Tasks                        []Task
+	Stacks                       []Stack
+	Buildpacks                   []Buildpack
+}
+
+// Server is a fake Cloud Controller which also acts as its UAA. The
+// fields must not be changed while requests are served.
+type Server struct {
+	*httptest.Server
+
+	Foundation   Foundation
+	Token        string            // required Authorization header, any if empty
+	PageSize     int               // maximum resources per page
+	Users        map[string]string // passwords of the users UAA accepts
+	Clients      map[string]string // secrets of the clients UAA accepts
+	RefreshToken string            // refresh token UAA issues and accepts
+
+	mu       sync.Mutex
+	requests []string
+}
+
+// New starts a fake Cloud Controller serving f. Close it when done.
+func New(f Foundation) *Server {
+	s := &Server{Foundation: f, PageSize: DefaultPageSize}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Requests returns the request URIs served so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// resource is a v2 resource with metadata and entity.
+type resource struct {
+	Metadata metadata    `json:"metadata"`
+	Entity   interface{} `json:"entity"`
+}
+
+type metadata struct {
+	GUID string `json:"guid"`
+	URL  string `json:"url"`
+}
+
+type page struct {
+	TotalResults int        `json:"total_results"`
+	TotalPages   int        `json:"total_pages"`
+	PrevURL      *string    `json:"prev_url"`
+	NextURL      *string    `json:"next_url"`
+	Resources    []resource `json:"resources"`
+}
+
+type apiError struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+	ErrorCode   string `json:"error_code"`
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.URL.RequestURI())
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/oauth/token" {
+		s.serveToken(w, r)
+		return
+	}
+	if s.Token != "" && r.URL.Path != "/" && r.Header.Get("Authorization") != s.Token {
+		writeJSON(w, http.StatusUnauthorized, apiError{1000, "Invalid Auth Token", "CF-InvalidAuthToken"})
+		return
+	}
+	if r.Method != "GET" {
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{10000, "Unknown request", "CF-NotFound"})
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	status, body := s.route(parts, r.URL.Query())
+	writeJSON(w, status, body)
+}
+
+// serveToken issues Token for the password grant of one of the Users, the
+// client credentials grant of one of the Clients and the refresh token
+// grant of RefreshToken. The client is only checked for client credentials.
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	valid := false
+	switch r.PostForm.Get("grant_type") {
+	case "password":
+		password, exists := s.Users[r.PostForm.Get("username")]
+		valid = exists && password == r.PostForm.Get("password")
+	case "client_credentials":
+		client, secret, _ := r.BasicAuth()
+		expected, exists := s.Clients[client]
+		valid = exists && secret == expected
+	case "refresh_token":
+		valid = s.RefreshToken != "" && r.PostForm.Get("refresh_token") == s.RefreshToken
+	}
+	if !valid {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized", "error_description": "Bad credentials"})
+		return
+	}
+
+	token := strings.TrimPrefix(s.Token, "bearer ")
+	if token == "" {
+		token = "fake-token"
+	}
+	response := map[string]string{"access_token": token, "token_type": "bearer"}
+	if s.RefreshToken != "" && r.PostForm.Get("grant_type") != "client_credentials" {
+		response["refresh_token"] = s.RefreshToken
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+var notFound = apiError{10000, "Unknown request", "CF-NotFound"}
+
+// route dispatches the request path to the list or resource it refers to.
+func (s *Server) route(parts []string, query url.Values) (int, interface{}) {
+	f := s.Foundation
+	path := "/" + strings.Join(parts, "/")
+
+	if path == "/" {
+		return http.StatusOK, map[string]interface{}{
+			"links": map[string]interface{}{
+				"self":                map[string]string{"href": s.URL},
+				"cloud_controller_v2": map[string]interface{}{"href": s.URL + "/v2"},
+				"uaa":                 map[string]interface{}{"href": s.URL},
+			},
+		}
+	}
+	if len(parts) == 2 && parts[0] == "v3" {
+		return s.routeV3(path, parts[1], query)
+	}
+	if len(parts) < 2 || parts[0] != "v2" {
+		return http.StatusNotFound, notFound
+	}
+
+	switch {
+	case path == "/v2/organizations":
+		var orgs []resource
+		for _, o := range f.Orgs {
+			orgs = append(orgs, orgResource(o))
+		}
+		return s.filteredPage(path, query, orgs, "name")
+
+	case len(parts) == 3 && parts[1] == "organizations":
+		org, exists := s.org(parts[2])
+		if !exists {
+			return http.StatusNotFound, apiError{30003, "The organization could not be found: " + parts[2], "CF-OrganizationNotFound"}
+		}
+		return http.StatusOK, orgResource(org)
+
+	case len(parts) == 4 && (parts[1] == "organizations" || parts[1] == "spaces") && parts[3] == "services":
+		return s.page(path, query, s.services())
+
+	case len(parts) == 4 && parts[1] == "organizations" && parts[3] == "memory_usage":
+		org, exists := s.org(parts[2])
+		if !exists {
+			return http.StatusNotFound, apiError{30003, "The organization could not be found: " + parts[2], "CF-OrganizationNotFound"}
+		}
+		return http.StatusOK, map[string]int{"memory_usage_in_mb": s.memoryUsage(org.GUID)}
+
+	case len(parts) == 4 && parts[1] == "organizations" && parts[3] == "spaces":
+		var spaces []resource
+		for _, sp := range f.Spaces {
+			if sp.OrgGUID == parts[2] {
+				spaces = append(spaces, spaceResource(sp))
+			}
+		}
+		return s.page(path, query, spaces)
+
+	case path == "/v2/quota_definitions":
+		var quotas []resource
+		for _, q := range f.Quotas {
+			quotas = append(quotas, quotaResource(q))
+		}
+		return s.page(path, query, quotas)
+
+	case len(parts) == 3 && parts[1] == "quota_definitions":
+		for _, q := range f.Quotas {
+			if q.GUID == parts[2] {
+				return http.StatusOK, quotaResource(q)
+			}
+		}
+		return http.StatusNotFound, apiError{240001, "Quota Definition could not be found: " + parts[2], "CF-QuotaDefinitionNotFound"}
+
+	case path == "/v2/space_quota_definitions":
+		var quotas []resource
+		for _, q := range f.SpaceQuotas {
+			quotas = append(quotas, spaceQuotaResource(q))
+		}
+		return s.page(path, query, quotas)
+
+	case len(parts) == 4 && parts[1] == "organizations" && parts[3] == "space_quota_definitions":
+		var quotas []resource
+		for _, q := range f.SpaceQuotas {
+			if q.OrgGUID == parts[2] {
+				quotas = append(quotas, spaceQuotaResource(q))
+			}
+		}
+		return s.page(path, query, quotas)
+
+	case path == "/v2/spaces":
+		var spaces []resource
+		for _, sp := range f.Spaces {
+			spaces = append(spaces, spaceResource(sp))
+		}
+		return s.filteredPage(path, query, spaces, "organization_guid")
+
+	case len(parts) == 3 && parts[1] == "spaces":
+		for _, sp := range f.Spaces {
+			if sp.GUID == parts[2] {
+				return http.StatusOK, spaceResource(sp)
+			}
+		}
+		return http.StatusNotFound, apiError{40004, "The app space could not be found: " + parts[2], "CF-SpaceNotFound"}
+
+	case len(parts) == 4 && parts[1] == "spaces" && parts[3] == "apps":
+		var apps []resource
+		for _, a := range f.Apps {
+			if a.SpaceGUID == parts[2] {
+				apps = append(apps, appResource(a))
+			}
+		}
+		return s.page(path, query, apps)
+
+	case len(parts) == 4 && parts[1] == "spaces" && parts[3] == "routes":
+		var routes []resource
+		for _, r := range f.Routes {
+			if r.SpaceGUID == parts[2] {
+				routes = append(routes, routeResource(r))
+			}
+		}
+		return s.page(path, query, routes)
+
+	case path == "/v2/routes":
+		var routes []resource
+		for _, r := range f.Routes {
+			routes = append(routes, routeResource(r))
+		}
+		return s.filteredPage(path, query, routes, "organization_guid")
+
+	case path == "/v2/service_keys":
+		var keys []resource
+		for _, k := range f.ServiceKeys {
+			keys = append(keys, resource{
+				Metadata: metadata{GUID: k.GUID, URL: "/v2/service_keys/" + k.GUID},
+				Entity:   map[string]interface{}{"service_instance_guid": k.ServiceInstanceGUID},
+			})
+		}
+		return s.filteredPage(path, query, keys, "service_instance_guid")
+
+	case path == "/v2/apps":
+		var apps []resource
+		for _, a := range f.Apps {
+			apps = append(apps, appResource(a))
+		}
+		return s.filteredPage(path, query, apps, "space_guid", "organization_guid")
+
+	case len(parts) == 4 && parts[1] == "apps" && parts[3] == "stats":
+		for _, a := range f.Apps {
+			if a.GUID != parts[2] {
+				continue
+			}
+			if a.State != "STARTED" {
+				return http.StatusBadRequest, apiError{200003, "Could not fetch stats for stopped app: " + a.Name, "CF-AppStoppedStatsError"}
+			}
+			return http.StatusOK, appStats(a)
+		}
+		return http.StatusNotFound, apiError{100004, "The app could not be found: " + parts[2], "CF-AppNotFound"}
+
+	case len(parts) == 4 && parts[1] == "apps" && parts[3] == "service_bindings":
+		var bindings []resource
+		for _, b := range f.ServiceBindings {
+			if b.AppGUID == parts[2] {
+				bindings = append(bindings, bindingResource(b))
+			}
+		}
+		return s.page(path, query, bindings)
+
+	case path == "/v2/service_bindings":
+		var bindings []resource
+		for _, b := range f.ServiceBindings {
+			bindings = append(bindings, bindingResource(b))
+		}
+		return s.filteredPage(path, query, bindings, "app_guid")
+
+	case path == "/v2/service_instances":
+		var instances []resource
+		for _, si := range f.ServiceInstances {
+			instances = append(instances, resource{
+				Metadata: metadata{GUID: si.GUID, URL: "/v2/service_instances/" + si.GUID},
+				Entity: map[string]interface{}{
+					"name":              si.Name,
+					"type":              "managed_service_instance",
+					"service_plan_guid": nullable(si.ServicePlanGUID),
+					"space_guid":        si.SpaceGUID,
+				},
+			})
+		}
+		return s.filteredPage(path, query, instances, "space_guid", "organization_guid")
+
+	case path == "/v2/user_provided_service_instances":
+		var instances []resource
+		for _, ups := range f.UserProvidedServiceInstances {
+			instances = append(instances, resource{
+				Metadata: metadata{GUID: ups.GUID, URL: "/v2/user_provided_service_instances/" + ups.GUID},
+				Entity: map[string]interface{}{
+					"name":       ups.Name,
+					"type":       "user_provided_service_instance",
+					"space_guid": ups.SpaceGUID,
+				},
+			})
+		}
+		return s.filteredPage(path, query, instances, "space_guid", "organization_guid")
+
+	case path == "/v2/service_plans":
+		var plans []resource
+		for _, sp := range f.ServicePlans {
+			plans = append(plans, resource{
+				Metadata: metadata{GUID: sp.GUID, URL: "/v2/service_plans/" + sp.GUID},
+				Entity: map[string]interface{}{
+					"name":         sp.Name,
+					"service_guid": sp.ServiceGUID,
+				},
+			})
+		}
+		return s.page(path, query, plans)
+
+	case path == "/v2/services":
+		return s.page(path, query, s.services())
+
+	case path == "/v2/stacks":
+		var stacks []resource
+		for _, st := range f.Stacks {
+			stacks = append(stacks, resource{
+				Metadata: metadata{GUID: st.GUID, URL: "/v2/stacks/" + st.GUID},
+				Entity:   map[string]interface{}{"name": st.Name},
+			})
+		}
+		return s.page(path, query, stacks)
+
+	case path == "/v2/buildpacks":
+		var buildpacks []resource
+		for _, bp := range f.Buildpacks {
+			buildpacks = append(buildpacks, resource{
+				Metadata: metadata{GUID: bp.GUID, URL: "/v2/buildpacks/" + bp.GUID},
+				Entity:   map[string]interface{}{"name": bp.Name, "filename": bp.Filename},
+			})
+		}
+		return s.page(path, query, buildpacks)
+	}
+	return http.StatusNotFound, notFound
+}
+
+// v3Resource is the part of a v3 org, space, app or task the report reads.
+type v3Resource struct {
+	GUID     string `json:"guid"`
+	Name     string `json:"name"`
+	Metadata struct {
+		Labels map[string]string `json:"labels"`
+	} `json:"metadata"`
+
+	// the fields of a task
+	State         string       `json:"state,omitempty"`
+	MemoryInMB    int          `json:"memory_in_mb,omitempty"`
+	DiskInMB      int          `json:"disk_in_mb,omitempty"`
+	Relationships *v3TaskLinks `json:"relationships,omitempty"`
+
+	orgGUID, spaceGUID, appGUID string
+}
+
+type v3TaskLinks struct {
+	App struct {
+		Data struct {
+			GUID string `json:"guid"`
+		} `json:"data"`
+	} `json:"app"`
+}
+
+func newV3Resource(guid, name, orgGUID, spaceGUID string, labels map[string]string) v3Resource {
+	r := v3Resource{GUID: guid, Name: name, orgGUID: orgGUID, spaceGUID: spaceGUID}
+	r.Metadata.Labels = make(map[string]string)
+	for k, v := range labels {
+		r.Metadata.Labels[k] = v
+	}
+	return r
+}
+
+// routeV3 serves the v3 list of orgs, spaces, apps or tasks filtered by the
+// guids, organization_guids, space_guids, app_guids and states parameters.
+func (s *Server) routeV3(path, list string, query url.Values) (int, interface{}) {
+	f := s.Foundation
+	orgOf := make(map[string]string)
+	for _, sp := range f.Spaces {
+		orgOf[sp.GUID] = sp.OrgGUID
+	}
+	spaceOf := make(map[string]string)
+	for _, a := range f.Apps {
+		spaceOf[a.GUID] = a.SpaceGUID
+	}
+
+	var all []v3Resource
+	switch list {
+	case "organizations":
+		for _, o := range f.Orgs {
+			all = append(all, newV3Resource(o.GUID, o.Name, o.GUID, "", o.Labels))
+		}
+	case "spaces":
+		for _, sp := range f.Spaces {
+			all = append(all, newV3Resource(sp.GUID, sp.Name, sp.OrgGUID, sp.GUID, sp.Labels))
+		}
+	case "apps":
+		for _, a := range f.Apps {
+			all = append(all, newV3Resource(a.GUID, a.Name, orgOf[a.SpaceGUID], a.SpaceGUID, a.Labels))
+		}
+	case "tasks":
+		for _, t := range f.Tasks {
+			r := newV3Resource(t.GUID, t.Name, orgOf[spaceOf[t.AppGUID]], spaceOf[t.AppGUID], nil)
+			r.appGUID = t.AppGUID
+			r.State, r.MemoryInMB, r.DiskInMB = t.State, t.Memory, t.Disk
+			r.Relationships = &v3TaskLinks{}
+			r.Relationships.App.Data.GUID = t.AppGUID
+			all = append(all, r)
+		}
+	default:
+		return http.StatusNotFound, notFound
+	}
+
+	filters := map[string]func(r v3Resource) string{
+		"guids":              func(r v3Resource) string { return r.GUID },
+		"organization_guids": func(r v3Resource) string { return r.orgGUID },
+		"space_guids":        func(r v3Resource) string { return r.spaceGUID },
+		"app_guids":          func(r v3Resource) string { return r.appGUID },
+		"states":             func(r v3Resource) string { return r.State },
+	}
+	for param, field := range filters {
+		if query.Get(param) == "" {
+			continue
+		}
+		values := strings.Split(query.Get(param), ",")
+		var matching []v3Resource
+		for _, r := range all {
+			if contains(values, field(r)) {
+				matching = append(matching, r)
+			}
+		}
+		all = matching
+	}
+	return s.v3Page(path, query, all)
+}
+
+// v3Page returns the page of all selected by the page and per_page
+// parameters with the absolute link to the next page.
+func (s *Server) v3Page(path string, query url.Values, all []v3Resource) (int, interface{}) {
+	perPage := s.PageSize
+	if n, err := strconv.Atoi(query.Get("per_page")); err == nil && n > 0 && n < perPage {
+		perPage = n
+	}
+	current := 1
+	if n, err := strconv.Atoi(query.Get("page")); err == nil && n > 0 {
+		current = n
+	}
+
+	totalPages := (len(all) + perPage - 1) / perPage
+	if totalPages == 0 {
+		totalPages = 1
+	}
+	start := (current - 1) * perPage
+	if start > len(all) {
+		start = len(all)
+	}
+	end := start + perPage
+	if end > len(all) {
+		end = len(all)
+	}
+
+	var next interface{}
+	if current < totalPages {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("page", strconv.Itoa(current+1))
+		q.Set("per_page", strconv.Itoa(perPage))
+		next = map[string]string{"href": s.URL + path + "?" + q.Encode()}
+	}
+	return http.StatusOK, map[string]interface{}{
+		"pagination": map[string]interface{}{
+			"total_results": len(all),
+			"total_pages":   totalPages,
+			"next":          next,
+		},
+		"resources": append([]v3Resource{}, all[start:end]...),
+	}
+}
+
+// services returns all services, the fake offers all of them in every org
+// and space.
+func (s *Server) services() []resource {
+	var services []resource
+	for _, svc := range s.Foundation.Services {
+		services = append(services, resource{
+			Metadata: metadata{GUID: svc.GUID, URL: "/v2/services/" + svc.GUID},
+			Entity:   map[string]interface{}{"label": svc.Label},
+		})
+	}
+	return services
+}
+
+// filteredPage returns the page of the resources matching the q filters of
+// query. Filters are field:value or field IN value,value on one of the
+// given entity fields. organization_guid also filters resources by the org
+// of their space. Other filters are rejected like by the Cloud Controller.
+func (s *Server) filteredPage(path string, query url.Values, all []resource, fields ...string) (int, interface{}) {
+	filtered := all
+	for _, q := range query["q"] {
+		field, values := parseFilter(q)
+		if !contains(fields, field) {
+			return http.StatusBadRequest, apiError{1001, "Request invalid due to parse error: invalid query " + q, "CF-MessageParseError"}
+		}
+		var matching []resource
+		for _, r := range filtered {
+			if contains(values, s.entityField(r, field)) {
+				matching = append(matching, r)
+			}
+		}
+		filtered = matching
+	}
+	return s.page(path, query, filtered)
+}
+
+func parseFilter(q string) (string, []string) {
+	if i := strings.Index(q, " IN "); i >= 0 {
+		return q[:i], strings.Split(q[i+len(" IN "):], ",")
+	}
+	if i := strings.Index(q, ":"); i >= 0 {
+		return q[:i], []string{q[i+1:]}
+	}
+	return q, nil
+}
+
+func (s *Server) entityField(r resource, field string) string {
+	entity := r.Entity.(map[string]interface{})
+	if value, ok := entity[field].(string); ok {
+		return value
+	}
+	if field == "organization_guid" {
+		for _, sp := range s.Foundation.Spaces {
+			if sp.GUID == entity["space_guid"] {
+				return sp.OrgGUID
+			}
+		}
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// page returns the page of all selected by the page and results-per-page
+// parameters with the links to the neighbouring pages.
+func (s *Server) page(path string, query url.Values, all []resource) (int, interface{}) {
+	perPage := s.PageSize
+	if n, err := strconv.Atoi(query.Get("results-per-page")); err == nil && n > 0 && n < perPage {
+		perPage = n
+	}
+	current := 1
+	if n, err := strconv.Atoi(query.Get("page")); err == nil && n > 0 {
+		current = n
+	}
+
+	totalPages := (len(all) + perPage - 1) / perPage
+	if totalPages == 0 {
+		totalPages = 1
+	}
+	start := (current - 1) * perPage
+	if start > len(all) {
+		start = len(all)
+	}
+	end := start + perPage
+	if end > len(all) {
+		end = len(all)
+	}
+
+	p := page{
+		TotalResults: len(all),
+		TotalPages:   totalPages,
+		Resources:    append([]resource{}, all[start:end]...),
+	}
+	link := func(n int) *string {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("page", strconv.Itoa(n))
+		q.Set("results-per-page", strconv.Itoa(perPage))
+		u := path + "?" + q.Encode()
+		return &u
+	}
+	if current > 1 {
+		p.PrevURL = link(current - 1)
+	}
+	if current < totalPages {
+		p.NextURL = link(current + 1)
+	}
+	return http.StatusOK, p
+}
+
+func (s *Server) org(guid string) (Org, bool) {
+	for _, o := range s.Foundation.Orgs {
+		if o.GUID == guid {
+			return o, true
+		}
+	}
+	return Org{}, false
+}
+
+// memoryUsage sums up the memory of the instances of the started apps and of
+// the running tasks of an org like the Cloud Controller does.
+func (s *Server) memoryUsage(orgGUID string) int {
+	spaces := make(map[string]bool)
+	for _, sp := range s.Foundation.Spaces {
+		if sp.OrgGUID == orgGUID {
+			spaces[sp.GUID] = true
+		}
+	}
+	usage := 0
+	apps := make(map[string]bool)
+	for _, a := range s.Foundation.Apps {
+		apps[a.GUID] = spaces[a.SpaceGUID]
+		if spaces[a.SpaceGUID] && a.State == "STARTED" {
+			usage += a.Instances * a.Memory
+		}
+	}
+	for _, t := range s.Foundation.Tasks {
+		if apps[t.AppGUID] && t.State == "RUNNING" {
+			usage += t.Memory
+		}
+	}
+	return usage
+}
+
+func orgResource(o Org) resource {
+	entity := map[string]interface{}{
+		"name":                  o.Name,
+		"status":                "active",
+		"quota_definition_guid": nullable(o.QuotaGUID),
+		"quota_definition_url":  nil,
+		"spaces_url":            fmt.Sprintf("/v2/organizations/%s/spaces", o.GUID),
+	}
+	if o.QuotaGUID != "" {
+		entity["quota_definition_url"] = "/v2/quota_definitions/" + o.QuotaGUID
+	}
+	return resource{
+		Metadata: metadata{GUID: o.GUID, URL: "/v2/organizations/" + o.GUID},
+		Entity:   entity,
+	}
+}
+
+func spaceResource(sp Space) resource {
+	return resource{
+		Metadata: metadata{GUID: sp.GUID, URL: "/v2/spaces/" + sp.GUID},
+		Entity: map[string]interface{}{
+			"name":                        sp.Name,
+			"organization_guid":           sp.OrgGUID,
+			"apps_url":                    fmt.Sprintf("/v2/spaces/%s/apps", sp.GUID),
+			"space_quota_definition_guid": nullable(sp.QuotaGUID),
+		},
+	}
+}
+
+func appResource(a App) resource {
+	return resource{
+		Metadata: metadata{GUID: a.GUID, URL: "/v2/apps/" + a.GUID},
+		Entity: map[string]interface{}{
+			"name":                    a.Name,
+			"space_guid":              a.SpaceGUID,
+			"state":                   a.State,
+			"instances":               a.Instances,
+			"memory":                  a.Memory,
+			"disk_quota":              a.Disk,
+			"service_bindings_url":    fmt.Sprintf("/v2/apps/%s/service_bindings", a.GUID),
+			"stack_guid":              nullable(a.StackGUID),
+			"buildpack":               nullable(a.Buildpack),
+			"detected_buildpack_guid": nullable(a.BuildpackGUID),
+			"docker_image":            nullable(a.DockerImage),
+		},
+	}
+}
+
+// appStats returns the stats of the instances of a by index.
+func appStats(a App) map[string]interface{} {
+	const mb = 1024 * 1024
+	stats := make(map[string]interface{}, a.Instances)
+	for i := 0; i < a.Instances; i++ {
+		if i >= len(a.Stats) {
+			stats[strconv.Itoa(i)] = map[string]interface{}{"state": "DOWN"}
+			continue
+		}
+		st := a.Stats[i]
+		stats[strconv.Itoa(i)] = map[string]interface{}{
+			"state": "RUNNING",
+			"stats": map[string]interface{}{
+				"name":       a.Name,
+				"usage":      map[string]interface{}{"mem": st.MemoryMB * mb, "disk": st.DiskMB * mb, "cpu": st.CPU},
+				"mem_quota":  a.Memory * mb,
+				"disk_quota": st.DiskQuotaMB * mb,
+			},
+		}
+	}
+	return stats
+}
+
+func quotaResource(q Quota) resource {
+	entity := map[string]interface{}{
+		"name":         q.Name,
+		"memory_limit": q.MemoryLimit,
+	}
+	for field, limit := range q.Limits {
+		entity[field] = limit
+	}
+	return resource{
+		Metadata: metadata{GUID: q.GUID, URL: "/v2/quota_definitions/" + q.GUID},
+		Entity:   entity,
+	}
+}
+
+func spaceQuotaResource(q SpaceQuota) resource {
+	return resource{
+		Metadata: metadata{GUID: q.GUID, URL: "/v2/space_quota_definitions/" + q.GUID},
+		Entity: map[string]interface{}{
+			"name":              q.Name,
+			"organization_guid": q.OrgGUID,
+			"memory_limit":      q.MemoryLimit,
+		},
+	}
+}
+
+func routeResource(r Route) resource {
+	var port interface{}
+	if r.Port != 0 {
+		port = r.Port
+	}
+	return resource{
+		Metadata: metadata{GUID: r.GUID, URL: "/v2/routes/" + r.GUID},
+		Entity: map[string]interface{}{
+			"space_guid": r.SpaceGUID,
+			"port":       port,
+		},
+	}
+}
+
+func bindingResource(b ServiceBinding) resource {
+	return resource{
+		Metadata: metadata{GUID: b.GUID, URL: "/v2/service_bindings/" + b.GUID},
+		Entity: map[string]interface{}{
+			"app_guid":              b.AppGUID,
+			"service_instance_guid": b.ServiceInstanceGUID,
+		},
+	}
+}
+
+// nullable returns nil for empty strings, which the API renders as null.
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
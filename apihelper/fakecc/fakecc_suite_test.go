@@ -0,0 +1,13 @@
+package fakecc_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestFakecc(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Fakecc Suite")
+}
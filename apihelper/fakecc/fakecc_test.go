@@ -0,0 +1,177 @@
+package fakecc_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/dgruber/usagereport-plugin/apihelper/fakecc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type v2Page struct {
+	TotalResults int     `json:"total_results"`
+	TotalPages   int     `json:"total_pages"`
+	PrevURL      *string `json:"prev_url"`
+	NextURL      *string `json:"next_url"`
+	Resources    []struct {
+		Metadata struct {
+			GUID string `json:"guid"`
+		} `json:"metadata"`
+	} `json:"resources"`
+}
+
+type v3Page struct {
+	Pagination struct {
+		TotalResults int `json:"total_results"`
+		TotalPages   int `json:"total_pages"`
+		Next         *struct {
+			Href string `json:"href"`
+		} `json:"next"`
+	} `json:"pagination"`
+	Resources []struct {
+		GUID string `json:"guid"`
+	} `json:"resources"`
+}
+
+var _ = Describe("Fake Cloud Controller", func() {
+	var server *fakecc.Server
+
+	BeforeEach(func() {
+		server = fakecc.New(fakecc.Foundation{
+			Orgs: []fakecc.Org{
+				{GUID: "o1", Name: "org-1"},
+				{GUID: "o2", Name: "org-2"},
+				{GUID: "o3", Name: "org-3"},
+				{GUID: "o4", Name: "org-4"},
+				{GUID: "o5", Name: "org-5"},
+			},
+			Spaces: []fakecc.Space{
+				{GUID: "s1", Name: "dev", OrgGUID: "o1"},
+				{GUID: "s2", Name: "prod", OrgGUID: "o2"},
+			},
+			Apps: []fakecc.App{
+				{GUID: "a1", Name: "web", SpaceGUID: "s1", State: "STARTED"},
+				{GUID: "a2", Name: "worker", SpaceGUID: "s2", State: "STARTED"},
+				{GUID: "a3", Name: "batch", SpaceGUID: "s2", State: "STOPPED"},
+			},
+		})
+		server.PageSize = 2
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	get := func(uri string, v interface{}) int {
+		resp, err := http.Get(server.URL + uri)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		Expect(json.NewDecoder(resp.Body).Decode(v)).To(Succeed())
+		return resp.StatusCode
+	}
+
+	// v2GUIDs follows the next_url links from uri and returns the guids of
+	// all pages.
+	v2GUIDs := func(uri string) []string {
+		var guids []string
+		for {
+			var p v2Page
+			Expect(get(uri, &p)).To(Equal(http.StatusOK))
+			for _, r := range p.Resources {
+				guids = append(guids, r.Metadata.GUID)
+			}
+			if p.NextURL == nil {
+				return guids
+			}
+			uri = *p.NextURL
+		}
+	}
+
+	Describe("v2 lists", func() {
+		It("pages by the page size with links to the neighbouring pages", func() {
+			var p v2Page
+			get("/v2/organizations", &p)
+			Expect(p.TotalResults).To(Equal(5))
+			Expect(p.TotalPages).To(Equal(3))
+			Expect(p.PrevURL).To(BeNil())
+			Expect(*p.NextURL).To(Equal("/v2/organizations?page=2&results-per-page=2"))
+
+			get(*p.NextURL, &p)
+			Expect(*p.PrevURL).To(Equal("/v2/organizations?page=1&results-per-page=2"))
+			Expect(*p.NextURL).To(Equal("/v2/organizations?page=3&results-per-page=2"))
+
+			Expect(v2GUIDs("/v2/organizations")).To(Equal([]string{"o1", "o2", "o3", "o4", "o5"}))
+		})
+
+		It("honours smaller results-per-page and keeps the query in next_url", func() {
+			var p v2Page
+			get("/v2/apps?results-per-page=1&q=organization_guid:o2", &p)
+			Expect(p.Resources).To(HaveLen(1))
+			Expect(p.TotalPages).To(Equal(2))
+			next, err := url.Parse(*p.NextURL)
+			Expect(err).To(BeNil())
+			Expect(next.Query().Get("q")).To(Equal("organization_guid:o2"))
+
+			get("/v2/organizations?results-per-page=10", &p)
+			Expect(p.Resources).To(HaveLen(2))
+		})
+
+		It("filters by q", func() {
+			Expect(v2GUIDs("/v2/organizations?q=name:org-3")).To(Equal([]string{"o3"}))
+			Expect(v2GUIDs("/v2/organizations?q=" + url.QueryEscape("name IN org-1,org-4,org-9"))).To(Equal([]string{"o1", "o4"}))
+			Expect(v2GUIDs("/v2/apps?q=organization_guid:o2")).To(Equal([]string{"a2", "a3"}))
+			Expect(v2GUIDs("/v2/apps?q=space_guid:s1&q=organization_guid:o2")).To(BeEmpty())
+		})
+
+		It("rejects unknown filters", func() {
+			var body map[string]interface{}
+			Expect(get("/v2/organizations?q=status:active", &body)).To(Equal(http.StatusBadRequest))
+			Expect(body["error_code"]).To(Equal("CF-MessageParseError"))
+		})
+	})
+
+	Describe("v3 lists", func() {
+		It("pages by per_page with an absolute link to the next page", func() {
+			var p v3Page
+			get("/v3/apps?per_page=1&space_guids=s2", &p)
+			Expect(p.Pagination.TotalResults).To(Equal(2))
+			Expect(p.Pagination.TotalPages).To(Equal(2))
+			Expect(p.Resources).To(HaveLen(1))
+			Expect(p.Pagination.Next.Href).To(Equal(server.URL + "/v3/apps?page=2&per_page=1&space_guids=s2"))
+			get(p.Pagination.Next.Href[len(server.URL):], &p)
+			Expect(p.Resources[0].GUID).To(Equal("a3"))
+			Expect(p.Pagination.Next).To(BeNil())
+
+			var guids []string
+			uri := "/v3/organizations"
+			for {
+				var p v3Page
+				Expect(get(uri, &p)).To(Equal(http.StatusOK))
+				for _, r := range p.Resources {
+					guids = append(guids, r.GUID)
+				}
+				if p.Pagination.Next == nil {
+					break
+				}
+				uri = p.Pagination.Next.Href[len(server.URL):]
+			}
+			Expect(guids).To(Equal([]string{"o1", "o2", "o3", "o4", "o5"}))
+		})
+
+		It("filters by guids and the guids of the org and space", func() {
+			var p v3Page
+			get("/v3/organizations?guids=o2,o5", &p)
+			Expect(p.Resources).To(HaveLen(2))
+			Expect(p.Resources[1].GUID).To(Equal("o5"))
+
+			get("/v3/apps?organization_guids=o2", &p)
+			Expect(p.Pagination.TotalResults).To(Equal(2))
+
+			get("/v3/apps?space_guids=s1,s2&guids=a3", &p)
+			Expect(p.Resources).To(HaveLen(1))
+			Expect(p.Resources[0].GUID).To(Equal("a3"))
+		})
+	})
+})
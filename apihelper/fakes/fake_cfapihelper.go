@@ -2,122 +2,281 @@
 package fakes
 
 import (
+	"context"
 	"sync"
 
-	// "github.com/cloudfoundry/cli/plugin"
 	"github.com/dgruber/usagereport-plugin/apihelper"
 )
 
 type FakeCFAPIHelper struct {
-	GetOrgsStub    func() ([]apihelper.Organization, error)
-	getOrgsMutex   sync.RWMutex
+	GetOrgsStub        func(context.Context) ([]apihelper.Organization, error)
+	getOrgsMutex       sync.RWMutex
+	getOrgsArgsForCall []struct {
+		arg1 context.Context
+	}
 	getOrgsReturns struct {
 		result1 []apihelper.Organization
 		result2 error
 	}
-	GetOrgStub    func(string) (apihelper.Organization, error)
-	getOrgMutex   sync.RWMutex
+	GetOrgStub        func(context.Context, string) (apihelper.Organization, error)
+	getOrgMutex       sync.RWMutex
+	getOrgArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
 	getOrgReturns struct {
 		result1 apihelper.Organization
 		result2 error
 	}
-	GetQuotaMemoryLimitStub        func(string) (float64, error)
+	GetQuotaMemoryLimitStub        func(context.Context, string) (float64, error)
 	getQuotaMemoryLimitMutex       sync.RWMutex
 	getQuotaMemoryLimitArgsForCall []struct {
-		arg1 string
+		arg1 context.Context
+		arg2 string
 	}
 	getQuotaMemoryLimitReturns struct {
 		result1 float64
 		result2 error
 	}
-	GetOrgMemoryUsageStub        func(apihelper.Organization) (float64, error)
+	GetOrgMemoryUsageStub        func(context.Context, apihelper.Organization) (float64, error)
 	getOrgMemoryUsageMutex       sync.RWMutex
 	getOrgMemoryUsageArgsForCall []struct {
-		arg1 apihelper.Organization
+		arg1 context.Context
+		arg2 apihelper.Organization
 	}
 	getOrgMemoryUsageReturns struct {
 		result1 float64
 		result2 error
 	}
-	GetOrgSpacesStub        func(string) ([]apihelper.Space, error)
+	GetOrgSpacesStub        func(context.Context, string) ([]apihelper.Space, error)
 	getOrgSpacesMutex       sync.RWMutex
 	getOrgSpacesArgsForCall []struct {
-		arg1 string
+		arg1 context.Context
+		arg2 string
 	}
 	getOrgSpacesReturns struct {
 		result1 []apihelper.Space
 		result2 error
 	}
-	GetSpaceAppsStub        func(string) ([]apihelper.App, error)
+	GetSpaceAppsStub        func(context.Context, string) ([]apihelper.App, error)
 	getSpaceAppsMutex       sync.RWMutex
 	getSpaceAppsArgsForCall []struct {
-		arg1 string
+		arg1 context.Context
+		arg2 string
 	}
 	getSpaceAppsReturns struct {
 		result1 []apihelper.App
 		result2 error
 	}
-	GetServiceBindingsStub    func() ([]apihelper.ServiceBindings, error)
-	getServiceBindingsMutex   sync.RWMutex
+	GetAppsStub        func(context.Context) ([]apihelper.App, error)
+	getAppsMutex       sync.RWMutex
+	getAppsArgsForCall []struct {
+		arg1 context.Context
+	}
+	getAppsReturns struct {
+		result1 []apihelper.App
+		result2 error
+	}
+	GetQuotaMapStub        func(context.Context) (map[string]apihelper.Quota, error)
+	getQuotaMapMutex       sync.RWMutex
+	getQuotaMapArgsForCall []struct {
+		arg1 context.Context
+	}
+	getQuotaMapReturns struct {
+		result1 map[string]apihelper.Quota
+		result2 error
+	}
+	GetSpaceQuotaMapStub        func(context.Context) (map[string]apihelper.Quota, error)
+	getSpaceQuotaMapMutex       sync.RWMutex
+	getSpaceQuotaMapArgsForCall []struct {
+		arg1 context.Context
+	}
+	getSpaceQuotaMapReturns struct {
+		result1 map[string]apihelper.Quota
+		result2 error
+	}
+	GetServiceBindingsStub        func(context.Context, string) ([]apihelper.ServiceBindings, error)
+	getServiceBindingsMutex       sync.RWMutex
+	getServiceBindingsArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
 	getServiceBindingsReturns struct {
 		result1 []apihelper.ServiceBindings
 		result2 error
 	}
-	GetServiceInstanceMapStub    func() (map[string]apihelper.ServiceInstance, error)
-	getServiceInstanceMapMutex   sync.RWMutex
+	GetServiceInstanceMapStub        func(context.Context) (map[string]apihelper.ServiceInstance, error)
+	getServiceInstanceMapMutex       sync.RWMutex
+	getServiceInstanceMapArgsForCall []struct {
+		arg1 context.Context
+	}
 	getServiceInstanceMapReturns struct {
 		result1 map[string]apihelper.ServiceInstance
 		result2 error
 	}
-	GetServiceMapStub    func() (map[string]apihelper.Service, error)
-	getServiceMapMutex   sync.RWMutex
+	GetServiceMapStub        func(context.Context) (map[string]apihelper.Service, error)
+	getServiceMapMutex       sync.RWMutex
+	getServiceMapArgsForCall []struct {
+		arg1 context.Context
+	}
 	getServiceMapReturns struct {
 		result1 map[string]apihelper.Service
 		result2 error
 	}
-	GetServicePlanMapStub    func() (map[string]apihelper.ServicePlan, error)
-	getServicePlanMapMutex   sync.RWMutex
+	GetServicePlanMapStub        func(context.Context) (map[string]apihelper.ServicePlan, error)
+	getServicePlanMapMutex       sync.RWMutex
+	getServicePlanMapArgsForCall []struct {
+		arg1 context.Context
+	}
 	getServicePlanMapReturns struct {
 		result1 map[string]apihelper.ServicePlan
 		result2 error
 	}
-	GetUserProvidedServiceMapStub    func() (map[string]apihelper.UserProvidedService, error)
-	getUserProvidedServiceMapMutex   sync.RWMutex
+	GetUserProvidedServiceMapStub        func(context.Context) (map[string]apihelper.UserProvidedService, error)
+	getUserProvidedServiceMapMutex       sync.RWMutex
+	getUserProvidedServiceMapArgsForCall []struct {
+		arg1 context.Context
+	}
 	getUserProvidedServiceMapReturns struct {
 		result1 map[string]apihelper.UserProvidedService
 		result2 error
 	}
-
-	GetServiceBindingsListStub    func() ([]apihelper.ServiceBinding, error)
-	getServiceBindingsListMutex   sync.RWMutex
+	GetServiceBindingsListStub        func(context.Context) ([]apihelper.ServiceBinding, error)
+	getServiceBindingsListMutex       sync.RWMutex
+	getServiceBindingsListArgsForCall []struct {
+		arg1 context.Context
+	}
 	getServiceBindingsListReturns struct {
 		result1 []apihelper.ServiceBinding
 		result2 error
 	}
-
-	GetSpaceMapStub    func() (map[string]apihelper.SpaceDetails, error)
-	getSpaceMapMutex   sync.RWMutex
+	GetSpacesStub        func(context.Context) ([]apihelper.SpaceDetails, error)
+	getSpacesMutex       sync.RWMutex
+	getSpacesArgsForCall []struct {
+		arg1 context.Context
+	}
+	getSpacesReturns struct {
+		result1 []apihelper.SpaceDetails
+		result2 error
+	}
+	GetSpaceMapStub        func(context.Context) (map[string]apihelper.SpaceDetails, error)
+	getSpaceMapMutex       sync.RWMutex
+	getSpaceMapArgsForCall []struct {
+		arg1 context.Context
+	}
 	getSpaceMapReturns struct {
 		result1 map[string]apihelper.SpaceDetails
 		result2 error
 	}
-
-	GetOrgMapStub    func() (map[string]apihelper.OrgDetails, error)
-	getOrgMapMutex   sync.RWMutex
+	GetOrgMapStub        func(context.Context) (map[string]apihelper.OrgDetails, error)
+	getOrgMapMutex       sync.RWMutex
+	getOrgMapArgsForCall []struct {
+		arg1 context.Context
+	}
 	getOrgMapReturns struct {
 		result1 map[string]apihelper.OrgDetails
 		result2 error
 	}
+	GetLabelsStub        func(context.Context) (apihelper.Labels, error)
+	getLabelsMutex       sync.RWMutex
+	getLabelsArgsForCall []struct {
+		arg1 context.Context
+	}
+	getLabelsReturns struct {
+		result1 apihelper.Labels
+		result2 error
+	}
+	GetRoutesStub        func(context.Context) ([]apihelper.Route, error)
+	getRoutesMutex       sync.RWMutex
+	getRoutesArgsForCall []struct {
+		arg1 context.Context
+	}
+	getRoutesReturns struct {
+		result1 []apihelper.Route
+		result2 error
+	}
+	GetServiceKeysStub        func(context.Context) ([]apihelper.ServiceKey, error)
+	getServiceKeysMutex       sync.RWMutex
+	getServiceKeysArgsForCall []struct {
+		arg1 context.Context
+	}
+	getServiceKeysReturns struct {
+		result1 []apihelper.ServiceKey
+		result2 error
+	}
+	GetAppStatsStub        func(context.Context, string, string) ([]apihelper.InstanceStats, error)
+	getAppStatsMutex       sync.RWMutex
+	getAppStatsArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+	}
+	getAppStatsReturns struct {
+		result1 []apihelper.InstanceStats
+		result2 error
+	}
+	GetAppSidecarsStub        func(context.Context, string) ([]apihelper.Sidecar, error)
+	getAppSidecarsMutex       sync.RWMutex
+	getAppSidecarsArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	getAppSidecarsReturns struct {
+		result1 []apihelper.Sidecar
+		result2 error
+	}
+	GetAppBuildStub        func(context.Context, string) (apihelper.Build, error)
+	getAppBuildMutex       sync.RWMutex
+	getAppBuildArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	getAppBuildReturns struct {
+		result1 apihelper.Build
+		result2 error
+	}
+	GetRunningTasksStub        func(context.Context) ([]apihelper.Task, error)
+	getRunningTasksMutex       sync.RWMutex
+	getRunningTasksArgsForCall []struct {
+		arg1 context.Context
+	}
+	getRunningTasksReturns struct {
+		result1 []apihelper.Task
+		result2 error
+	}
+	GetBuildsStub        func(context.Context) ([]apihelper.Build, error)
+	getBuildsMutex       sync.RWMutex
+	getBuildsArgsForCall []struct {
+		arg1 context.Context
+	}
+	getBuildsReturns struct {
+		result1 []apihelper.Build
+		result2 error
+	}
 }
 
-func (fake *FakeCFAPIHelper) GetOrgs() ([]apihelper.Organization, error) {
+func (fake *FakeCFAPIHelper) GetOrgs(arg1 context.Context) ([]apihelper.Organization, error) {
 	fake.getOrgsMutex.Lock()
+	fake.getOrgsArgsForCall = append(fake.getOrgsArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
 	fake.getOrgsMutex.Unlock()
 	if fake.GetOrgsStub != nil {
-		return fake.GetOrgsStub()
-	} else {
-		return fake.getOrgsReturns.result1, fake.getOrgsReturns.result2
+		return fake.GetOrgsStub(arg1)
 	}
+	return fake.getOrgsReturns.result1, fake.getOrgsReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetOrgsCallCount() int {
+	fake.getOrgsMutex.RLock()
+	defer fake.getOrgsMutex.RUnlock()
+	return len(fake.getOrgsArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetOrgsArgsForCall(i int) context.Context {
+	fake.getOrgsMutex.RLock()
+	defer fake.getOrgsMutex.RUnlock()
+	return fake.getOrgsArgsForCall[i].arg1
 }
 
 func (fake *FakeCFAPIHelper) GetOrgsReturns(result1 []apihelper.Organization, result2 error) {
@@ -128,14 +287,29 @@ func (fake *FakeCFAPIHelper) GetOrgsReturns(result1 []apihelper.Organization, re
 	}{result1, result2}
 }
 
-func (fake *FakeCFAPIHelper) GetOrg(name string) (apihelper.Organization, error) {
+func (fake *FakeCFAPIHelper) GetOrg(arg1 context.Context, arg2 string) (apihelper.Organization, error) {
 	fake.getOrgMutex.Lock()
+	fake.getOrgArgsForCall = append(fake.getOrgArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
 	fake.getOrgMutex.Unlock()
 	if fake.GetOrgStub != nil {
-		return fake.GetOrgStub(name)
-	} else {
-		return fake.getOrgReturns.result1, fake.getOrgReturns.result2
+		return fake.GetOrgStub(arg1, arg2)
 	}
+	return fake.getOrgReturns.result1, fake.getOrgReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetOrgCallCount() int {
+	fake.getOrgMutex.RLock()
+	defer fake.getOrgMutex.RUnlock()
+	return len(fake.getOrgArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetOrgArgsForCall(i int) (context.Context, string) {
+	fake.getOrgMutex.RLock()
+	defer fake.getOrgMutex.RUnlock()
+	return fake.getOrgArgsForCall[i].arg1, fake.getOrgArgsForCall[i].arg2
 }
 
 func (fake *FakeCFAPIHelper) GetOrgReturns(result1 apihelper.Organization, result2 error) {
@@ -146,17 +320,17 @@ func (fake *FakeCFAPIHelper) GetOrgReturns(result1 apihelper.Organization, resul
 	}{result1, result2}
 }
 
-func (fake *FakeCFAPIHelper) GetQuotaMemoryLimit(arg1 string) (float64, error) {
+func (fake *FakeCFAPIHelper) GetQuotaMemoryLimit(arg1 context.Context, arg2 string) (float64, error) {
 	fake.getQuotaMemoryLimitMutex.Lock()
 	fake.getQuotaMemoryLimitArgsForCall = append(fake.getQuotaMemoryLimitArgsForCall, struct {
-		arg1 string
-	}{arg1})
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
 	fake.getQuotaMemoryLimitMutex.Unlock()
 	if fake.GetQuotaMemoryLimitStub != nil {
-		return fake.GetQuotaMemoryLimitStub(arg1)
-	} else {
-		return fake.getQuotaMemoryLimitReturns.result1, fake.getQuotaMemoryLimitReturns.result2
+		return fake.GetQuotaMemoryLimitStub(arg1, arg2)
 	}
+	return fake.getQuotaMemoryLimitReturns.result1, fake.getQuotaMemoryLimitReturns.result2
 }
 
 func (fake *FakeCFAPIHelper) GetQuotaMemoryLimitCallCount() int {
@@ -165,10 +339,10 @@ func (fake *FakeCFAPIHelper) GetQuotaMemoryLimitCallCount() int {
 	return len(fake.getQuotaMemoryLimitArgsForCall)
 }
 
-func (fake *FakeCFAPIHelper) GetQuotaMemoryLimitArgsForCall(i int) string {
+func (fake *FakeCFAPIHelper) GetQuotaMemoryLimitArgsForCall(i int) (context.Context, string) {
 	fake.getQuotaMemoryLimitMutex.RLock()
 	defer fake.getQuotaMemoryLimitMutex.RUnlock()
-	return fake.getQuotaMemoryLimitArgsForCall[i].arg1
+	return fake.getQuotaMemoryLimitArgsForCall[i].arg1, fake.getQuotaMemoryLimitArgsForCall[i].arg2
 }
 
 func (fake *FakeCFAPIHelper) GetQuotaMemoryLimitReturns(result1 float64, result2 error) {
@@ -179,17 +353,17 @@ func (fake *FakeCFAPIHelper) GetQuotaMemoryLimitReturns(result1 float64, result2
 	}{result1, result2}
 }
 
-func (fake *FakeCFAPIHelper) GetOrgMemoryUsage(arg1 apihelper.Organization) (float64, error) {
+func (fake *FakeCFAPIHelper) GetOrgMemoryUsage(arg1 context.Context, arg2 apihelper.Organization) (float64, error) {
 	fake.getOrgMemoryUsageMutex.Lock()
 	fake.getOrgMemoryUsageArgsForCall = append(fake.getOrgMemoryUsageArgsForCall, struct {
-		arg1 apihelper.Organization
-	}{arg1})
+		arg1 context.Context
+		arg2 apihelper.Organization
+	}{arg1, arg2})
 	fake.getOrgMemoryUsageMutex.Unlock()
 	if fake.GetOrgMemoryUsageStub != nil {
-		return fake.GetOrgMemoryUsageStub(arg1)
-	} else {
-		return fake.getOrgMemoryUsageReturns.result1, fake.getOrgMemoryUsageReturns.result2
+		return fake.GetOrgMemoryUsageStub(arg1, arg2)
 	}
+	return fake.getOrgMemoryUsageReturns.result1, fake.getOrgMemoryUsageReturns.result2
 }
 
 func (fake *FakeCFAPIHelper) GetOrgMemoryUsageCallCount() int {
@@ -198,10 +372,10 @@ func (fake *FakeCFAPIHelper) GetOrgMemoryUsageCallCount() int {
 	return len(fake.getOrgMemoryUsageArgsForCall)
 }
 
-func (fake *FakeCFAPIHelper) GetOrgMemoryUsageArgsForCall(i int) apihelper.Organization {
+func (fake *FakeCFAPIHelper) GetOrgMemoryUsageArgsForCall(i int) (context.Context, apihelper.Organization) {
 	fake.getOrgMemoryUsageMutex.RLock()
 	defer fake.getOrgMemoryUsageMutex.RUnlock()
-	return fake.getOrgMemoryUsageArgsForCall[i].arg1
+	return fake.getOrgMemoryUsageArgsForCall[i].arg1, fake.getOrgMemoryUsageArgsForCall[i].arg2
 }
 
 func (fake *FakeCFAPIHelper) GetOrgMemoryUsageReturns(result1 float64, result2 error) {
@@ -212,17 +386,17 @@ func (fake *FakeCFAPIHelper) GetOrgMemoryUsageReturns(result1 float64, result2 e
 	}{result1, result2}
 }
 
-func (fake *FakeCFAPIHelper) GetOrgSpaces(arg1 string) ([]apihelper.Space, error) {
+func (fake *FakeCFAPIHelper) GetOrgSpaces(arg1 context.Context, arg2 string) ([]apihelper.Space, error) {
 	fake.getOrgSpacesMutex.Lock()
 	fake.getOrgSpacesArgsForCall = append(fake.getOrgSpacesArgsForCall, struct {
-		arg1 string
-	}{arg1})
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
 	fake.getOrgSpacesMutex.Unlock()
 	if fake.GetOrgSpacesStub != nil {
-		return fake.GetOrgSpacesStub(arg1)
-	} else {
-		return fake.getOrgSpacesReturns.result1, fake.getOrgSpacesReturns.result2
+		return fake.GetOrgSpacesStub(arg1, arg2)
 	}
+	return fake.getOrgSpacesReturns.result1, fake.getOrgSpacesReturns.result2
 }
 
 func (fake *FakeCFAPIHelper) GetOrgSpacesCallCount() int {
@@ -231,10 +405,10 @@ func (fake *FakeCFAPIHelper) GetOrgSpacesCallCount() int {
 	return len(fake.getOrgSpacesArgsForCall)
 }
 
-func (fake *FakeCFAPIHelper) GetOrgSpacesArgsForCall(i int) string {
+func (fake *FakeCFAPIHelper) GetOrgSpacesArgsForCall(i int) (context.Context, string) {
 	fake.getOrgSpacesMutex.RLock()
 	defer fake.getOrgSpacesMutex.RUnlock()
-	return fake.getOrgSpacesArgsForCall[i].arg1
+	return fake.getOrgSpacesArgsForCall[i].arg1, fake.getOrgSpacesArgsForCall[i].arg2
 }
 
 func (fake *FakeCFAPIHelper) GetOrgSpacesReturns(result1 []apihelper.Space, result2 error) {
@@ -245,17 +419,17 @@ func (fake *FakeCFAPIHelper) GetOrgSpacesReturns(result1 []apihelper.Space, resu
 	}{result1, result2}
 }
 
-func (fake *FakeCFAPIHelper) GetSpaceApps(arg1 string) ([]apihelper.App, error) {
+func (fake *FakeCFAPIHelper) GetSpaceApps(arg1 context.Context, arg2 string) ([]apihelper.App, error) {
 	fake.getSpaceAppsMutex.Lock()
 	fake.getSpaceAppsArgsForCall = append(fake.getSpaceAppsArgsForCall, struct {
-		arg1 string
-	}{arg1})
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
 	fake.getSpaceAppsMutex.Unlock()
 	if fake.GetSpaceAppsStub != nil {
-		return fake.GetSpaceAppsStub(arg1)
-	} else {
-		return fake.getSpaceAppsReturns.result1, fake.getSpaceAppsReturns.result2
+		return fake.GetSpaceAppsStub(arg1, arg2)
 	}
+	return fake.getSpaceAppsReturns.result1, fake.getSpaceAppsReturns.result2
 }
 
 func (fake *FakeCFAPIHelper) GetSpaceAppsCallCount() int {
@@ -264,10 +438,10 @@ func (fake *FakeCFAPIHelper) GetSpaceAppsCallCount() int {
 	return len(fake.getSpaceAppsArgsForCall)
 }
 
-func (fake *FakeCFAPIHelper) GetSpaceAppsArgsForCall(i int) string {
+func (fake *FakeCFAPIHelper) GetSpaceAppsArgsForCall(i int) (context.Context, string) {
 	fake.getSpaceAppsMutex.RLock()
 	defer fake.getSpaceAppsMutex.RUnlock()
-	return fake.getSpaceAppsArgsForCall[i].arg1
+	return fake.getSpaceAppsArgsForCall[i].arg1, fake.getSpaceAppsArgsForCall[i].arg2
 }
 
 func (fake *FakeCFAPIHelper) GetSpaceAppsReturns(result1 []apihelper.App, result2 error) {
@@ -278,52 +452,649 @@ func (fake *FakeCFAPIHelper) GetSpaceAppsReturns(result1 []apihelper.App, result
 	}{result1, result2}
 }
 
-func (fake *FakeCFAPIHelper) GetServiceBindings(url string) ([]apihelper.ServiceBindings, error) {
+func (fake *FakeCFAPIHelper) GetApps(arg1 context.Context) ([]apihelper.App, error) {
+	fake.getAppsMutex.Lock()
+	fake.getAppsArgsForCall = append(fake.getAppsArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getAppsMutex.Unlock()
+	if fake.GetAppsStub != nil {
+		return fake.GetAppsStub(arg1)
+	}
+	return fake.getAppsReturns.result1, fake.getAppsReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetAppsCallCount() int {
+	fake.getAppsMutex.RLock()
+	defer fake.getAppsMutex.RUnlock()
+	return len(fake.getAppsArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetAppsArgsForCall(i int) context.Context {
+	fake.getAppsMutex.RLock()
+	defer fake.getAppsMutex.RUnlock()
+	return fake.getAppsArgsForCall[i].arg1
+}
+
+func (fake *FakeCFAPIHelper) GetAppsReturns(result1 []apihelper.App, result2 error) {
+	fake.GetAppsStub = nil
+	fake.getAppsReturns = struct {
+		result1 []apihelper.App
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetQuotaMap(arg1 context.Context) (map[string]apihelper.Quota, error) {
+	fake.getQuotaMapMutex.Lock()
+	fake.getQuotaMapArgsForCall = append(fake.getQuotaMapArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getQuotaMapMutex.Unlock()
+	if fake.GetQuotaMapStub != nil {
+		return fake.GetQuotaMapStub(arg1)
+	}
+	return fake.getQuotaMapReturns.result1, fake.getQuotaMapReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetQuotaMapCallCount() int {
+	fake.getQuotaMapMutex.RLock()
+	defer fake.getQuotaMapMutex.RUnlock()
+	return len(fake.getQuotaMapArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetQuotaMapArgsForCall(i int) context.Context {
+	fake.getQuotaMapMutex.RLock()
+	defer fake.getQuotaMapMutex.RUnlock()
+	return fake.getQuotaMapArgsForCall[i].arg1
+}
+
+func (fake *FakeCFAPIHelper) GetQuotaMapReturns(result1 map[string]apihelper.Quota, result2 error) {
+	fake.GetQuotaMapStub = nil
+	fake.getQuotaMapReturns = struct {
+		result1 map[string]apihelper.Quota
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetSpaceQuotaMap(arg1 context.Context) (map[string]apihelper.Quota, error) {
+	fake.getSpaceQuotaMapMutex.Lock()
+	fake.getSpaceQuotaMapArgsForCall = append(fake.getSpaceQuotaMapArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getSpaceQuotaMapMutex.Unlock()
+	if fake.GetSpaceQuotaMapStub != nil {
+		return fake.GetSpaceQuotaMapStub(arg1)
+	}
+	return fake.getSpaceQuotaMapReturns.result1, fake.getSpaceQuotaMapReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetSpaceQuotaMapCallCount() int {
+	fake.getSpaceQuotaMapMutex.RLock()
+	defer fake.getSpaceQuotaMapMutex.RUnlock()
+	return len(fake.getSpaceQuotaMapArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetSpaceQuotaMapArgsForCall(i int) context.Context {
+	fake.getSpaceQuotaMapMutex.RLock()
+	defer fake.getSpaceQuotaMapMutex.RUnlock()
+	return fake.getSpaceQuotaMapArgsForCall[i].arg1
+}
+
+func (fake *FakeCFAPIHelper) GetSpaceQuotaMapReturns(result1 map[string]apihelper.Quota, result2 error) {
+	fake.GetSpaceQuotaMapStub = nil
+	fake.getSpaceQuotaMapReturns = struct {
+		result1 map[string]apihelper.Quota
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetServiceBindings(arg1 context.Context, arg2 string) ([]apihelper.ServiceBindings, error) {
+	fake.getServiceBindingsMutex.Lock()
+	fake.getServiceBindingsArgsForCall = append(fake.getServiceBindingsArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	fake.getServiceBindingsMutex.Unlock()
+	if fake.GetServiceBindingsStub != nil {
+		return fake.GetServiceBindingsStub(arg1, arg2)
+	}
+	return fake.getServiceBindingsReturns.result1, fake.getServiceBindingsReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetServiceBindingsCallCount() int {
 	fake.getServiceBindingsMutex.RLock()
 	defer fake.getServiceBindingsMutex.RUnlock()
-	return fake.getServiceBindingsReturns.result1, fake.getServiceBindingsReturns.result2
+	return len(fake.getServiceBindingsArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetServiceBindingsArgsForCall(i int) (context.Context, string) {
+	fake.getServiceBindingsMutex.RLock()
+	defer fake.getServiceBindingsMutex.RUnlock()
+	return fake.getServiceBindingsArgsForCall[i].arg1, fake.getServiceBindingsArgsForCall[i].arg2
+}
+
+func (fake *FakeCFAPIHelper) GetServiceBindingsReturns(result1 []apihelper.ServiceBindings, result2 error) {
+	fake.GetServiceBindingsStub = nil
+	fake.getServiceBindingsReturns = struct {
+		result1 []apihelper.ServiceBindings
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetServiceInstanceMap(arg1 context.Context) (map[string]apihelper.ServiceInstance, error) {
+	fake.getServiceInstanceMapMutex.Lock()
+	fake.getServiceInstanceMapArgsForCall = append(fake.getServiceInstanceMapArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getServiceInstanceMapMutex.Unlock()
+	if fake.GetServiceInstanceMapStub != nil {
+		return fake.GetServiceInstanceMapStub(arg1)
+	}
+	return fake.getServiceInstanceMapReturns.result1, fake.getServiceInstanceMapReturns.result2
 }
 
-func (fake *FakeCFAPIHelper) GetServiceInstanceMap() (map[string]apihelper.ServiceInstance, error) {
+func (fake *FakeCFAPIHelper) GetServiceInstanceMapCallCount() int {
 	fake.getServiceInstanceMapMutex.RLock()
 	defer fake.getServiceInstanceMapMutex.RUnlock()
-	return fake.getServiceInstanceMapReturns.result1, fake.getServiceInstanceMapReturns.result2
+	return len(fake.getServiceInstanceMapArgsForCall)
 }
 
-func (fake *FakeCFAPIHelper) GetServiceMap() (map[string]apihelper.Service, error) {
+func (fake *FakeCFAPIHelper) GetServiceInstanceMapArgsForCall(i int) context.Context {
+	fake.getServiceInstanceMapMutex.RLock()
+	defer fake.getServiceInstanceMapMutex.RUnlock()
+	return fake.getServiceInstanceMapArgsForCall[i].arg1
+}
+
+func (fake *FakeCFAPIHelper) GetServiceInstanceMapReturns(result1 map[string]apihelper.ServiceInstance, result2 error) {
+	fake.GetServiceInstanceMapStub = nil
+	fake.getServiceInstanceMapReturns = struct {
+		result1 map[string]apihelper.ServiceInstance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetServiceMap(arg1 context.Context) (map[string]apihelper.Service, error) {
+	fake.getServiceMapMutex.Lock()
+	fake.getServiceMapArgsForCall = append(fake.getServiceMapArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getServiceMapMutex.Unlock()
+	if fake.GetServiceMapStub != nil {
+		return fake.GetServiceMapStub(arg1)
+	}
+	return fake.getServiceMapReturns.result1, fake.getServiceMapReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetServiceMapCallCount() int {
 	fake.getServiceMapMutex.RLock()
 	defer fake.getServiceMapMutex.RUnlock()
-	return fake.getServiceMapReturns.result1, fake.getServiceMapReturns.result2
+	return len(fake.getServiceMapArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetServiceMapArgsForCall(i int) context.Context {
+	fake.getServiceMapMutex.RLock()
+	defer fake.getServiceMapMutex.RUnlock()
+	return fake.getServiceMapArgsForCall[i].arg1
+}
+
+func (fake *FakeCFAPIHelper) GetServiceMapReturns(result1 map[string]apihelper.Service, result2 error) {
+	fake.GetServiceMapStub = nil
+	fake.getServiceMapReturns = struct {
+		result1 map[string]apihelper.Service
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetServicePlanMap(arg1 context.Context) (map[string]apihelper.ServicePlan, error) {
+	fake.getServicePlanMapMutex.Lock()
+	fake.getServicePlanMapArgsForCall = append(fake.getServicePlanMapArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getServicePlanMapMutex.Unlock()
+	if fake.GetServicePlanMapStub != nil {
+		return fake.GetServicePlanMapStub(arg1)
+	}
+	return fake.getServicePlanMapReturns.result1, fake.getServicePlanMapReturns.result2
 }
 
-func (fake *FakeCFAPIHelper) GetServicePlanMap() (map[string]apihelper.ServicePlan, error) {
+func (fake *FakeCFAPIHelper) GetServicePlanMapCallCount() int {
 	fake.getServicePlanMapMutex.RLock()
 	defer fake.getServicePlanMapMutex.RUnlock()
-	return fake.getServicePlanMapReturns.result1, fake.getServicePlanMapReturns.result2
+	return len(fake.getServicePlanMapArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetServicePlanMapArgsForCall(i int) context.Context {
+	fake.getServicePlanMapMutex.RLock()
+	defer fake.getServicePlanMapMutex.RUnlock()
+	return fake.getServicePlanMapArgsForCall[i].arg1
+}
+
+func (fake *FakeCFAPIHelper) GetServicePlanMapReturns(result1 map[string]apihelper.ServicePlan, result2 error) {
+	fake.GetServicePlanMapStub = nil
+	fake.getServicePlanMapReturns = struct {
+		result1 map[string]apihelper.ServicePlan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetUserProvidedServiceMap(arg1 context.Context) (map[string]apihelper.UserProvidedService, error) {
+	fake.getUserProvidedServiceMapMutex.Lock()
+	fake.getUserProvidedServiceMapArgsForCall = append(fake.getUserProvidedServiceMapArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getUserProvidedServiceMapMutex.Unlock()
+	if fake.GetUserProvidedServiceMapStub != nil {
+		return fake.GetUserProvidedServiceMapStub(arg1)
+	}
+	return fake.getUserProvidedServiceMapReturns.result1, fake.getUserProvidedServiceMapReturns.result2
 }
 
-func (fake *FakeCFAPIHelper) GetUserProvidedServiceMap() (map[string]apihelper.UserProvidedService, error) {
+func (fake *FakeCFAPIHelper) GetUserProvidedServiceMapCallCount() int {
 	fake.getUserProvidedServiceMapMutex.RLock()
 	defer fake.getUserProvidedServiceMapMutex.RUnlock()
-	return fake.getUserProvidedServiceMapReturns.result1, fake.getUserProvidedServiceMapReturns.result2
+	return len(fake.getUserProvidedServiceMapArgsForCall)
 }
 
-func (fake *FakeCFAPIHelper) GetServiceBindingsList() ([]apihelper.ServiceBinding, error) {
+func (fake *FakeCFAPIHelper) GetUserProvidedServiceMapArgsForCall(i int) context.Context {
+	fake.getUserProvidedServiceMapMutex.RLock()
+	defer fake.getUserProvidedServiceMapMutex.RUnlock()
+	return fake.getUserProvidedServiceMapArgsForCall[i].arg1
+}
+
+func (fake *FakeCFAPIHelper) GetUserProvidedServiceMapReturns(result1 map[string]apihelper.UserProvidedService, result2 error) {
+	fake.GetUserProvidedServiceMapStub = nil
+	fake.getUserProvidedServiceMapReturns = struct {
+		result1 map[string]apihelper.UserProvidedService
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetServiceBindingsList(arg1 context.Context) ([]apihelper.ServiceBinding, error) {
+	fake.getServiceBindingsListMutex.Lock()
+	fake.getServiceBindingsListArgsForCall = append(fake.getServiceBindingsListArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getServiceBindingsListMutex.Unlock()
+	if fake.GetServiceBindingsListStub != nil {
+		return fake.GetServiceBindingsListStub(arg1)
+	}
+	return fake.getServiceBindingsListReturns.result1, fake.getServiceBindingsListReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetServiceBindingsListCallCount() int {
 	fake.getServiceBindingsListMutex.RLock()
 	defer fake.getServiceBindingsListMutex.RUnlock()
-	return fake.getServiceBindingsListReturns.result1, fake.getServiceBindingsListReturns.result2
+	return len(fake.getServiceBindingsListArgsForCall)
 }
 
-func (fake *FakeCFAPIHelper) GetSpaceMap() (map[string]apihelper.SpaceDetails, error) {
+func (fake *FakeCFAPIHelper) GetServiceBindingsListArgsForCall(i int) context.Context {
+	fake.getServiceBindingsListMutex.RLock()
+	defer fake.getServiceBindingsListMutex.RUnlock()
+	return fake.getServiceBindingsListArgsForCall[i].arg1
+}
+
+func (fake *FakeCFAPIHelper) GetServiceBindingsListReturns(result1 []apihelper.ServiceBinding, result2 error) {
+	fake.GetServiceBindingsListStub = nil
+	fake.getServiceBindingsListReturns = struct {
+		result1 []apihelper.ServiceBinding
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetSpaces(arg1 context.Context) ([]apihelper.SpaceDetails, error) {
+	fake.getSpacesMutex.Lock()
+	fake.getSpacesArgsForCall = append(fake.getSpacesArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getSpacesMutex.Unlock()
+	if fake.GetSpacesStub != nil {
+		return fake.GetSpacesStub(arg1)
+	}
+	return fake.getSpacesReturns.result1, fake.getSpacesReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetSpacesCallCount() int {
+	fake.getSpacesMutex.RLock()
+	defer fake.getSpacesMutex.RUnlock()
+	return len(fake.getSpacesArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetSpacesArgsForCall(i int) context.Context {
+	fake.getSpacesMutex.RLock()
+	defer fake.getSpacesMutex.RUnlock()
+	return fake.getSpacesArgsForCall[i].arg1
+}
+
+func (fake *FakeCFAPIHelper) GetSpacesReturns(result1 []apihelper.SpaceDetails, result2 error) {
+	fake.GetSpacesStub = nil
+	fake.getSpacesReturns = struct {
+		result1 []apihelper.SpaceDetails
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetSpaceMap(arg1 context.Context) (map[string]apihelper.SpaceDetails, error) {
+	fake.getSpaceMapMutex.Lock()
+	fake.getSpaceMapArgsForCall = append(fake.getSpaceMapArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getSpaceMapMutex.Unlock()
+	if fake.GetSpaceMapStub != nil {
+		return fake.GetSpaceMapStub(arg1)
+	}
+	return fake.getSpaceMapReturns.result1, fake.getSpaceMapReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetSpaceMapCallCount() int {
 	fake.getSpaceMapMutex.RLock()
 	defer fake.getSpaceMapMutex.RUnlock()
-	return fake.getSpaceMapReturns.result1, fake.getSpaceMapReturns.result2
+	return len(fake.getSpaceMapArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetSpaceMapArgsForCall(i int) context.Context {
+	fake.getSpaceMapMutex.RLock()
+	defer fake.getSpaceMapMutex.RUnlock()
+	return fake.getSpaceMapArgsForCall[i].arg1
+}
+
+func (fake *FakeCFAPIHelper) GetSpaceMapReturns(result1 map[string]apihelper.SpaceDetails, result2 error) {
+	fake.GetSpaceMapStub = nil
+	fake.getSpaceMapReturns = struct {
+		result1 map[string]apihelper.SpaceDetails
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetOrgMap(arg1 context.Context) (map[string]apihelper.OrgDetails, error) {
+	fake.getOrgMapMutex.Lock()
+	fake.getOrgMapArgsForCall = append(fake.getOrgMapArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getOrgMapMutex.Unlock()
+	if fake.GetOrgMapStub != nil {
+		return fake.GetOrgMapStub(arg1)
+	}
+	return fake.getOrgMapReturns.result1, fake.getOrgMapReturns.result2
 }
 
-func (fake *FakeCFAPIHelper) GetOrgMap() (map[string]apihelper.OrgDetails, error) {
+func (fake *FakeCFAPIHelper) GetOrgMapCallCount() int {
 	fake.getOrgMapMutex.RLock()
 	defer fake.getOrgMapMutex.RUnlock()
-	return fake.getOrgMapReturns.result1, fake.getOrgMapReturns.result2
+	return len(fake.getOrgMapArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetOrgMapArgsForCall(i int) context.Context {
+	fake.getOrgMapMutex.RLock()
+	defer fake.getOrgMapMutex.RUnlock()
+	return fake.getOrgMapArgsForCall[i].arg1
+}
+
+func (fake *FakeCFAPIHelper) GetOrgMapReturns(result1 map[string]apihelper.OrgDetails, result2 error) {
+	fake.GetOrgMapStub = nil
+	fake.getOrgMapReturns = struct {
+		result1 map[string]apihelper.OrgDetails
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetLabels(arg1 context.Context) (apihelper.Labels, error) {
+	fake.getLabelsMutex.Lock()
+	fake.getLabelsArgsForCall = append(fake.getLabelsArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getLabelsMutex.Unlock()
+	if fake.GetLabelsStub != nil {
+		return fake.GetLabelsStub(arg1)
+	}
+	return fake.getLabelsReturns.result1, fake.getLabelsReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetLabelsCallCount() int {
+	fake.getLabelsMutex.RLock()
+	defer fake.getLabelsMutex.RUnlock()
+	return len(fake.getLabelsArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetLabelsArgsForCall(i int) context.Context {
+	fake.getLabelsMutex.RLock()
+	defer fake.getLabelsMutex.RUnlock()
+	return fake.getLabelsArgsForCall[i].arg1
+}
+
+func (fake *FakeCFAPIHelper) GetLabelsReturns(result1 apihelper.Labels, result2 error) {
+	fake.GetLabelsStub = nil
+	fake.getLabelsReturns = struct {
+		result1 apihelper.Labels
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetRoutes(arg1 context.Context) ([]apihelper.Route, error) {
+	fake.getRoutesMutex.Lock()
+	fake.getRoutesArgsForCall = append(fake.getRoutesArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getRoutesMutex.Unlock()
+	if fake.GetRoutesStub != nil {
+		return fake.GetRoutesStub(arg1)
+	}
+	return fake.getRoutesReturns.result1, fake.getRoutesReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetRoutesCallCount() int {
+	fake.getRoutesMutex.RLock()
+	defer fake.getRoutesMutex.RUnlock()
+	return len(fake.getRoutesArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetRoutesArgsForCall(i int) context.Context {
+	fake.getRoutesMutex.RLock()
+	defer fake.getRoutesMutex.RUnlock()
+	return fake.getRoutesArgsForCall[i].arg1
+}
+
+func (fake *FakeCFAPIHelper) GetRoutesReturns(result1 []apihelper.Route, result2 error) {
+	fake.GetRoutesStub = nil
+	fake.getRoutesReturns = struct {
+		result1 []apihelper.Route
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetServiceKeys(arg1 context.Context) ([]apihelper.ServiceKey, error) {
+	fake.getServiceKeysMutex.Lock()
+	fake.getServiceKeysArgsForCall = append(fake.getServiceKeysArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getServiceKeysMutex.Unlock()
+	if fake.GetServiceKeysStub != nil {
+		return fake.GetServiceKeysStub(arg1)
+	}
+	return fake.getServiceKeysReturns.result1, fake.getServiceKeysReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetServiceKeysCallCount() int {
+	fake.getServiceKeysMutex.RLock()
+	defer fake.getServiceKeysMutex.RUnlock()
+	return len(fake.getServiceKeysArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetServiceKeysArgsForCall(i int) context.Context {
+	fake.getServiceKeysMutex.RLock()
+	defer fake.getServiceKeysMutex.RUnlock()
+	return fake.getServiceKeysArgsForCall[i].arg1
+}
+
+func (fake *FakeCFAPIHelper) GetServiceKeysReturns(result1 []apihelper.ServiceKey, result2 error) {
+	fake.GetServiceKeysStub = nil
+	fake.getServiceKeysReturns = struct {
+		result1 []apihelper.ServiceKey
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetAppStats(arg1 context.Context, arg2 string, arg3 string) ([]apihelper.InstanceStats, error) {
+	fake.getAppStatsMutex.Lock()
+	fake.getAppStatsArgsForCall = append(fake.getAppStatsArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+	}{arg1, arg2, arg3})
+	fake.getAppStatsMutex.Unlock()
+	if fake.GetAppStatsStub != nil {
+		return fake.GetAppStatsStub(arg1, arg2, arg3)
+	}
+	return fake.getAppStatsReturns.result1, fake.getAppStatsReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetAppStatsCallCount() int {
+	fake.getAppStatsMutex.RLock()
+	defer fake.getAppStatsMutex.RUnlock()
+	return len(fake.getAppStatsArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetAppStatsArgsForCall(i int) (context.Context, string, string) {
+	fake.getAppStatsMutex.RLock()
+	defer fake.getAppStatsMutex.RUnlock()
+	return fake.getAppStatsArgsForCall[i].arg1, fake.getAppStatsArgsForCall[i].arg2, fake.getAppStatsArgsForCall[i].arg3
+}
+
+func (fake *FakeCFAPIHelper) GetAppStatsReturns(result1 []apihelper.InstanceStats, result2 error) {
+	fake.GetAppStatsStub = nil
+	fake.getAppStatsReturns = struct {
+		result1 []apihelper.InstanceStats
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetAppSidecars(arg1 context.Context, arg2 string) ([]apihelper.Sidecar, error) {
+	fake.getAppSidecarsMutex.Lock()
+	fake.getAppSidecarsArgsForCall = append(fake.getAppSidecarsArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	fake.getAppSidecarsMutex.Unlock()
+	if fake.GetAppSidecarsStub != nil {
+		return fake.GetAppSidecarsStub(arg1, arg2)
+	}
+	return fake.getAppSidecarsReturns.result1, fake.getAppSidecarsReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetAppSidecarsCallCount() int {
+	fake.getAppSidecarsMutex.RLock()
+	defer fake.getAppSidecarsMutex.RUnlock()
+	return len(fake.getAppSidecarsArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetAppSidecarsArgsForCall(i int) (context.Context, string) {
+	fake.getAppSidecarsMutex.RLock()
+	defer fake.getAppSidecarsMutex.RUnlock()
+	return fake.getAppSidecarsArgsForCall[i].arg1, fake.getAppSidecarsArgsForCall[i].arg2
+}
+
+func (fake *FakeCFAPIHelper) GetAppSidecarsReturns(result1 []apihelper.Sidecar, result2 error) {
+	fake.GetAppSidecarsStub = nil
+	fake.getAppSidecarsReturns = struct {
+		result1 []apihelper.Sidecar
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetAppBuild(arg1 context.Context, arg2 string) (apihelper.Build, error) {
+	fake.getAppBuildMutex.Lock()
+	fake.getAppBuildArgsForCall = append(fake.getAppBuildArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	fake.getAppBuildMutex.Unlock()
+	if fake.GetAppBuildStub != nil {
+		return fake.GetAppBuildStub(arg1, arg2)
+	}
+	return fake.getAppBuildReturns.result1, fake.getAppBuildReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetAppBuildCallCount() int {
+	fake.getAppBuildMutex.RLock()
+	defer fake.getAppBuildMutex.RUnlock()
+	return len(fake.getAppBuildArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetAppBuildArgsForCall(i int) (context.Context, string) {
+	fake.getAppBuildMutex.RLock()
+	defer fake.getAppBuildMutex.RUnlock()
+	return fake.getAppBuildArgsForCall[i].arg1, fake.getAppBuildArgsForCall[i].arg2
+}
+
+func (fake *FakeCFAPIHelper) GetAppBuildReturns(result1 apihelper.Build, result2 error) {
+	fake.GetAppBuildStub = nil
+	fake.getAppBuildReturns = struct {
+		result1 apihelper.Build
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetRunningTasks(arg1 context.Context) ([]apihelper.Task, error) {
+	fake.getRunningTasksMutex.Lock()
+	fake.getRunningTasksArgsForCall = append(fake.getRunningTasksArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getRunningTasksMutex.Unlock()
+	if fake.GetRunningTasksStub != nil {
+		return fake.GetRunningTasksStub(arg1)
+	}
+	return fake.getRunningTasksReturns.result1, fake.getRunningTasksReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetRunningTasksCallCount() int {
+	fake.getRunningTasksMutex.RLock()
+	defer fake.getRunningTasksMutex.RUnlock()
+	return len(fake.getRunningTasksArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetRunningTasksArgsForCall(i int) context.Context {
+	fake.getRunningTasksMutex.RLock()
+	defer fake.getRunningTasksMutex.RUnlock()
+	return fake.getRunningTasksArgsForCall[i].arg1
+}
+
+func (fake *FakeCFAPIHelper) GetRunningTasksReturns(result1 []apihelper.Task, result2 error) {
+	fake.GetRunningTasksStub = nil
+	fake.getRunningTasksReturns = struct {
+		result1 []apihelper.Task
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCFAPIHelper) GetBuilds(arg1 context.Context) ([]apihelper.Build, error) {
+	fake.getBuildsMutex.Lock()
+	fake.getBuildsArgsForCall = append(fake.getBuildsArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getBuildsMutex.Unlock()
+	if fake.GetBuildsStub != nil {
+		return fake.GetBuildsStub(arg1)
+	}
+	return fake.getBuildsReturns.result1, fake.getBuildsReturns.result2
+}
+
+func (fake *FakeCFAPIHelper) GetBuildsCallCount() int {
+	fake.getBuildsMutex.RLock()
+	defer fake.getBuildsMutex.RUnlock()
+	return len(fake.getBuildsArgsForCall)
+}
+
+func (fake *FakeCFAPIHelper) GetBuildsArgsForCall(i int) context.Context {
+	fake.getBuildsMutex.RLock()
+	defer fake.getBuildsMutex.RUnlock()
+	return fake.getBuildsArgsForCall[i].arg1
+}
+
+func (fake *FakeCFAPIHelper) GetBuildsReturns(result1 []apihelper.Build, result2 error) {
+	fake.GetBuildsStub = nil
+	fake.getBuildsReturns = struct {
+		result1 []apihelper.Build
+		result2 error
+	}{result1, result2}
 }
 
 var _ apihelper.CFAPIHelper = new(FakeCFAPIHelper)
@@ -0,0 +1,64 @@
+package apihelper
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Labels are the metadata labels of the orgs, spaces and apps of the scope,
+// each by the GUID of the resource. Resources without labels are missing.
+type Labels struct {
+	Orgs   map[string]map[string]string
+	Spaces map[string]map[string]string
+	Apps   map[string]map[string]string
+}
+
+// v3Metadata is the part of a v3 resource holding its labels.
+type v3Metadata struct {
+	GUID     string `json:"guid"`
+	Metadata struct {
+		Labels map[string]string `json:"labels"`
+	} `json:"metadata"`
+}
+
+// GetLabels returns the labels of the orgs, spaces and apps. The v2 API
+// knows no metadata, they are read from the v3 API every foundation with
+// labels serves as well.
+func (api *APIHelper) GetLabels(ctx context.Context) (Labels, error) {
+	v3 := &APIHelperV3{transport: api.transport, perPage: DefaultPerPage, scope: api.scope}
+	return v3.GetLabels(ctx)
+}
+
+// GetLabels returns the labels of the orgs, spaces and apps.
+func (api *APIHelperV3) GetLabels(ctx context.Context) (Labels, error) {
+	var labels Labels
+	var err error
+	if labels.Orgs, err = api.getLabels(ctx, api.scope.v3List("/v3/organizations", "guids", ""), "organization"); nil != err {
+		return labels, err
+	}
+	if labels.Spaces, err = api.getLabels(ctx, api.scope.v3List("/v3/spaces", "organization_guids", "guids"), "space"); nil != err {
+		return labels, err
+	}
+	labels.Apps, err = api.getLabels(ctx, api.scope.v3List("/v3/apps", "organization_guids", "space_guids"), "app")
+	return labels, err
+}
+
+// getLabels maps the GUIDs of the resources of the v3 list at path to
+// their labels.
+func (api *APIHelperV3) getLabels(ctx context.Context, path, resource string) (map[string]map[string]string, error) {
+	labels := make(map[string]map[string]string)
+	err := api.getAllPages(ctx, path, resource, func(raw json.RawMessage) error {
+		var r v3Metadata
+		if err := decodeResource(resource, raw, &r); nil != err {
+			return err
+		}
+		if len(r.Metadata.Labels) > 0 {
+			labels[r.GUID] = r.Metadata.Labels
+		}
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return labels, nil
+}
@@ -0,0 +1,66 @@
+package apihelper
+
+import (
+	"github.com/cloudfoundry/cli/plugin/pluginfakes"
+	"github.com/dgruber/usagereport-plugin/apihelper/fakecc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Labels", func() {
+	var server *fakecc.Server
+
+	helper := func(version string, scope Scope) CFAPIHelper {
+		fakeCliConnection := &pluginfakes.FakeCliConnection{}
+		fakeCliConnection.ApiEndpointReturns(server.URL, nil)
+		fakeCliConnection.AccessTokenReturns("bearer fake-token", nil)
+		transport, err := NewHTTPTransport(fakeCliConnection)
+		Expect(err).To(BeNil())
+		api, err := NewScopedForAPIVersion(ctx, transport, version, scope)
+		Expect(err).To(BeNil())
+		return api
+	}
+
+	BeforeEach(func() {
+		server = fakecc.New(fakecc.Foundation{
+			Orgs: []fakecc.Org{
+				{GUID: "o-1", Name: "org-1", Labels: map[string]string{"team": "payments"}},
+				{GUID: "o-2", Name: "org-2"},
+			},
+			Spaces: []fakecc.Space{
+				{GUID: "s-1", Name: "prod", OrgGUID: "o-1", Labels: map[string]string{"env": "prod"}},
+				{GUID: "s-2", Name: "dev", OrgGUID: "o-2", Labels: map[string]string{"env": "dev"}},
+			},
+			Apps: []fakecc.App{
+				{GUID: "a-1", Name: "web", SpaceGUID: "s-1", Labels: map[string]string{"tier": "web"}},
+				{GUID: "a-2", Name: "batch", SpaceGUID: "s-1"},
+				{GUID: "a-3", Name: "web", SpaceGUID: "s-2", Labels: map[string]string{"tier": "web"}},
+			},
+		})
+		server.PageSize = 1
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("reads the labels from the v3 API for v2 as well", func() {
+		for _, version := range []string{APIVersionV2, APIVersionV3} {
+			labels, err := helper(version, Scope{}).GetLabels(ctx)
+			Expect(err).To(BeNil())
+			Expect(labels).To(Equal(Labels{
+				Orgs:   map[string]map[string]string{"o-1": {"team": "payments"}},
+				Spaces: map[string]map[string]string{"s-1": {"env": "prod"}, "s-2": {"env": "dev"}},
+				Apps:   map[string]map[string]string{"a-1": {"tier": "web"}, "a-3": {"tier": "web"}},
+			}), version)
+		}
+	})
+
+	It("reads only the labels of the scope", func() {
+		labels, err := helper(APIVersionV2, Scope{OrgGUID: "o-1"}).GetLabels(ctx)
+		Expect(err).To(BeNil())
+		Expect(labels.Spaces).To(HaveLen(1))
+		Expect(labels.Apps).To(Equal(map[string]map[string]string{"a-1": {"tier": "web"}}))
+		Expect(server.Requests()).To(ContainElement("/v3/apps?organization_guids=o-1&per_page=5000"))
+	})
+})
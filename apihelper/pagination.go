@@ -0,0 +1,79 @@
+package apihelper
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultResultsPerPage is the page size requested from v2 list endpoints.
+// 100 is the maximum the Cloud Controller accepts.
+const DefaultResultsPerPage = 100
+
+// PageError is returned when a page of a paginated list can not be fetched
+// or parsed. The resources of the pages fetched before are dropped so that
+// a report is never silently built on a truncated list.
+type PageError struct {
+	Path       string
+	Page       int
+	TotalPages int
+	Err        error
+}
+
+func (e *PageError) Error() string {
+	if e.TotalPages > 0 {
+		return fmt.Sprintf("fetching page %d of %d (%s) failed: %v", e.Page, e.TotalPages, e.Path, e.Err)
+	}
+	return fmt.Sprintf("fetching page %d (%s) failed: %v", e.Page, e.Path, e.Err)
+}
+
+// withPageSize adds the page size parameter param to the path unless the
+// caller has already set it.
+func withPageSize(path, param string, size int) string {
+	if size <= 0 {
+		return path
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return path
+	}
+	if u.Query().Get(param) != "" {
+		return path
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + param + "=" + strconv.Itoa(size)
+}
+
+// getAllPages fetches the list at path and follows next_url until the last
+// page was processed. fn is called once for every resource in the list. An
+// error on any page, including the ones after the first, aborts the
+// iteration and is returned as *PageError.
+func (api *APIHelper) getAllPages(ctx context.Context, path, resource string, fn func(r v2Resource) error) error {
+	next := withPageSize(path, "results-per-page", api.resultsPerPage)
+
+	for page := 1; next != ""; page++ {
+		var p v2Page
+		if err := getJSON(ctx, api.transport, next, resource+" list", &p); err != nil {
+			return &PageError{Path: next, Page: page, Err: err}
+		}
+
+		for _, r := range p.Resources {
+			if err := fn(r); err != nil {
+				return &PageError{Path: next, Page: page, TotalPages: p.TotalPages, Err: err}
+			}
+		}
+
+		// a missing next_url marks the last page; stop as well once
+		// total_pages is reached to not loop on a misbehaving endpoint
+		next = p.NextURL
+		if p.TotalPages > 0 && page >= p.TotalPages {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package apihelper
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+)
+
+// webProcess is the type of the process serving the routes of an app, the
+// only one v2 knows.
+const webProcess = "web"
+
+// Process is a process type of an app with its own number of instances.
+// Memory and disk are in MB per instance.
+type Process struct {
+	Type      string
+	Instances float64
+	RAM       float64
+	Disk      float64
+}
+
+// Sidecar runs next to the instances of the process types of an app. Its
+// memory is part of the memory of the processes, 0 when it is not limited
+// separately.
+type Sidecar struct {
+	Name         string
+	RAM          float64
+	ProcessTypes []string
+}
+
+type v3Sidecar struct {
+	GUID         string   `json:"guid"`
+	Name         string   `json:"name"`
+	MemoryInMB   *float64 `json:"memory_in_mb"`
+	ProcessTypes []string `json:"process_types"`
+}
+
+// AllProcesses returns the process types of the app, or its web process
+// if they are unknown.
+func (a App) AllProcesses() []Process {
+	if len(a.Processes) > 0 {
+		return a.Processes
+	}
+	return []Process{{Type: webProcess, Instances: a.Instances, RAM: a.RAM, Disk: a.Disk}}
+}
+
+// sortProcesses orders the process types web first and the others by type.
+func sortProcesses(processes []Process) {
+	sort.Slice(processes, func(i, j int) bool {
+		if (processes[i].Type == webProcess) != (processes[j].Type == webProcess) {
+			return processes[i].Type == webProcess
+		}
+		return processes[i].Type < processes[j].Type
+	})
+}
+
+// GetAppSidecars returns no sidecars, v2 does not know them.
+func (api *APIHelper) GetAppSidecars(ctx context.Context, appGUID string) ([]Sidecar, error) {
+	return nil, nil
+}
+
+// GetAppSidecars returns the sidecars of the app.
+func (api *APIHelperV3) GetAppSidecars(ctx context.Context, appGUID string) ([]Sidecar, error) {
+	sidecars := []Sidecar{}
+	err := api.getAllPages(ctx, "/v3/apps/"+appGUID+"/sidecars", "sidecar", func(raw json.RawMessage) error {
+		var s v3Sidecar
+		if err := decodeResource("sidecar", raw, &s); nil != err {
+			return err
+		}
+		if err := requireFields("sidecar", s.GUID,
+			"name", s.Name); nil != err {
+			return err
+		}
+		sidecars = append(sidecars, Sidecar{Name: s.Name, RAM: valueOf(s.MemoryInMB), ProcessTypes: s.ProcessTypes})
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return sidecars, nil
+}
@@ -0,0 +1,168 @@
+package apihelper
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// Route counts against the routes of the org quota, a route with a Port
+// against its reserved route ports as well. Port is 0 for HTTP routes.
+type Route struct {
+	GUID      string
+	SpaceGUID string
+	Port      int
+}
+
+// ServiceKey counts against the service keys of the org quota of the space
+// of its service instance.
+type ServiceKey struct {
+	GUID                string
+	ServiceInstanceGUID string
+}
+
+type v2RouteEntity struct {
+	SpaceGUID string   `json:"space_guid"`
+	Port      *float64 `json:"port"`
+}
+
+type v2ServiceKeyEntity struct {
+	ServiceInstanceGUID string `json:"service_instance_guid"`
+}
+
+type v3Route struct {
+	GUID          string `json:"guid"`
+	Port          *int   `json:"port"`
+	Relationships struct {
+		Space v3Relationship `json:"space"`
+	} `json:"relationships"`
+}
+
+// GetRoutes returns the routes of the scope.
+func (api *APIHelper) GetRoutes(ctx context.Context) ([]Route, error) {
+	path := "/v2/routes"
+	switch {
+	case api.scope.SpaceGUID != "":
+		path = "/v2/spaces/" + api.scope.SpaceGUID + "/routes"
+	case api.scope.OrgGUID != "":
+		path += "?q=" + url.QueryEscape("organization_guid:"+api.scope.OrgGUID)
+	}
+	routes := make([]Route, 0, 64)
+	err := api.getAllPages(ctx, path, "route", func(r v2Resource) error {
+		var entity v2RouteEntity
+		if err := decodeEntity("route", r, &entity); nil != err {
+			return err
+		}
+		if err := requireFields("route", r.Metadata.GUID,
+			"space_guid", entity.SpaceGUID); nil != err {
+			return err
+		}
+		route := Route{GUID: r.Metadata.GUID, SpaceGUID: entity.SpaceGUID}
+		if entity.Port != nil {
+			route.Port = int(*entity.Port)
+		}
+		routes = append(routes, route)
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// GetServiceKeys returns the service keys of the scope. The keys can not be
+// filtered by org or space, but by the GUIDs of the service instances of
+// the scope.
+func (api *APIHelper) GetServiceKeys(ctx context.Context) ([]ServiceKey, error) {
+	paths := []string{"/v2/service_keys"}
+	if api.scope.OrgGUID != "" {
+		simap, err := api.GetServiceInstanceMap(ctx)
+		if nil != err {
+			return nil, err
+		}
+		guids := make([]string, 0, len(simap))
+		for guid := range simap {
+			guids = append(guids, guid)
+		}
+		paths = nil
+		for _, chunk := range chunks(guids, bindingQueryChunk) {
+			paths = append(paths, "/v2/service_keys?q="+url.QueryEscape("service_instance_guid IN "+strings.Join(chunk, ",")))
+		}
+	}
+	keys := make([]ServiceKey, 0, 16)
+	for _, path := range paths {
+		err := api.getAllPages(ctx, path, "service key", func(r v2Resource) error {
+			var entity v2ServiceKeyEntity
+			if err := decodeEntity("service key", r, &entity); nil != err {
+				return err
+			}
+			if err := requireFields("service key", r.Metadata.GUID,
+				"service_instance_guid", entity.ServiceInstanceGUID); nil != err {
+				return err
+			}
+			keys = append(keys, ServiceKey{GUID: r.Metadata.GUID, ServiceInstanceGUID: entity.ServiceInstanceGUID})
+			return nil
+		})
+		if nil != err {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// GetRoutes returns the routes of the scope.
+func (api *APIHelperV3) GetRoutes(ctx context.Context) ([]Route, error) {
+	routes := make([]Route, 0, 64)
+	err := api.getAllPages(ctx, api.scope.v3List("/v3/routes", "organization_guids", "space_guids"), "route", func(raw json.RawMessage) error {
+		var r v3Route
+		if err := decodeResource("route", raw, &r); nil != err {
+			return err
+		}
+		if err := requireFields("route", r.GUID,
+			"relationships.space", r.Relationships.Space.guid()); nil != err {
+			return err
+		}
+		route := Route{GUID: r.GUID, SpaceGUID: r.Relationships.Space.guid()}
+		if r.Port != nil {
+			route.Port = *r.Port
+		}
+		routes = append(routes, route)
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// GetServiceKeys returns the service keys of the scope, filtered by the
+// GUIDs of its managed service instances like for v2.
+func (api *APIHelperV3) GetServiceKeys(ctx context.Context) ([]ServiceKey, error) {
+	paths := []string{"/v3/service_credential_bindings?type=key"}
+	if api.scope.OrgGUID != "" {
+		instances, err := api.getServiceInstances(ctx, "managed")
+		if nil != err {
+			return nil, err
+		}
+		guids := make([]string, 0, len(instances))
+		for _, si := range instances {
+			guids = append(guids, si.GUID)
+		}
+		paths = nil
+		for _, chunk := range chunks(guids, bindingQueryChunk) {
+			paths = append(paths, "/v3/service_credential_bindings?type=key&service_instance_guids="+strings.Join(chunk, ","))
+		}
+	}
+	var keys []ServiceKey
+	for _, path := range paths {
+		bindings, err := api.getCredentialBindings(ctx, path)
+		if nil != err {
+			return nil, err
+		}
+		for _, b := range bindings {
+			keys = append(keys, ServiceKey{GUID: b.GUID, ServiceInstanceGUID: b.Relationships.ServiceInstance.guid()})
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,112 @@
+package apihelper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxRecordingNameLength limits the readable part of recording file names.
+const maxRecordingNameLength = 80
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._=-]+`)
+
+// recordingFile returns the file holding the response for path. The name
+// starts with the path for humans and ends with a hash of the full path, so
+// files can be edited, e.g. to remove names, without breaking the lookup.
+func recordingFile(dir, path string) string {
+	name := unsafeNameChars.ReplaceAllString(path, "_")
+	name = strings.Trim(name, "_")
+	if len(name) > maxRecordingNameLength {
+		name = name[:maxRecordingNameLength]
+	}
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(dir, name+"-"+hex.EncodeToString(sum[:4])+".json")
+}
+
+// recordedError is the recording of an *APIError. The status code is only
+// known when talking HTTP directly, errors of cf curl are recorded like the
+// body they were returned as.
+type recordedError struct {
+	StatusCode  int    `json:"status_code,omitempty"`
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+	ErrorCode   string `json:"error_code"`
+}
+
+// RecordTransport stores every response fetched through it in a directory,
+// which ReplayTransport can serve later on.
+type RecordTransport struct {
+	transport Transport
+	dir       string
+}
+
+// NewRecordTransport wraps t so that all responses are written to dir.
+func NewRecordTransport(t Transport, dir string) (*RecordTransport, error) {
+	if err := os.MkdirAll(dir, 0700); nil != err {
+		return nil, fmt.Errorf("creating record directory: %v", err)
+	}
+	return &RecordTransport{transport: t, dir: dir}, nil
+}
+
+// Get issues the request and records the response. Cloud Controller errors
+// are recorded as error bodies so that replaying fails the same way.
+func (t *RecordTransport) Get(ctx context.Context, path string) ([]byte, error) {
+	body, err := t.transport.Get(ctx, path)
+	recorded := body
+	if nil != err {
+		apiErr, ok := err.(*APIError)
+		if !ok {
+			return nil, err
+		}
+		recorded, _ = json.Marshal(recordedError{apiErr.StatusCode, apiErr.Code, apiErr.Description, apiErr.ErrorCode})
+	}
+	if writeErr := ioutil.WriteFile(recordingFile(t.dir, path), recorded, 0600); nil != writeErr {
+		return nil, fmt.Errorf("recording %s: %v", path, writeErr)
+	}
+	return body, err
+}
+
+// ReplayTransport serves the responses recorded by RecordTransport without
+// talking to a Cloud Controller.
+type ReplayTransport struct {
+	dir string
+}
+
+// NewReplayTransport returns a Transport serving the recordings in dir.
+func NewReplayTransport(dir string) (*ReplayTransport, error) {
+	info, err := os.Stat(dir)
+	if nil != err {
+		return nil, fmt.Errorf("opening recordings: %v", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("opening recordings: %s is not a directory", dir)
+	}
+	return &ReplayTransport{dir: dir}, nil
+}
+
+// Get returns the recorded response for path. Errors recorded with their
+// status code are returned as *APIError, others are left to the error check
+// of the body.
+func (t *ReplayTransport) Get(ctx context.Context, path string) ([]byte, error) {
+	body, err := ioutil.ReadFile(recordingFile(t.dir, path))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: no recorded response in %s", path, t.dir)
+	}
+	if nil != err {
+		return nil, err
+	}
+	var recErr recordedError
+	if json.Unmarshal(body, &recErr) == nil && recErr.StatusCode != 0 {
+		return nil, &APIError{Path: path, StatusCode: recErr.StatusCode, Code: recErr.Code,
+			ErrorCode: recErr.ErrorCode, Description: recErr.Description}
+	}
+	return body, nil
+}
@@ -0,0 +1,102 @@
+package apihelper
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Record and replay", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "usagereport-recordings")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("replays the recorded responses of a report", func() {
+		fakeCliConnection := &pluginfakes.FakeCliConnection{}
+		fakeCliConnection.CliCommandWithoutTerminalOutputReturns(slurp("test-data/orgs.json"), nil)
+		recorder, err := NewRecordTransport(NewCurlTransport(fakeCliConnection), dir)
+		Expect(err).To(BeNil())
+		recorded, err := NewWithTransport(recorder).GetOrgs(ctx)
+		Expect(err).To(BeNil())
+
+		replay, err := NewReplayTransport(dir)
+		Expect(err).To(BeNil())
+		replayed, err := NewWithTransport(replay).GetOrgs(ctx)
+		Expect(err).To(BeNil())
+		Expect(replayed).To(Equal(recorded))
+	})
+
+	It("names the files after the request path", func() {
+		recorder, _ := NewRecordTransport(transportFunc(func(path string) ([]byte, error) {
+			return []byte(`{"memory_usage_in_mb": 512}`), nil
+		}), dir)
+		recorder.Get(ctx, "/v2/organizations/1234/memory_usage")
+		files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		Expect(files).To(HaveLen(1))
+		Expect(filepath.Base(files[0])).To(HavePrefix("v2_organizations_1234_memory_usage-"))
+	})
+
+	It("replays Cloud Controller errors", func() {
+		recorder, _ := NewRecordTransport(transportFunc(func(path string) ([]byte, error) {
+			return nil, &APIError{Path: path, StatusCode: http.StatusForbidden, Code: 10003,
+				ErrorCode: "CF-NotAuthorized", Description: "You are not authorized"}
+		}), dir)
+		_, err := recorder.Get(ctx, "/v2/quota_definitions/q1")
+		Expect(err).ToNot(BeNil())
+
+		replay, _ := NewReplayTransport(dir)
+		_, err = NewWithTransport(replay).GetQuotaMemoryLimit(ctx, "/v2/quota_definitions/q1")
+		apiErr, ok := err.(*APIError)
+		Expect(ok).To(BeTrue())
+		Expect(apiErr.ErrorCode).To(Equal("CF-NotAuthorized"))
+		Expect(apiErr.StatusCode).To(Equal(http.StatusForbidden))
+	})
+
+	It("replays HTTP errors without error code with their status code", func() {
+		recorder, _ := NewRecordTransport(transportFunc(func(path string) ([]byte, error) {
+			return nil, &APIError{Path: path, StatusCode: http.StatusNotFound, Description: "Not Found"}
+		}), dir)
+		recorder.Get(ctx, "/v3/tasks")
+
+		replay, _ := NewReplayTransport(dir)
+		_, err := replay.Get(ctx, "/v3/tasks")
+		Expect(err).To(Equal(&APIError{Path: "/v3/tasks", StatusCode: http.StatusNotFound, Description: "Not Found"}))
+		Expect(isNotFound(err)).To(BeTrue())
+	})
+
+	It("does not record other errors", func() {
+		recorder, _ := NewRecordTransport(transportFunc(func(path string) ([]byte, error) {
+			return nil, errors.New("Bad Things")
+		}), dir)
+		recorder.Get(ctx, "/v2/organizations")
+		files, _ := ioutil.ReadDir(dir)
+		Expect(files).To(BeEmpty())
+	})
+
+	It("fails for requests which were not recorded", func() {
+		replay, _ := NewReplayTransport(dir)
+		_, err := replay.Get(ctx, "/v2/organizations")
+		Expect(err).ToNot(BeNil())
+		Expect(strings.Contains(err.Error(), "no recorded response")).To(BeTrue())
+	})
+
+	It("fails for a missing directory", func() {
+		_, err := NewReplayTransport(filepath.Join(dir, "missing"))
+		Expect(err).ToNot(BeNil())
+	})
+})
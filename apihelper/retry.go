@@ -0,0 +1,193 @@
+package apihelper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Defaults of the RetryTransport
+const (
+	DefaultMaxRetries   = 5
+	DefaultMaxRetryTime = time.Minute
+	defaultBaseDelay    = 500 * time.Millisecond
+	defaultMaxDelay     = 15 * time.Second
+)
+
+// rateLimitErrorCode is returned by the Cloud Controller in the error body
+// when the per user rate limit is exceeded. cf curl does not expose the
+// status code, so this is the only hint in that case.
+const rateLimitErrorCode = "CF-RateLimitExceeded"
+
+// RetryTransport retries failed GET requests with exponential backoff and
+// jitter. It honors the Retry-After header and gives up once MaxRetries
+// retries were made or the next attempt would exceed MaxRetryTime.
+type RetryTransport struct {
+	transport    Transport
+	MaxRetries   int
+	MaxRetryTime time.Duration
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+
+	sleep func(context.Context, time.Duration) error
+	now   func() time.Time
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewRetryTransport wraps t so that transient failures are retried.
+func NewRetryTransport(t Transport, maxRetries int, maxRetryTime time.Duration) *RetryTransport {
+	return &RetryTransport{
+		transport:    t,
+		MaxRetries:   maxRetries,
+		MaxRetryTime: maxRetryTime,
+		BaseDelay:    defaultBaseDelay,
+		MaxDelay:     defaultMaxDelay,
+		sleep:        sleep,
+		now:          time.Now,
+		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Get issues the request and retries it on 429, 5xx gateway errors, timeouts
+// and network errors. Once ctx is done no further attempt is made.
+func (t *RetryTransport) Get(ctx context.Context, path string) ([]byte, error) {
+	start := t.now()
+	for attempt := 0; ; attempt++ {
+		body, err := t.transport.Get(ctx, path)
+		if err == nil {
+			// cf curl returns error bodies without an error
+			err = checkAPIError(path, body)
+			if err == nil || !isRetryable(err) {
+				return body, nil
+			}
+		}
+		if !isRetryable(err) || attempt >= t.MaxRetries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		delay := t.backoff(attempt)
+		if apiErr, ok := err.(*APIError); ok && apiErr.RetryAfter > delay {
+			delay = apiErr.RetryAfter
+		}
+		if t.now().Add(delay).Sub(start) > t.MaxRetryTime {
+			return nil, err
+		}
+		if err := t.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff returns the delay before the given retry attempt: an exponential
+// delay capped at MaxDelay of which a random half is added as jitter.
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	delay := t.BaseDelay << uint(attempt)
+	if delay > t.MaxDelay || delay <= 0 {
+		delay = t.MaxDelay
+	}
+	t.mu.Lock()
+	jitter := time.Duration(t.rand.Int63n(int64(delay)/2 + 1))
+	t.mu.Unlock()
+	return delay/2 + jitter
+}
+
+// isRetryable tells if a request failing with err may succeed when issued
+// again. Of the network errors only timeouts and connections dropped by the
+// server are, refused connections or certificate errors fail the same way
+// again.
+func isRetryable(err error) bool {
+	switch e := err.(type) {
+	case *APIError:
+		switch e.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return e.ErrorCode == rateLimitErrorCode
+	case *TimeoutError:
+		return true
+	case net.Error:
+		return e.Timeout() || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+	return false
+}
+
+// parseRetryAfter parses the value of a Retry-After header which is either
+// a number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// RateLimitTransport limits the requests to the Cloud Controller to a
+// fixed number per second, so that large reports do not trip the rate
+// limiter of the Cloud Controller for other users.
+type RateLimitTransport struct {
+	transport Transport
+	interval  time.Duration
+
+	sleep func(context.Context, time.Duration) error
+	now   func() time.Time
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// NewRateLimitTransport wraps t so that at most requestsPerSecond requests
+// are issued per second.
+func NewRateLimitTransport(t Transport, requestsPerSecond float64) *RateLimitTransport {
+	return &RateLimitTransport{
+		transport: t,
+		interval:  time.Duration(float64(time.Second) / requestsPerSecond),
+		sleep:     sleep,
+		now:       time.Now,
+	}
+}
+
+// Get waits for the next free slot and issues the request.
+func (t *RateLimitTransport) Get(ctx context.Context, path string) ([]byte, error) {
+	t.mu.Lock()
+	now := t.now()
+	slot := t.next
+	if slot.Before(now) {
+		slot = now
+	}
+	t.next = slot.Add(t.interval)
+	t.mu.Unlock()
+
+	if wait := slot.Sub(now); wait > 0 {
+		if err := t.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+	return t.transport.Get(ctx, path)
+}
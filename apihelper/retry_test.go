@@ -0,0 +1,207 @@
+package apihelper
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// scriptedTransport returns the given results one after another
+type scriptedTransport struct {
+	results []error
+	calls   int
+}
+
+func (t *scriptedTransport) Get(ctx context.Context, path string) ([]byte, error) {
+	err := t.results[t.calls]
+	t.calls++
+	if err != nil {
+		return nil, err
+	}
+	return []byte(`{"memory_usage_in_mb": 512}`), nil
+}
+
+// timeoutError is a network error which timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ = Describe("Retries", func() {
+	var scripted *scriptedTransport
+	var transport *RetryTransport
+	var clock time.Time
+	var sleeps []time.Duration
+
+	BeforeEach(func() {
+		scripted = &scriptedTransport{}
+		transport = NewRetryTransport(scripted, 3, time.Minute)
+		clock = time.Unix(0, 0)
+		sleeps = nil
+		transport.now = func() time.Time { return clock }
+		transport.sleep = func(ctx context.Context, d time.Duration) error {
+			sleeps = append(sleeps, d)
+			clock = clock.Add(d)
+			return nil
+		}
+	})
+
+	It("retries bad gateway errors until the request succeeds", func() {
+		scripted.results = []error{
+			&APIError{StatusCode: http.StatusBadGateway},
+			&APIError{StatusCode: http.StatusServiceUnavailable},
+			nil,
+		}
+		_, err := transport.Get(ctx, "/v2/organizations")
+		Expect(err).To(BeNil())
+		Expect(scripted.calls).To(Equal(3))
+		Expect(sleeps).To(HaveLen(2))
+	})
+
+	It("backs off exponentially", func() {
+		Expect(transport.backoff(0)).To(BeNumerically("<=", defaultBaseDelay))
+		Expect(transport.backoff(0)).To(BeNumerically(">=", defaultBaseDelay/2))
+		Expect(transport.backoff(3)).To(BeNumerically(">=", 4*defaultBaseDelay))
+		Expect(transport.backoff(30)).To(BeNumerically("<=", defaultMaxDelay))
+	})
+
+	It("honors Retry-After", func() {
+		scripted.results = []error{
+			&APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 20 * time.Second},
+			nil,
+		}
+		_, err := transport.Get(ctx, "/v2/organizations")
+		Expect(err).To(BeNil())
+		Expect(sleeps).To(Equal([]time.Duration{20 * time.Second}))
+	})
+
+	It("gives up after the maximum number of retries", func() {
+		scripted.results = []error{
+			&APIError{StatusCode: http.StatusBadGateway},
+			&APIError{StatusCode: http.StatusBadGateway},
+			&APIError{StatusCode: http.StatusBadGateway},
+			&APIError{StatusCode: http.StatusBadGateway},
+		}
+		_, err := transport.Get(ctx, "/v2/organizations")
+		Expect(err).ToNot(BeNil())
+		Expect(scripted.calls).To(Equal(4))
+	})
+
+	It("gives up when the retry time would be exceeded", func() {
+		scripted.results = []error{
+			&APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 2 * time.Minute},
+			nil,
+		}
+		_, err := transport.Get(ctx, "/v2/organizations")
+		Expect(err).ToNot(BeNil())
+		Expect(scripted.calls).To(Equal(1))
+		Expect(sleeps).To(BeEmpty())
+	})
+
+	It("does not retry other errors", func() {
+		scripted.results = []error{
+			&APIError{StatusCode: http.StatusForbidden, ErrorCode: "CF-NotAuthorized"},
+			errors.New("Bad Things"),
+		}
+		_, err := transport.Get(ctx, "/v2/organizations")
+		Expect(err).ToNot(BeNil())
+		Expect(scripted.calls).To(Equal(1))
+	})
+
+	It("retries requests which timed out", func() {
+		scripted.results = []error{&TimeoutError{Path: "/v2/organizations"}, nil}
+		_, err := transport.Get(ctx, "/v2/organizations")
+		Expect(err).To(BeNil())
+		Expect(scripted.calls).To(Equal(2))
+	})
+
+	It("retries network timeouts and connections dropped by the server only", func() {
+		timeout := &url.Error{Op: "Get", URL: "/v2/organizations", Err: &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}}
+		reset := &url.Error{Op: "Get", URL: "/v2/organizations", Err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}
+		dropped := &url.Error{Op: "Get", URL: "/v2/organizations", Err: io.EOF}
+		scripted.results = []error{timeout, reset, dropped, nil}
+		_, err := transport.Get(ctx, "/v2/organizations")
+		Expect(err).To(BeNil())
+		Expect(scripted.calls).To(Equal(4))
+
+		for _, err := range []error{
+			&url.Error{Op: "Get", URL: "/v2/organizations", Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}},
+			&url.Error{Op: "Get", URL: "/v2/organizations", Err: x509.UnknownAuthorityError{}},
+		} {
+			scripted.calls = 0
+			scripted.results = []error{err, nil}
+			_, err = transport.Get(ctx, "/v2/organizations")
+			Expect(err).ToNot(BeNil())
+			Expect(scripted.calls).To(Equal(1))
+		}
+	})
+
+	It("stops retrying when the context is done", func() {
+		cancelCtx, cancel := context.WithCancel(ctx)
+		scripted.results = []error{&APIError{StatusCode: http.StatusBadGateway}, nil}
+		transport.sleep = func(ctx context.Context, d time.Duration) error {
+			cancel()
+			return ctx.Err()
+		}
+		_, err := transport.Get(cancelCtx, "/v2/organizations")
+		Expect(err).To(Equal(context.Canceled))
+		Expect(scripted.calls).To(Equal(1))
+	})
+
+	It("retries rate limit error bodies returned through cf curl", func() {
+		bodies := []string{
+			`{"errors":[{"code":10013,"title":"CF-RateLimitExceeded","detail":"Rate Limit Exceeded"}]}`,
+			`{"memory_usage_in_mb": 512}`,
+		}
+		calls := 0
+		transport.transport = transportFunc(func(path string) ([]byte, error) {
+			calls++
+			return []byte(bodies[calls-1]), nil
+		})
+		body, err := transport.Get(ctx, "/v2/organizations/1234/memory_usage")
+		Expect(err).To(BeNil())
+		Expect(string(body)).To(Equal(bodies[1]))
+	})
+
+	It("parses Retry-After as seconds and as date", func() {
+		now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+		Expect(parseRetryAfter("7", now)).To(Equal(7 * time.Second))
+		Expect(parseRetryAfter("Wed, 01 Mar 2017 12:00:30 GMT", now)).To(Equal(30 * time.Second))
+		Expect(parseRetryAfter("soon", now)).To(Equal(time.Duration(0)))
+	})
+})
+
+var _ = Describe("Rate limit", func() {
+	It("spaces the requests evenly", func() {
+		clock := time.Unix(0, 0)
+		var sleeps []time.Duration
+		transport := NewRateLimitTransport(&scriptedTransport{results: []error{nil, nil, nil}}, 4)
+		transport.now = func() time.Time { return clock }
+		transport.sleep = func(ctx context.Context, d time.Duration) error {
+			sleeps = append(sleeps, d)
+			return nil
+		}
+
+		transport.Get(ctx, "/v2/organizations")
+		transport.Get(ctx, "/v2/organizations")
+		transport.Get(ctx, "/v2/organizations")
+		Expect(sleeps).To(Equal([]time.Duration{250 * time.Millisecond, 500 * time.Millisecond}))
+	})
+})
+
+type transportFunc func(path string) ([]byte, error)
+
+func (f transportFunc) Get(ctx context.Context, path string) ([]byte, error) {
+	return f(path)
+}
@@ -0,0 +1,183 @@
+package apihelper
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// bindingQueryChunk limits the number of app GUIDs per service binding
+// query of a scoped helper to keep the URL short.
+const bindingQueryChunk = 50
+
+// Scope limits the foundation-wide lists of a CFAPIHelper to an org or a
+// space of it, so that users who can only see a few orgs do not wait for
+// the whole foundation. A SpaceGUID needs the OrgGUID of its org. The
+// empty Scope is the whole foundation.
+type Scope struct {
+	OrgGUID   string
+	SpaceGUID string
+}
+
+// NewScopedForAPIVersion returns the CFAPIHelper for the given API version
+// whose lists only contain the resources of scope. The v2 service plans
+// can not be filtered by org or space and are listed completely.
+func NewScopedForAPIVersion(ctx context.Context, t Transport, version string, scope Scope) (CFAPIHelper, error) {
+	api, err := NewForAPIVersion(ctx, t, version)
+	if nil != err {
+		return nil, err
+	}
+	switch helper := api.(type) {
+	case *APIHelper:
+		helper.scope = scope
+	case *APIHelperV3:
+		helper.scope = scope
+	}
+	return api, nil
+}
+
+// v2List adds the q filter of the scope to the v2 list at path, for lists
+// which can be filtered by organization_guid and space_guid.
+func (s Scope) v2List(path string) string {
+	switch {
+	case s.SpaceGUID != "":
+		return path + "?q=" + url.QueryEscape("space_guid:"+s.SpaceGUID)
+	case s.OrgGUID != "":
+		return path + "?q=" + url.QueryEscape("organization_guid:"+s.OrgGUID)
+	}
+	return path
+}
+
+// v3List adds the filter of the scope to the v3 list at path. orgParam and
+// spaceParam name the filters of the list, e.g. organization_guids.
+func (s Scope) v3List(path, orgParam, spaceParam string) string {
+	param, guid := orgParam, s.OrgGUID
+	if s.SpaceGUID != "" && spaceParam != "" {
+		param, guid = spaceParam, s.SpaceGUID
+	}
+	if guid == "" {
+		return path
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + param + "=" + guid
+}
+
+// chunks splits guids into slices of at most size GUIDs.
+func chunks(guids []string, size int) [][]string {
+	var parts [][]string
+	for start := 0; start < len(guids); start += size {
+		end := start + size
+		if end > len(guids) {
+			end = len(guids)
+		}
+		parts = append(parts, guids[start:end])
+	}
+	return parts
+}
+
+// getResource calls fn for the single v2 resource at path.
+func (api *APIHelper) getResource(ctx context.Context, path, resource string, fn func(r v2Resource) error) error {
+	var r v2Resource
+	if err := getJSON(ctx, api.transport, path, resource, &r); nil != err {
+		return err
+	}
+	return fn(r)
+}
+
+// eachOrg calls fn for every org of the scope.
+func (api *APIHelper) eachOrg(ctx context.Context, fn func(r v2Resource) error) error {
+	if api.scope.OrgGUID == "" {
+		return api.getAllPages(ctx, "/v2/organizations", "organization", fn)
+	}
+	return api.getResource(ctx, "/v2/organizations/"+api.scope.OrgGUID, "organization", fn)
+}
+
+// eachSpace calls fn for every space of the scope.
+func (api *APIHelper) eachSpace(ctx context.Context, fn func(r v2Resource) error) error {
+	switch {
+	case api.scope.SpaceGUID != "":
+		return api.getResource(ctx, "/v2/spaces/"+api.scope.SpaceGUID, "space", fn)
+	case api.scope.OrgGUID != "":
+		return api.getAllPages(ctx, "/v2/spaces?q="+url.QueryEscape("organization_guid:"+api.scope.OrgGUID), "space", fn)
+	}
+	return api.getAllPages(ctx, "/v2/spaces", "space", fn)
+}
+
+// servicesPath returns the list of the services available in the scope.
+func (api *APIHelper) servicesPath() string {
+	switch {
+	case api.scope.SpaceGUID != "":
+		return "/v2/spaces/" + api.scope.SpaceGUID + "/services"
+	case api.scope.OrgGUID != "":
+		return "/v2/organizations/" + api.scope.OrgGUID + "/services"
+	}
+	return "/v2/services"
+}
+
+// eachQuota calls fn for every quota definition, or only for the quota of
+// the org of the scope.
+func (api *APIHelper) eachQuota(ctx context.Context, fn func(r v2Resource) error) error {
+	if api.scope.OrgGUID == "" {
+		return api.getAllPages(ctx, "/v2/quota_definitions", "quota definition", fn)
+	}
+	return api.eachOrg(ctx, func(r v2Resource) error {
+		var entity v2OrgEntity
+		if err := decodeEntity("organization", r, &entity); nil != err {
+			return err
+		}
+		if entity.QuotaDefinitionGUID == "" {
+			return nil
+		}
+		return api.getResource(ctx, "/v2/quota_definitions/"+entity.QuotaDefinitionGUID, "quota definition", fn)
+	})
+}
+
+// serviceBindingsPaths returns the lists of the service bindings of the
+// scope. The bindings can not be filtered by org or space, but by the
+// GUIDs of the apps of the scope.
+func (api *APIHelper) serviceBindingsPaths(ctx context.Context) ([]string, error) {
+	if api.scope.OrgGUID == "" {
+		return []string{"/v2/service_bindings"}, nil
+	}
+	apps, err := api.GetApps(ctx)
+	if nil != err {
+		return nil, err
+	}
+	var paths []string
+	for _, guids := range chunks(appGUIDs(apps), bindingQueryChunk) {
+		paths = append(paths, "/v2/service_bindings?q="+url.QueryEscape("app_guid IN "+strings.Join(guids, ",")))
+	}
+	return paths, nil
+}
+
+// credentialBindingsPaths returns the lists of the app bindings of the
+// scope, filtered by the GUIDs of its apps like for v2.
+func (api *APIHelperV3) credentialBindingsPaths(ctx context.Context) ([]string, error) {
+	if api.scope.OrgGUID == "" {
+		return []string{"/v3/service_credential_bindings?type=app"}, nil
+	}
+	rawApps, err := api.getApps(ctx, api.scope.v3List("/v3/apps", "organization_guids", "space_guids"))
+	if nil != err {
+		return nil, err
+	}
+	guids := make([]string, 0, len(rawApps))
+	for _, a := range rawApps {
+		guids = append(guids, a.GUID)
+	}
+	var paths []string
+	for _, chunk := range chunks(guids, bindingQueryChunk) {
+		paths = append(paths, "/v3/service_credential_bindings?type=app&app_guids="+strings.Join(chunk, ","))
+	}
+	return paths, nil
+}
+
+func appGUIDs(apps []App) []string {
+	guids := make([]string, 0, len(apps))
+	for _, a := range apps {
+		guids = append(guids, a.GUID)
+	}
+	return guids
+}
@@ -0,0 +1,172 @@
+package apihelper
+
+import (
+	"strings"
+
+	"github.com/cloudfoundry/cli/plugin/pluginfakes"
+	"github.com/dgruber/usagereport-plugin/apihelper/fakecc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Scoped lists", func() {
+	Describe("v2", func() {
+		var server *fakecc.Server
+
+		scoped := func(scope Scope) CFAPIHelper {
+			fakeCliConnection := &pluginfakes.FakeCliConnection{}
+			fakeCliConnection.ApiEndpointReturns(server.URL, nil)
+			fakeCliConnection.AccessTokenReturns("bearer fake-token", nil)
+			transport, err := NewHTTPTransport(fakeCliConnection)
+			Expect(err).To(BeNil())
+			api, err := NewScopedForAPIVersion(ctx, transport, APIVersionV2, scope)
+			Expect(err).To(BeNil())
+			return api
+		}
+
+		BeforeEach(func() {
+			server = fakecc.New(fakecc.Foundation{
+				Quotas: []fakecc.Quota{{GUID: "q-1", MemoryLimit: 1024}, {GUID: "q-2", MemoryLimit: 2048}},
+				SpaceQuotas: []fakecc.SpaceQuota{
+					{GUID: "sq-1", OrgGUID: "o-1", MemoryLimit: 512},
+					{GUID: "sq-2", OrgGUID: "o-2", MemoryLimit: -1},
+				},
+				Orgs: []fakecc.Org{{GUID: "o-1", Name: "org-1", QuotaGUID: "q-1"}, {GUID: "o-2", Name: "org-2", QuotaGUID: "q-2"}},
+				Spaces: []fakecc.Space{
+					{GUID: "s-1", Name: "dev", OrgGUID: "o-1", QuotaGUID: "sq-1"},
+					{GUID: "s-2", Name: "prod", OrgGUID: "o-1"},
+					{GUID: "s-3", Name: "dev", OrgGUID: "o-2"},
+				},
+				Apps: []fakecc.App{
+					{GUID: "a-1", Name: "web", SpaceGUID: "s-1", Instances: 1, Memory: 64},
+					{GUID: "a-2", Name: "web", SpaceGUID: "s-2", Instances: 1, Memory: 64},
+					{GUID: "a-3", Name: "web", SpaceGUID: "s-3", Instances: 1, Memory: 64},
+				},
+				ServiceInstances: []fakecc.ServiceInstance{
+					{GUID: "si-1", Name: "db", SpaceGUID: "s-1"},
+					{GUID: "si-3", Name: "db", SpaceGUID: "s-3"},
+				},
+				ServiceBindings: []fakecc.ServiceBinding{
+					{GUID: "b-1", AppGUID: "a-1", ServiceInstanceGUID: "si-1"},
+					{GUID: "b-3", AppGUID: "a-3", ServiceInstanceGUID: "si-3"},
+				},
+				Routes: []fakecc.Route{
+					{GUID: "r-1", SpaceGUID: "s-1"},
+					{GUID: "r-2", SpaceGUID: "s-2", Port: 1024},
+					{GUID: "r-3", SpaceGUID: "s-3"},
+				},
+				ServiceKeys: []fakecc.ServiceKey{
+					{GUID: "k-1", ServiceInstanceGUID: "si-1"},
+					{GUID: "k-3", ServiceInstanceGUID: "si-3"},
+				},
+			})
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("lists only the resources of the org", func() {
+			api := scoped(Scope{OrgGUID: "o-1"})
+			orgs, err := api.GetOrgs(ctx)
+			Expect(err).To(BeNil())
+			Expect(orgs).To(HaveLen(1))
+			Expect(orgs[0].Name).To(Equal("org-1"))
+
+			spaces, _ := api.GetSpaces(ctx)
+			Expect(spaces).To(HaveLen(2))
+			apps, _ := api.GetApps(ctx)
+			Expect(apps).To(HaveLen(2))
+			quotas, _ := api.GetQuotaMap(ctx)
+			Expect(quotas).To(HaveLen(1))
+			Expect(quotas).To(HaveKey("q-1"))
+			spaceQuotas, err := api.GetSpaceQuotaMap(ctx)
+			Expect(err).To(BeNil())
+			Expect(spaceQuotas).To(HaveLen(1))
+			Expect(spaceQuotas["sq-1"].MemoryLimit).To(Equal(float64(512)))
+			Expect(spaces[0].QuotaGUID).To(Equal("sq-1"))
+			instances, _ := api.GetServiceInstanceMap(ctx)
+			Expect(instances).To(HaveLen(1))
+			bindings, err := api.GetServiceBindingsList(ctx)
+			Expect(err).To(BeNil())
+			Expect(bindings).To(Equal([]ServiceBinding{{AppGUID: "a-1", ServiceInstanceGUID: "si-1"}}))
+			routes, err := api.GetRoutes(ctx)
+			Expect(err).To(BeNil())
+			Expect(routes).To(Equal([]Route{{GUID: "r-1", SpaceGUID: "s-1"}, {GUID: "r-2", SpaceGUID: "s-2", Port: 1024}}))
+			keys, err := api.GetServiceKeys(ctx)
+			Expect(err).To(BeNil())
+			Expect(keys).To(Equal([]ServiceKey{{GUID: "k-1", ServiceInstanceGUID: "si-1"}}))
+
+			for _, request := range server.Requests() {
+				Expect(request).ToNot(HavePrefix("/v2/quota_definitions?"))
+				Expect(request).ToNot(Equal("/v2/service_bindings?results-per-page=100"))
+				Expect(request).ToNot(Equal("/v2/service_keys?results-per-page=100"))
+			}
+		})
+
+		It("lists only the resources of the space", func() {
+			api := scoped(Scope{OrgGUID: "o-1", SpaceGUID: "s-2"})
+			spaces, err := api.GetSpaces(ctx)
+			Expect(err).To(BeNil())
+			Expect(spaces).To(Equal([]SpaceDetails{{GUID: "s-2", Name: "prod", OrgGUID: "o-1"}}))
+			apps, _ := api.GetApps(ctx)
+			Expect(apps).To(HaveLen(1))
+			Expect(apps[0].GUID).To(Equal("a-2"))
+			bindings, _ := api.GetServiceBindingsList(ctx)
+			Expect(bindings).To(BeEmpty())
+			routes, err := api.GetRoutes(ctx)
+			Expect(err).To(BeNil())
+			Expect(routes).To(Equal([]Route{{GUID: "r-2", SpaceGUID: "s-2", Port: 1024}}))
+		})
+
+		It("lists everything without a scope", func() {
+			apps, err := scoped(Scope{}).GetApps(ctx)
+			Expect(err).To(BeNil())
+			Expect(apps).To(HaveLen(3))
+			spaceQuotas, _ := scoped(Scope{}).GetSpaceQuotaMap(ctx)
+			Expect(spaceQuotas["sq-2"].MemoryLimit).To(Equal(float64(-1)))
+		})
+	})
+
+	Describe("v3", func() {
+		var paths []string
+		var api CFAPIHelper
+
+		BeforeEach(func() {
+			paths = nil
+			transport := transportFunc(func(path string) ([]byte, error) {
+				paths = append(paths, path)
+				return []byte(`{"pagination": {"total_pages": 1}, "resources": []}`), nil
+			})
+			var err error
+			api, err = NewScopedForAPIVersion(ctx, transport, APIVersionV3, Scope{OrgGUID: "o-1", SpaceGUID: "s-1"})
+			Expect(err).To(BeNil())
+		})
+
+		It("filters the lists by org and space GUID", func() {
+			api.GetOrgs(ctx)
+			api.GetSpaces(ctx)
+			api.GetApps(ctx)
+			api.GetQuotaMap(ctx)
+			api.GetSpaceQuotaMap(ctx)
+			api.GetServiceInstanceMap(ctx)
+			api.GetServicePlanMap(ctx)
+			api.GetServiceBindingsList(ctx)
+			api.GetRoutes(ctx)
+			api.GetServiceKeys(ctx)
+			Expect(strings.Join(paths, "\n")).To(Equal(strings.Join([]string{
+				"/v3/organizations?guids=o-1&per_page=5000",
+				"/v3/spaces?guids=s-1&per_page=5000",
+				"/v3/apps?space_guids=s-1&per_page=5000",
+				"/v3/processes?space_guids=s-1&per_page=5000",
+				"/v3/organization_quotas?organization_guids=o-1&per_page=5000",
+				"/v3/space_quotas?space_guids=s-1&per_page=5000",
+				"/v3/service_instances?type=managed&space_guids=s-1&per_page=5000",
+				"/v3/service_plans?space_guids=s-1&per_page=5000",
+				"/v3/apps?space_guids=s-1&per_page=5000",
+				"/v3/routes?space_guids=s-1&per_page=5000",
+				"/v3/service_instances?type=managed&space_guids=s-1&per_page=5000",
+			}, "\n")))
+		})
+	})
+})
@@ -0,0 +1,109 @@
+package apihelper
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// codeAppStopped is the error code of the stats of an app which is not
+// started (CF-AppStoppedStatsError).
+const codeAppStopped = 200003
+
+// InstanceStats is the actual usage of a running app instance. Memory and
+// disk are in bytes like in the API.
+type InstanceStats struct {
+	Index     int
+	Memory    float64
+	Disk      float64
+	DiskQuota float64
+	CPU       float64 // share of a core, 1 is a whole core
+}
+
+type v2InstanceStats struct {
+	State string `json:"state"`
+	Stats *struct {
+		Usage     v2Usage `json:"usage"`
+		DiskQuota float64 `json:"disk_quota"`
+	} `json:"stats"`
+}
+
+type v2Usage struct {
+	Memory float64 `json:"mem"`
+	Disk   float64 `json:"disk"`
+	CPU    float64 `json:"cpu"`
+}
+
+type v3ProcessStats struct {
+	Resources []struct {
+		Index     int     `json:"index"`
+		State     string  `json:"state"`
+		Usage     v2Usage `json:"usage"`
+		DiskQuota float64 `json:"disk_quota"`
+	} `json:"resources"`
+}
+
+// isAppStopped tells whether err is the error of the stats of an app which
+// was stopped since it was listed.
+func isAppStopped(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.Code == codeAppStopped
+}
+
+// GetAppStats returns the usage of the running instances of the app, ordered
+// by index. Apps which are not started have none. The v2 API only knows the
+// web process, so the process type is ignored.
+func (api *APIHelper) GetAppStats(ctx context.Context, appGUID, processType string) ([]InstanceStats, error) {
+	var raw map[string]v2InstanceStats
+	if err := getJSON(ctx, api.transport, "/v2/apps/"+appGUID+"/stats", "app stats", &raw); nil != err {
+		if isAppStopped(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	stats := make([]InstanceStats, 0, len(raw))
+	for index, s := range raw {
+		if s.State != "RUNNING" || s.Stats == nil {
+			continue
+		}
+		i, err := strconv.Atoi(index)
+		if nil != err {
+			return nil, newDecodeError("app stats", appGUID, err)
+		}
+		stats = append(stats, InstanceStats{
+			Index:     i,
+			Memory:    s.Stats.Usage.Memory,
+			Disk:      s.Stats.Usage.Disk,
+			DiskQuota: s.Stats.DiskQuota,
+			CPU:       s.Stats.Usage.CPU,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Index < stats[j].Index })
+	return stats, nil
+}
+
+// GetAppStats returns the usage of the running instances of the process
+// type of the app, ordered by index.
+func (api *APIHelperV3) GetAppStats(ctx context.Context, appGUID, processType string) ([]InstanceStats, error) {
+	var raw v3ProcessStats
+	path := "/v3/apps/" + appGUID + "/processes/" + url.PathEscape(processType) + "/stats"
+	if err := getJSON(ctx, api.transport, path, "process stats", &raw); nil != err {
+		return nil, err
+	}
+	stats := make([]InstanceStats, 0, len(raw.Resources))
+	for _, s := range raw.Resources {
+		if s.State != "RUNNING" {
+			continue
+		}
+		stats = append(stats, InstanceStats{
+			Index:     s.Index,
+			Memory:    s.Usage.Memory,
+			Disk:      s.Usage.Disk,
+			DiskQuota: s.DiskQuota,
+			CPU:       s.Usage.CPU,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Index < stats[j].Index })
+	return stats, nil
+}
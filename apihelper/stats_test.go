@@ -0,0 +1,65 @@
+package apihelper
+
+import (
+	"io/ioutil"
+
+	"github.com/cloudfoundry/cli/plugin/pluginfakes"
+	"github.com/dgruber/usagereport-plugin/apihelper/fakecc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("App stats", func() {
+	const mb = 1024 * 1024
+
+	It("reads the usage of the running instances", func() {
+		server := fakecc.New(fakecc.Foundation{
+			Apps: []fakecc.App{
+				{GUID: "a-1", Name: "web", State: "STARTED", Instances: 3, Memory: 512, Stats: []fakecc.InstanceStats{
+					{MemoryMB: 200, DiskMB: 64, DiskQuotaMB: 1024, CPU: 0.02},
+					{MemoryMB: 100, DiskMB: 32, DiskQuotaMB: 1024, CPU: 0.01},
+				}},
+				{GUID: "a-2", Name: "worker", State: "STOPPED", Instances: 1, Memory: 256},
+			},
+		})
+		defer server.Close()
+		fakeCliConnection := &pluginfakes.FakeCliConnection{}
+		fakeCliConnection.ApiEndpointReturns(server.URL, nil)
+		fakeCliConnection.AccessTokenReturns("bearer fake-token", nil)
+		transport, err := NewHTTPTransport(fakeCliConnection)
+		Expect(err).To(BeNil())
+		api := NewWithTransport(transport)
+
+		stats, err := api.GetAppStats(ctx, "a-1", "web")
+		Expect(err).To(BeNil())
+		Expect(stats).To(Equal([]InstanceStats{
+			{Index: 0, Memory: 200 * mb, Disk: 64 * mb, DiskQuota: 1024 * mb, CPU: 0.02},
+			{Index: 1, Memory: 100 * mb, Disk: 32 * mb, DiskQuota: 1024 * mb, CPU: 0.01},
+		}))
+
+		// the app was stopped since it was listed
+		stats, err = api.GetAppStats(ctx, "a-2", "web")
+		Expect(err).To(BeNil())
+		Expect(stats).To(BeEmpty())
+
+		_, err = api.GetAppStats(ctx, "a-3", "web")
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("reads the stats of a process type for v3", func() {
+		var paths []string
+		api := &APIHelperV3{transport: transportFunc(func(path string) ([]byte, error) {
+			paths = append(paths, path)
+			return ioutil.ReadFile("test-data/v3-process-stats.json")
+		})}
+		stats, err := api.GetAppStats(ctx, "a-1", "web")
+		Expect(err).To(BeNil())
+		_, err = api.GetAppStats(ctx, "a-1", "worker")
+		Expect(err).To(BeNil())
+		Expect(paths).To(Equal([]string{"/v3/apps/a-1/processes/web/stats", "/v3/apps/a-1/processes/worker/stats"}))
+		Expect(stats).To(Equal([]InstanceStats{
+			{Index: 0, Memory: 96 * mb, Disk: 64 * mb, DiskQuota: 1024 * mb, CPU: 0.005},
+			{Index: 1, Memory: 128 * mb, Disk: 64 * mb, DiskQuota: 1024 * mb, CPU: 0.0125},
+		}))
+	})
+})
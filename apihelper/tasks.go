@@ -0,0 +1,79 @@
+package apihelper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// Task is a running task of an app. Its memory counts against the quotas
+// like the one of the app instances, also if the app is stopped. Memory and
+// disk are in MB.
+type Task struct {
+	GUID    string
+	Name    string
+	AppGUID string
+	RAM     float64
+	Disk    float64
+}
+
+type v3Task struct {
+	GUID          string   `json:"guid"`
+	Name          string   `json:"name"`
+	MemoryInMB    *float64 `json:"memory_in_mb"`
+	DiskInMB      *float64 `json:"disk_in_mb"`
+	Relationships struct {
+		App v3Relationship `json:"app"`
+	} `json:"relationships"`
+}
+
+// GetRunningTasks returns the running tasks of the scope from the v3
+// endpoints, v2 does not list tasks. Foundations without the v3 API run no
+// tasks.
+func (api *APIHelper) GetRunningTasks(ctx context.Context) ([]Task, error) {
+	v3 := &APIHelperV3{transport: api.transport, perPage: DefaultPerPage, scope: api.scope}
+	tasks, err := v3.GetRunningTasks(ctx)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	return tasks, err
+}
+
+// isNotFound tells whether err is the error of an unknown endpoint or
+// resource, also on the first page of a list. Through cf curl only the error
+// code of the body is known.
+func isNotFound(err error) bool {
+	if pageErr, ok := err.(*PageError); ok {
+		err = pageErr.Err
+	}
+	apiErr, ok := err.(*APIError)
+	return ok && (apiErr.StatusCode == http.StatusNotFound || apiErr.ErrorCode == "CF-NotFound" || apiErr.ErrorCode == "CF-ResourceNotFound")
+}
+
+// GetRunningTasks returns the running tasks of the scope.
+func (api *APIHelperV3) GetRunningTasks(ctx context.Context) ([]Task, error) {
+	tasks := []Task{}
+	err := api.getAllPages(ctx, api.scope.v3List("/v3/tasks?states=RUNNING", "organization_guids", "space_guids"), "task", func(raw json.RawMessage) error {
+		var t v3Task
+		if err := decodeResource("task", raw, &t); nil != err {
+			return err
+		}
+		if err := requireFields("task", t.GUID,
+			"relationships.app", t.Relationships.App.guid(),
+			"memory_in_mb", t.MemoryInMB); nil != err {
+			return err
+		}
+		tasks = append(tasks, Task{
+			GUID:    t.GUID,
+			Name:    t.Name,
+			AppGUID: t.Relationships.App.guid(),
+			RAM:     *t.MemoryInMB,
+			Disk:    valueOf(t.DiskInMB),
+		})
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return tasks, nil
+}
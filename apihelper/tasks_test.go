@@ -0,0 +1,48 @@
+package apihelper
+
+import (
+	"github.com/cloudfoundry/cli/plugin/pluginfakes"
+	"github.com/dgruber/usagereport-plugin/apihelper/fakecc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Running tasks", func() {
+	It("lists the running tasks of the scope from v3 also for v2", func() {
+		server := fakecc.New(fakecc.Foundation{
+			Orgs:   []fakecc.Org{{GUID: "o-1", Name: "org"}, {GUID: "o-2", Name: "other"}},
+			Spaces: []fakecc.Space{{GUID: "s-1", Name: "dev", OrgGUID: "o-1"}, {GUID: "s-2", Name: "dev", OrgGUID: "o-2"}},
+			Apps: []fakecc.App{
+				{GUID: "a-1", Name: "web", SpaceGUID: "s-1", State: "STOPPED"},
+				{GUID: "a-2", Name: "web", SpaceGUID: "s-2", State: "STARTED"},
+			},
+			Tasks: []fakecc.Task{
+				{GUID: "t-1", Name: "migrate", AppGUID: "a-1", State: "RUNNING", Memory: 256, Disk: 512},
+				{GUID: "t-2", Name: "done", AppGUID: "a-1", State: "SUCCEEDED", Memory: 256, Disk: 512},
+				{GUID: "t-3", Name: "other", AppGUID: "a-2", State: "RUNNING", Memory: 128},
+			},
+		})
+		defer server.Close()
+		fakeCliConnection := &pluginfakes.FakeCliConnection{}
+		fakeCliConnection.ApiEndpointReturns(server.URL, nil)
+		fakeCliConnection.AccessTokenReturns("bearer fake-token", nil)
+		transport, err := NewHTTPTransport(fakeCliConnection)
+		Expect(err).To(BeNil())
+
+		api, err := NewScopedForAPIVersion(ctx, transport, APIVersionV2, Scope{OrgGUID: "o-1"})
+		Expect(err).To(BeNil())
+		tasks, err := api.GetRunningTasks(ctx)
+		Expect(err).To(BeNil())
+		Expect(tasks).To(Equal([]Task{{GUID: "t-1", Name: "migrate", AppGUID: "a-1", RAM: 256, Disk: 512}}))
+		Expect(server.Requests()).To(ContainElement("/v3/tasks?states=RUNNING&organization_guids=o-1&per_page=5000"))
+	})
+
+	It("finds no tasks without the v3 API", func() {
+		api := &APIHelper{transport: transportFunc(func(path string) ([]byte, error) {
+			return nil, &APIError{Path: path, Code: 10000, ErrorCode: "CF-NotFound", Description: "Unknown request"}
+		})}
+		tasks, err := api.GetRunningTasks(ctx)
+		Expect(err).To(BeNil())
+		Expect(tasks).To(BeEmpty())
+	})
+})
@@ -0,0 +1,154 @@
+package apihelper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// TraceTransport logs every request with its page, status, latency and
+// response size and sums them up per endpoint.
+type TraceTransport struct {
+	transport Transport
+	log       io.Writer
+	now       func() time.Time
+
+	mu    sync.Mutex
+	stats map[string]*EndpointStats
+}
+
+// EndpointStats sums up the requests of one endpoint.
+type EndpointStats struct {
+	Endpoint  string
+	Calls     int
+	Errors    int
+	TotalTime time.Duration
+	Bytes     int
+}
+
+// NewTraceTransport wraps t so that every request is logged to log.
+func NewTraceTransport(t Transport, log io.Writer) *TraceTransport {
+	return &TraceTransport{
+		transport: t,
+		log:       log,
+		now:       time.Now,
+		stats:     make(map[string]*EndpointStats),
+	}
+}
+
+// Get issues the request and logs it once the response arrived.
+func (t *TraceTransport) Get(ctx context.Context, path string) ([]byte, error) {
+	start := t.now()
+	body, err := t.transport.Get(ctx, path)
+	latency := t.now().Sub(start)
+
+	endpoint, page := traceEndpoint(path)
+	status := traceStatus(path, body, err)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, exists := t.stats[endpoint]
+	if !exists {
+		s = &EndpointStats{Endpoint: endpoint}
+		t.stats[endpoint] = s
+	}
+	s.Calls++
+	s.TotalTime += latency
+	s.Bytes += len(body)
+	// error bodies of cf curl come without error
+	if status != "200" {
+		s.Errors++
+	}
+	fmt.Fprintf(t.log, "GET %s page=%d status=%s time=%v size=%d\n",
+		path, page, status, latency.Round(time.Millisecond), len(body))
+	return body, err
+}
+
+// Stats returns the sums per endpoint, the slowest endpoint first.
+func (t *TraceTransport) Stats() []EndpointStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := make([]EndpointStats, 0, len(t.stats))
+	for _, s := range t.stats {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].TotalTime != stats[j].TotalTime {
+			return stats[i].TotalTime > stats[j].TotalTime
+		}
+		return stats[i].Endpoint < stats[j].Endpoint
+	})
+	return stats
+}
+
+// WriteSummary writes a table of the requests per endpoint to w.
+func (t *TraceTransport) WriteSummary(w io.Writer) {
+	var total EndpointStats
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Endpoint\tCalls\tErrors\tTotal time\tAvg time\tBytes\t")
+	for _, s := range t.Stats() {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%v\t%v\t%d\t\n", s.Endpoint, s.Calls, s.Errors,
+			s.TotalTime.Round(time.Millisecond), (s.TotalTime / time.Duration(s.Calls)).Round(time.Millisecond), s.Bytes)
+		total.Calls += s.Calls
+		total.Errors += s.Errors
+		total.TotalTime += s.TotalTime
+		total.Bytes += s.Bytes
+	}
+	fmt.Fprintf(tw, "Total\t%d\t%d\t%v\t\t%d\t\n", total.Calls, total.Errors,
+		total.TotalTime.Round(time.Millisecond), total.Bytes)
+	tw.Flush()
+}
+
+// guidSegment matches the path segments which are GUIDs, other segments
+// like the process type in /v3/apps/:guid/processes/web/stats are kept.
+var guidSegment = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// traceEndpoint returns the path without query and with the GUIDs replaced,
+// e.g. /v2/organizations/:guid/spaces, and the requested page.
+func traceEndpoint(path string) (string, int) {
+	u, err := url.Parse(path)
+	if nil != err {
+		return path, 1
+	}
+	page := 1
+	fmt.Sscanf(u.Query().Get("page"), "%d", &page)
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, segment := range segments {
+		if guidSegment.MatchString(segment) {
+			segments[i] = ":guid"
+		}
+	}
+	return "/" + strings.Join(segments, "/"), page
+}
+
+// traceStatus returns the HTTP status of the response as far as known.
+// cf curl does not tell the status, error bodies are shown as "error".
+func traceStatus(path string, body []byte, err error) string {
+	if nil == err {
+		err = checkAPIError(path, body)
+	}
+	switch e := err.(type) {
+	case nil:
+		return "200"
+	case *APIError:
+		if e.StatusCode != 0 {
+			return fmt.Sprintf("%d", e.StatusCode)
+		}
+		return "error"
+	case *TimeoutError:
+		return "timeout"
+	default:
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return "canceled"
+		}
+		return "error"
+	}
+}
@@ -0,0 +1,83 @@
+package apihelper
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tracing", func() {
+	var log bytes.Buffer
+	var trace *TraceTransport
+	var clock time.Time
+
+	BeforeEach(func() {
+		log.Reset()
+		clock = time.Unix(0, 0)
+		trace = NewTraceTransport(transportFunc(func(path string) ([]byte, error) {
+			clock = clock.Add(100 * time.Millisecond)
+			if strings.HasSuffix(path, "memory_usage") {
+				return nil, &APIError{Path: path, StatusCode: http.StatusForbidden}
+			}
+			if strings.HasPrefix(path, "/v2/quota_definitions") {
+				return []byte(`{"code": 10003, "description": "You are not authorized", "error_code": "CF-NotAuthorized"}`), nil
+			}
+			if strings.HasPrefix(path, "/v3") {
+				return nil, errors.New("Bad Things")
+			}
+			return []byte(`{"resources": []}`), nil
+		}), &log)
+		trace.now = func() time.Time { return clock }
+	})
+
+	It("logs every request with page, status, latency and size", func() {
+		trace.Get(ctx, "/v2/apps?results-per-page=100&page=3")
+		trace.Get(ctx, "/v2/organizations/1234/memory_usage")
+		trace.Get(ctx, "/v3/processes")
+		Expect(log.String()).To(Equal(
+			"GET /v2/apps?results-per-page=100&page=3 page=3 status=200 time=100ms size=17\n" +
+				"GET /v2/organizations/1234/memory_usage page=1 status=403 time=100ms size=0\n" +
+				"GET /v3/processes page=1 status=error time=100ms size=0\n"))
+	})
+
+	It("sums up the requests per endpoint", func() {
+		trace.Get(ctx, "/v2/organizations/0d5a3c4e-8f2b-4e0a-9c1d-2b3a4f5e6d7c/memory_usage")
+		trace.Get(ctx, "/v2/organizations/7e6d5c4b-3a2f-4e1d-8c9b-0a1b2c3d4e5f/memory_usage")
+		trace.Get(ctx, "/v2/apps")
+		stats := trace.Stats()
+		Expect(stats).To(Equal([]EndpointStats{
+			{Endpoint: "/v2/organizations/:guid/memory_usage", Calls: 2, Errors: 2, TotalTime: 200 * time.Millisecond},
+			{Endpoint: "/v2/apps", Calls: 1, TotalTime: 100 * time.Millisecond, Bytes: 17},
+		}))
+
+		var summary bytes.Buffer
+		trace.WriteSummary(&summary)
+		Expect(summary.String()).To(ContainSubstring("/v2/organizations/:guid/memory_usage"))
+		Expect(summary.String()).To(MatchRegexp(`Total\s+3\s+2\s+300ms\s+17`))
+	})
+
+	It("counts error bodies returned without error as errors", func() {
+		trace.Get(ctx, "/v2/quota_definitions")
+		Expect(log.String()).To(ContainSubstring("status=error"))
+		Expect(trace.Stats()[0].Errors).To(Equal(1))
+	})
+
+	It("replaces the GUIDs of the endpoints and finds the page", func() {
+		endpoint, page := traceEndpoint("/v2/spaces/0d5a3c4e-8f2b-4e0a-9c1d-2b3a4f5e6d7c/apps?page=2")
+		Expect(endpoint).To(Equal("/v2/spaces/:guid/apps"))
+		Expect(page).To(Equal(2))
+		endpoint, _ = traceEndpoint("https://api.example.com/v3/organizations/0d5a3c4e-8f2b-4e0a-9c1d-2b3a4f5e6d7c/usage_summary")
+		Expect(endpoint).To(Equal("/v3/organizations/:guid/usage_summary"))
+		endpoint, _ = traceEndpoint("/v3/apps/0d5a3c4e-8f2b-4e0a-9c1d-2b3a4f5e6d7c/droplets/current")
+		Expect(endpoint).To(Equal("/v3/apps/:guid/droplets/current"))
+		endpoint, _ = traceEndpoint("/v3/apps/7e6d5c4b-3a2f-4e1d-8c9b-0a1b2c3d4e5f/processes/web/stats")
+		Expect(endpoint).To(Equal("/v3/apps/:guid/processes/web/stats"))
+		endpoint, _ = traceEndpoint("/")
+		Expect(endpoint).To(Equal("/"))
+	})
+})
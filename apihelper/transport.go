@@ -0,0 +1,307 @@
+package apihelper
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin"
+)
+
+// Transport issues GET requests against the Cloud Controller and returns
+// the raw response body. Get returns early once ctx is done.
+type Transport interface {
+	Get(ctx context.Context, path string) ([]byte, error)
+}
+
+// Transports accepted by NewTransport
+const (
+	TransportHTTP = "http"
+	TransportCurl = "curl"
+)
+
+// tokenExpiryMargin is the time before the expiry of the access token at
+// which it gets refreshed.
+const tokenExpiryMargin = 30 * time.Second
+
+// NewTransport returns the Transport of the given kind.
+func NewTransport(cli plugin.CliConnection, kind string) (Transport, error) {
+	switch kind {
+	case TransportHTTP, "":
+		return NewHTTPTransport(cli)
+	case TransportCurl:
+		return NewCurlTransport(cli), nil
+	}
+	return nil, fmt.Errorf("unknown transport %q", kind)
+}
+
+// CurlTransport spawns cf curl for every request. The CLI captures the
+// output of plugin commands in a shared buffer, so requests are serialized.
+// A running cf curl can not be aborted, Get only stops waiting for it.
+type CurlTransport struct {
+	cli plugin.CliConnection
+	mu  sync.Mutex
+}
+
+// NewCurlTransport returns a Transport which uses cf curl.
+func NewCurlTransport(cli plugin.CliConnection) *CurlTransport {
+	return &CurlTransport{cli: cli}
+}
+
+// Get issues a GET request for path through cf curl.
+func (t *CurlTransport) Get(ctx context.Context, path string) ([]byte, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	type result struct {
+		output []string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		t.mu.Lock()
+		output, err := t.cli.CliCommandWithoutTerminalOutput("curl", path)
+		t.mu.Unlock()
+		done <- result{output, err}
+	}()
+
+	var r result
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if nil != r.err {
+		return nil, r.err
+	}
+	data := strings.Join(r.output, "\n")
+	if strings.TrimSpace(data) == "" {
+		return nil, fmt.Errorf("%s: CF API returned no output", path)
+	}
+	return []byte(data), nil
+}
+
+// HTTPTransport talks HTTP directly with the API endpoint the CLI is
+// targeting, reusing connections between requests. The access token is
+// taken from the CLI and refreshed when it expires.
+type HTTPTransport struct {
+	cli      Connection
+	endpoint string
+	client   *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewHTTPTransport returns a Transport for the API endpoint of the CLI or
+// of any other Connection.
+func NewHTTPTransport(cli Connection) (*HTTPTransport, error) {
+	endpoint, err := cli.ApiEndpoint()
+	if nil != err {
+		return nil, fmt.Errorf("getting API endpoint: %v", err)
+	}
+	if endpoint == "" {
+		return nil, fmt.Errorf("no API endpoint set, use cf api or cf login")
+	}
+	sslDisabled, err := cli.IsSSLDisabled()
+	if nil != err {
+		return nil, fmt.Errorf("getting SSL settings: %v", err)
+	}
+
+	return &HTTPTransport{
+		cli:      cli,
+		endpoint: strings.TrimRight(endpoint, "/"),
+		client:   newHTTPClient(sslDisabled),
+	}, nil
+}
+
+// newHTTPClient returns a client which keeps connections to the API open.
+func newHTTPClient(sslDisabled bool) *http.Client {
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: sslDisabled},
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return &http.Client{Transport: transport}
+}
+
+// Get issues a GET request for path. On 401 the token is refreshed and the
+// request is repeated once.
+func (t *HTTPTransport) Get(ctx context.Context, path string) ([]byte, error) {
+	token, err := t.accessToken()
+	if nil != err {
+		return nil, err
+	}
+	resp, body, err := t.do(ctx, path, token)
+	if nil != err {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		if token, err = t.refreshToken(token); nil != err {
+			return nil, err
+		}
+		if resp, body, err = t.do(ctx, path, token); nil != err {
+			return nil, err
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newHTTPError(path, resp, body)
+	}
+	return body, nil
+}
+
+func (t *HTTPTransport) do(ctx context.Context, path, token string) (*http.Response, []byte, error) {
+	req, err := http.NewRequest("GET", t.endpoint+path, nil)
+	if nil != err {
+		return nil, nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := t.client.Do(req)
+	if nil != err {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if nil != err {
+		return nil, nil, fmt.Errorf("%s: reading response: %v", path, err)
+	}
+	return resp, body, nil
+}
+
+// tokenRefresher is a Connection which can replace a token the API
+// rejected before it expires, like UAAConnection. The CLI checks its token
+// itself when asked for it.
+type tokenRefresher interface {
+	RefreshAccessToken() (string, error)
+}
+
+// accessToken returns the cached token or asks the CLI for a new one if
+// the cached one is about to expire. The CLI refreshes the token with UAA
+// when needed.
+func (t *HTTPTransport) accessToken() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" &&
+		(t.expires.IsZero() || time.Now().Add(tokenExpiryMargin).Before(t.expires)) {
+		return t.token, nil
+	}
+	token, err := t.cli.AccessToken()
+	if nil != err {
+		return "", fmt.Errorf("getting access token: %v", err)
+	}
+	return t.setToken(token)
+}
+
+// refreshToken replaces the rejected token. Unless another request did so
+// already, the Connection is forced to get a new one.
+func (t *HTTPTransport) refreshToken(rejected string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && t.token != rejected {
+		return t.token, nil
+	}
+	var token string
+	var err error
+	if refresher, ok := t.cli.(tokenRefresher); ok {
+		token, err = refresher.RefreshAccessToken()
+	} else {
+		token, err = t.cli.AccessToken()
+	}
+	if nil != err {
+		return "", fmt.Errorf("getting access token: %v", err)
+	}
+	return t.setToken(token)
+}
+
+// setToken caches token, t.mu must be held.
+func (t *HTTPTransport) setToken(token string) (string, error) {
+	if token == "" {
+		return "", fmt.Errorf("not logged in, use cf login")
+	}
+	t.token = token
+	t.expires = tokenExpiry(token)
+	return t.token, nil
+}
+
+// tokenExpiry returns the expiry time of a JWT bearer token or the zero
+// time if it can not be determined.
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
+
+// newHTTPError turns a non 2xx response into an *APIError.
+func newHTTPError(path string, resp *http.Response, body []byte) error {
+	apiErr, ok := checkAPIError(path, body).(*APIError)
+	if !ok {
+		apiErr = &APIError{Path: path, Description: http.StatusText(resp.StatusCode)}
+	}
+	apiErr.StatusCode = resp.StatusCode
+	apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	return apiErr
+}
+
+// DefaultRequestTimeout is the time a single request may take.
+const DefaultRequestTimeout = time.Minute
+
+// TimeoutError is returned by TimeoutTransport when a request took too long.
+type TimeoutError struct {
+	Path    string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s: no response within %v", e.Path, e.Timeout)
+}
+
+// TimeoutTransport aborts requests which take longer than a fixed time.
+type TimeoutTransport struct {
+	transport Transport
+	timeout   time.Duration
+}
+
+// NewTimeoutTransport wraps t so that every request is limited to timeout.
+func NewTimeoutTransport(t Transport, timeout time.Duration) *TimeoutTransport {
+	return &TimeoutTransport{transport: t, timeout: timeout}
+}
+
+// Get issues the request with a deadline. When the deadline is hit, but
+// ctx is not done, a *TimeoutError is returned.
+func (t *TimeoutTransport) Get(ctx context.Context, path string) ([]byte, error) {
+	requestCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	body, err := t.transport.Get(requestCtx, path)
+	if nil != err && ctx.Err() == nil && requestCtx.Err() == context.DeadlineExceeded {
+		return nil, &TimeoutError{Path: path, Timeout: t.timeout}
+	}
+	return body, err
+}
@@ -0,0 +1,177 @@
+package apihelper
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// jwt returns an unsigned token which expires at exp
+func jwt(exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix())))
+	return "bearer header." + payload + ".signature"
+}
+
+// hangingTransport does not respond before ctx is done
+type hangingTransport struct{}
+
+func (hangingTransport) Get(ctx context.Context, path string) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+var _ = Describe("HTTP transport", func() {
+	var fakeCliConnection *pluginfakes.FakeCliConnection
+	var server *httptest.Server
+	var handler http.HandlerFunc
+	var validToken string
+	var requests int32
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&requests, 0)
+		validToken = jwt(time.Now().Add(time.Hour))
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != validToken {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"code":1000,"description":"Invalid Auth Token","error_code":"CF-InvalidAuthToken"}`)
+				return
+			}
+			fmt.Fprint(w, `{"memory_usage_in_mb": 512}`)
+		}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			handler(w, r)
+		}))
+
+		fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		fakeCliConnection.ApiEndpointReturns(server.URL, nil)
+		fakeCliConnection.AccessTokenReturns(validToken, nil)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("talks to the API endpoint of the CLI with its access token", func() {
+		transport, err := NewHTTPTransport(fakeCliConnection)
+		Expect(err).To(BeNil())
+		api := NewWithTransport(transport)
+
+		usage, err := api.GetOrgMemoryUsage(ctx, Organization{URL: "/v2/organizations/1234"})
+		Expect(err).To(BeNil())
+		Expect(usage).To(Equal(float64(512)))
+		Expect(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(0))
+	})
+
+	It("asks the CLI for the token only once while it is valid", func() {
+		transport, _ := NewHTTPTransport(fakeCliConnection)
+		transport.Get(ctx, "/v2/organizations/1234/memory_usage")
+		transport.Get(ctx, "/v2/organizations/1234/memory_usage")
+		Expect(fakeCliConnection.AccessTokenCallCount()).To(Equal(1))
+	})
+
+	It("refreshes a token which is about to expire", func() {
+		fakeCliConnection.AccessTokenReturns(jwt(time.Now().Add(10*time.Second)), nil)
+		transport, _ := NewHTTPTransport(fakeCliConnection)
+		transport.Get(ctx, "/v2/organizations/1234/memory_usage")
+		fakeCliConnection.AccessTokenReturns(validToken, nil)
+		_, err := transport.Get(ctx, "/v2/organizations/1234/memory_usage")
+		Expect(err).To(BeNil())
+		Expect(fakeCliConnection.AccessTokenCallCount()).To(Equal(3))
+	})
+
+	It("refreshes the token and retries once on 401", func() {
+		stale := jwt(time.Now().Add(time.Hour).Add(time.Minute))
+		fakeCliConnection.AccessTokenStub = func() (string, error) {
+			if fakeCliConnection.AccessTokenCallCount() == 1 {
+				return stale, nil
+			}
+			return validToken, nil
+		}
+		transport, _ := NewHTTPTransport(fakeCliConnection)
+		_, err := transport.Get(ctx, "/v2/organizations/1234/memory_usage")
+		Expect(err).To(BeNil())
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+	})
+
+	It("returns the status and Cloud Controller error on failure", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"code":10003,"description":"You are not authorized","error_code":"CF-NotAuthorized"}`)
+		}
+		transport, _ := NewHTTPTransport(fakeCliConnection)
+		_, err := transport.Get(ctx, "/v2/organizations/1234/memory_usage")
+		apiErr, ok := err.(*APIError)
+		Expect(ok).To(BeTrue())
+		Expect(apiErr.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(apiErr.ErrorCode).To(Equal("CF-NotAuthorized"))
+	})
+
+	It("returns the Retry-After delay of rate limited requests", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"errors":[{"code":10013,"title":"CF-RateLimitExceeded","detail":"Rate Limit Exceeded"}]}`)
+		}
+		transport, _ := NewHTTPTransport(fakeCliConnection)
+		_, err := transport.Get(ctx, "/v2/organizations/1234/memory_usage")
+		apiErr, ok := err.(*APIError)
+		Expect(ok).To(BeTrue())
+		Expect(apiErr.StatusCode).To(Equal(http.StatusTooManyRequests))
+		Expect(apiErr.RetryAfter).To(Equal(3 * time.Second))
+	})
+
+	It("fails without an API endpoint", func() {
+		fakeCliConnection.ApiEndpointReturns("", nil)
+		_, err := NewHTTPTransport(fakeCliConnection)
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("uses cf curl as fallback", func() {
+		transport, err := NewTransport(fakeCliConnection, TransportCurl)
+		Expect(err).To(BeNil())
+		_, isCurl := transport.(*CurlTransport)
+		Expect(isCurl).To(BeTrue())
+	})
+})
+
+var _ = Describe("Request timeout", func() {
+	It("returns a timeout error for slow requests", func() {
+		transport := NewTimeoutTransport(hangingTransport{}, 10*time.Millisecond)
+		_, err := transport.Get(ctx, "/v2/organizations")
+		timeoutErr, ok := err.(*TimeoutError)
+		Expect(ok).To(BeTrue())
+		Expect(timeoutErr.Path).To(Equal("/v2/organizations"))
+	})
+
+	It("returns the error of the cancelled context", func() {
+		cancelCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		transport := NewTimeoutTransport(hangingTransport{}, time.Minute)
+		_, err := transport.Get(cancelCtx, "/v2/organizations")
+		Expect(err).To(Equal(context.Canceled))
+	})
+
+	It("aborts HTTP requests", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+		fakeCliConnection := &pluginfakes.FakeCliConnection{}
+		fakeCliConnection.ApiEndpointReturns(server.URL, nil)
+		fakeCliConnection.AccessTokenReturns(jwt(time.Now().Add(time.Hour)), nil)
+
+		httpTransport, _ := NewHTTPTransport(fakeCliConnection)
+		_, err := NewTimeoutTransport(httpTransport, 10*time.Millisecond).Get(ctx, "/v2/organizations")
+		_, ok := err.(*TimeoutError)
+		Expect(ok).To(BeTrue())
+	})
+})
@@ -0,0 +1,278 @@
+package apihelper
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Connection is the part of the CLI connection HTTPTransport needs to talk
+// to a foundation. It is implemented by plugin.CliConnection for the
+// foundation the CLI targets and by UAAConnection for any other.
+type Connection interface {
+	ApiEndpoint() (string, error)
+	IsSSLDisabled() (bool, error)
+	AccessToken() (string, error)
+	UserGuid() (string, error)
+}
+
+// defaultUAAClient is the OAuth client of the cf CLI, it has no secret.
+const defaultUAAClient = "cf"
+
+// UAAConnection provides the API endpoint and access tokens of a foundation
+// without the CLI being logged in to it. Tokens are requested from UAA and
+// refreshed with the refresh token UAA returns.
+type UAAConnection struct {
+	endpoint     string
+	sslDisabled  bool
+	clientID     string
+	clientSecret string
+	client       *http.Client
+
+	mu    sync.Mutex
+	uaa   string     // UAA URL, looked up in the API root if empty
+	grant url.Values // next token request
+	token string
+}
+
+func newUAAConnection(endpoint, clientID, clientSecret string, sslDisabled bool, grant url.Values) *UAAConnection {
+	if clientID == "" {
+		clientID = defaultUAAClient
+	}
+	return &UAAConnection{
+		endpoint:     strings.TrimRight(endpoint, "/"),
+		sslDisabled:  sslDisabled,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		client:       newUAAClient(sslDisabled),
+		grant:        grant,
+	}
+}
+
+// NewPasswordConnection returns a Connection which logs in to the API
+// endpoint with username and password.
+func NewPasswordConnection(endpoint, username, password string, sslDisabled bool) *UAAConnection {
+	return newUAAConnection(endpoint, "", "", sslDisabled, url.Values{
+		"grant_type": {"password"},
+		"username":   {username},
+		"password":   {password},
+	})
+}
+
+// NewClientCredentialsConnection returns a Connection which authenticates
+// as the UAA client itself, e.g. for scheduled reports. The client needs
+// the cloud_controller.admin_read_only or global_auditor authority.
+func NewClientCredentialsConnection(endpoint, clientID, clientSecret string, sslDisabled bool) *UAAConnection {
+	return newUAAConnection(endpoint, clientID, clientSecret, sslDisabled, url.Values{
+		"grant_type": {"client_credentials"},
+	})
+}
+
+// NewRefreshTokenConnection returns a Connection which gets its tokens with
+// a refresh token issued to clientID, by default the client of the cf CLI.
+func NewRefreshTokenConnection(endpoint, clientID, clientSecret, refreshToken string, sslDisabled bool) *UAAConnection {
+	return newUAAConnection(endpoint, clientID, clientSecret, sslDisabled, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+}
+
+// cfConfig is the part of the cf CLI config.json needed to connect.
+type cfConfig struct {
+	Target               string
+	UaaEndpoint          string
+	AccessToken          string
+	RefreshToken         string
+	UAAOAuthClient       string
+	UAAOAuthClientSecret string
+	SSLDisabled          bool
+}
+
+// NewCFHomeConnection returns a Connection to the foundation the cf CLI
+// with the given CF_HOME is logged in to. The CLI config is not updated
+// when the token is refreshed.
+func NewCFHomeConnection(cfHome string) (*UAAConnection, error) {
+	path := filepath.Join(cfHome, ".cf", "config.json")
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, fmt.Errorf("reading cf CLI config: %v", err)
+	}
+	var config cfConfig
+	if err := json.Unmarshal(data, &config); nil != err {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	if config.Target == "" {
+		return nil, fmt.Errorf("%s: no API endpoint set, use cf api or cf login", path)
+	}
+	if config.AccessToken == "" && config.RefreshToken == "" {
+		return nil, fmt.Errorf("%s: not logged in, use cf login", path)
+	}
+
+	conn := NewRefreshTokenConnection(config.Target, config.UAAOAuthClient, config.UAAOAuthClientSecret,
+		config.RefreshToken, config.SSLDisabled)
+	conn.uaa = strings.TrimRight(config.UaaEndpoint, "/")
+	conn.token = config.AccessToken
+	return conn, nil
+}
+
+// newUAAClient returns the client for token requests. They can not be
+// cancelled, so they are limited to the DefaultRequestTimeout.
+func newUAAClient(sslDisabled bool) *http.Client {
+	client := newHTTPClient(sslDisabled)
+	client.Timeout = DefaultRequestTimeout
+	return client
+}
+
+// ApiEndpoint returns the API endpoint of the foundation.
+func (c *UAAConnection) ApiEndpoint() (string, error) {
+	return c.endpoint, nil
+}
+
+// IsSSLDisabled tells whether certificates are not verified.
+func (c *UAAConnection) IsSSLDisabled() (bool, error) {
+	return c.sslDisabled, nil
+}
+
+// AccessToken returns the current token or requests a new one from UAA
+// if it is about to expire.
+func (c *UAAConnection) AccessToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" {
+		expires := tokenExpiry(c.token)
+		if expires.IsZero() || time.Now().Add(tokenExpiryMargin).Before(expires) {
+			return c.token, nil
+		}
+	}
+	if err := c.requestToken(); nil != err {
+		return "", fmt.Errorf("getting access token from UAA: %v", err)
+	}
+	return c.token, nil
+}
+
+// RefreshAccessToken requests a new token from UAA although the current
+// one has not expired yet, e.g. because the API rejected it.
+func (c *UAAConnection) RefreshAccessToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.requestToken(); nil != err {
+		return "", fmt.Errorf("refreshing access token with UAA: %v", err)
+	}
+	return c.token, nil
+}
+
+// UserGuid returns the user the token was issued for, or the client for
+// tokens without user.
+func (c *UAAConnection) UserGuid() (string, error) {
+	token, err := c.AccessToken()
+	if nil != err {
+		return "", err
+	}
+	var claims struct {
+		UserID   string `json:"user_id"`
+		ClientID string `json:"client_id"`
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) == 3 {
+		if payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "=")); nil == err {
+			json.Unmarshal(payload, &claims)
+		}
+	}
+	if claims.UserID != "" {
+		return claims.UserID, nil
+	}
+	return claims.ClientID, nil
+}
+
+// tokenResponse is the answer of UAA to a token request.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	Error        string `json:"error"`
+	Description  string `json:"error_description"`
+}
+
+// requestToken requests a token with the current grant. Later tokens are
+// requested with the refresh token, if UAA returned one.
+func (c *UAAConnection) requestToken() error {
+	if c.uaa == "" {
+		uaa, err := c.lookupUAA()
+		if nil != err {
+			return err
+		}
+		c.uaa = uaa
+	}
+
+	req, err := http.NewRequest("POST", c.uaa+"/oauth/token", strings.NewReader(c.grant.Encode()))
+	if nil != err {
+		return err
+	}
+	req.SetBasicAuth(c.clientID, c.clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); nil != err && resp.StatusCode == http.StatusOK {
+		return fmt.Errorf("parsing token response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if token.Description != "" {
+			return fmt.Errorf("%s: %s", http.StatusText(resp.StatusCode), token.Description)
+		}
+		return fmt.Errorf("%s", http.StatusText(resp.StatusCode))
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf("token response without access token")
+	}
+
+	tokenType := token.TokenType
+	if tokenType == "" {
+		tokenType = "bearer"
+	}
+	c.token = tokenType + " " + token.AccessToken
+	if token.RefreshToken != "" {
+		c.grant = url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {token.RefreshToken},
+		}
+	}
+	return nil
+}
+
+// lookupUAA returns the UAA URL linked in the API root.
+func (c *UAAConnection) lookupUAA() (string, error) {
+	resp, err := c.client.Get(c.endpoint + "/")
+	if nil != err {
+		return "", fmt.Errorf("looking up UAA: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var root struct {
+		Links struct {
+			UAA *v3Link `json:"uaa"`
+		} `json:"links"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&root); nil != err {
+		return "", fmt.Errorf("looking up UAA: %v", err)
+	}
+	if root.Links.UAA == nil || root.Links.UAA.Href == "" {
+		return "", fmt.Errorf("looking up UAA: API root of %s does not link it", c.endpoint)
+	}
+	return strings.TrimRight(root.Links.UAA.Href, "/"), nil
+}
@@ -0,0 +1,131 @@
+package apihelper
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgruber/usagereport-plugin/apihelper/fakecc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UAA connection", func() {
+	var server *fakecc.Server
+	var token string
+
+	BeforeEach(func() {
+		payload := base64.RawURLEncoding.EncodeToString([]byte(
+			fmt.Sprintf(`{"exp":%d,"user_id":"user-guid"}`, time.Now().Add(time.Hour).Unix())))
+		token = "bearer header." + payload + ".signature"
+
+		server = fakecc.New(fakecc.Foundation{
+			Orgs: []fakecc.Org{{GUID: "o-1", Name: "org-1"}},
+		})
+		server.Token = token
+		server.Users = map[string]string{"admin": "secret"}
+		server.RefreshToken = "refresh-token"
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("logs in with username and password", func() {
+		conn := NewPasswordConnection(server.URL+"/", "admin", "secret", false)
+		Expect(conn.AccessToken()).To(Equal(token))
+		Expect(conn.ApiEndpoint()).To(Equal(server.URL))
+		Expect(conn.UserGuid()).To(Equal("user-guid"))
+
+		transport, err := NewHTTPTransport(conn)
+		Expect(err).To(BeNil())
+		orgs, err := NewWithTransport(transport).GetOrgs(ctx)
+		Expect(err).To(BeNil())
+		Expect(orgs).To(HaveLen(1))
+	})
+
+	It("authenticates with client credentials", func() {
+		server.Clients = map[string]string{"usage-reporter": "client-secret"}
+		conn := NewClientCredentialsConnection(server.URL, "usage-reporter", "client-secret", false)
+		Expect(conn.AccessToken()).To(Equal(token))
+
+		_, err := NewClientCredentialsConnection(server.URL, "usage-reporter", "guess", false).AccessToken()
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("gets the token with a refresh token", func() {
+		Expect(NewRefreshTokenConnection(server.URL, "", "", "refresh-token", false).AccessToken()).To(Equal(token))
+		_, err := NewRefreshTokenConnection(server.URL, "", "", "revoked", false).AccessToken()
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("fails for a wrong password", func() {
+		_, err := NewPasswordConnection(server.URL, "admin", "guess", false).AccessToken()
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Bad credentials"))
+	})
+
+	Describe("CF_HOME", func() {
+		var cfHome string
+
+		writeConfig := func(config string) {
+			Expect(os.MkdirAll(filepath.Join(cfHome, ".cf"), 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(cfHome, ".cf", "config.json"), []byte(config), 0600)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			var err error
+			cfHome, err = ioutil.TempDir("", "usagereport-cfhome")
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(cfHome)
+		})
+
+		It("uses the token of the cf CLI config", func() {
+			writeConfig(fmt.Sprintf(`{"Target": %q, "AccessToken": %q, "RefreshToken": "old"}`, server.URL, token))
+			conn, err := NewCFHomeConnection(cfHome)
+			Expect(err).To(BeNil())
+			Expect(conn.AccessToken()).To(Equal(token))
+			Expect(server.Requests()).To(BeEmpty())
+		})
+
+		It("refreshes an expired token", func() {
+			writeConfig(fmt.Sprintf(`{"Target": %q, "UaaEndpoint": %q, "AccessToken": %q, "RefreshToken": "refresh-token"}`,
+				server.URL, server.URL, jwt(time.Now().Add(-time.Minute))))
+			conn, err := NewCFHomeConnection(cfHome)
+			Expect(err).To(BeNil())
+			Expect(conn.AccessToken()).To(Equal(token))
+		})
+
+		It("refreshes a token the API rejects before it expires", func() {
+			writeConfig(fmt.Sprintf(`{"Target": %q, "UaaEndpoint": %q, "AccessToken": %q, "RefreshToken": "refresh-token"}`,
+				server.URL, server.URL, jwt(time.Now().Add(time.Hour))))
+			conn, err := NewCFHomeConnection(cfHome)
+			Expect(err).To(BeNil())
+
+			transport, err := NewHTTPTransport(conn)
+			Expect(err).To(BeNil())
+			orgs, err := NewWithTransport(transport).GetOrgs(ctx)
+			Expect(err).To(BeNil())
+			Expect(orgs).To(HaveLen(1))
+			Expect(conn.AccessToken()).To(Equal(token))
+			Expect(server.Requests()).To(ContainElement("/oauth/token"))
+		})
+
+		It("fails if the CLI is not logged in", func() {
+			writeConfig(fmt.Sprintf(`{"Target": %q}`, server.URL))
+			_, err := NewCFHomeConnection(cfHome)
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("fails without config", func() {
+			_, err := NewCFHomeConnection(filepath.Join(cfHome, "missing"))
+			Expect(err).ToNot(BeNil())
+		})
+	})
+})
@@ -0,0 +1,747 @@
+package apihelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/cloudfoundry/cli/plugin"
+)
+
+// DefaultPerPage is the page size requested from v3 list endpoints. 5000 is
+// the maximum the Cloud Controller accepts.
+const DefaultPerPage = 5000
+
+// processQueryChunk limits the number of app GUIDs per /v3/processes query
+// to keep the URL short.
+const processQueryChunk = 50
+
+type v3Link struct {
+	Href string `json:"href"`
+}
+
+type v3Pagination struct {
+	TotalResults int     `json:"total_results"`
+	TotalPages   int     `json:"total_pages"`
+	Next         *v3Link `json:"next"`
+}
+
+// v3Page is a single page of a v3 list response.
+type v3Page struct {
+	Pagination v3Pagination      `json:"pagination"`
+	Resources  []json.RawMessage `json:"resources"`
+}
+
+// v3Relationship is a to-one relationship of a v3 resource. Data is null
+// when the related resource does not exist.
+type v3Relationship struct {
+	Data *struct {
+		GUID string `json:"guid"`
+	} `json:"data"`
+}
+
+func (r v3Relationship) guid() string {
+	if r.Data == nil {
+		return ""
+	}
+	return r.Data.GUID
+}
+
+type v3Org struct {
+	GUID          string `json:"guid"`
+	Name          string `json:"name"`
+	Relationships struct {
+		Quota v3Relationship `json:"quota"`
+	} `json:"relationships"`
+}
+
+type v3Space struct {
+	GUID          string `json:"guid"`
+	Name          string `json:"name"`
+	Relationships struct {
+		Organization v3Relationship `json:"organization"`
+		Quota        v3Relationship `json:"quota"`
+	} `json:"relationships"`
+}
+
+type v3App struct {
+	GUID          string `json:"guid"`
+	Name          string `json:"name"`
+	State         string `json:"state"`
+	Relationships struct {
+		Space v3Relationship `json:"space"`
+	} `json:"relationships"`
+
+	// Lifecycle is only read for the builds of the apps.
+	Lifecycle v3Lifecycle `json:"lifecycle"`
+}
+
+type v3Process struct {
+	GUID          string   `json:"guid"`
+	Type          string   `json:"type"`
+	Instances     *float64 `json:"instances"`
+	MemoryInMB    *float64 `json:"memory_in_mb"`
+	DiskInMB      *float64 `json:"disk_in_mb"`
+	Relationships struct {
+		App v3Relationship `json:"app"`
+	} `json:"relationships"`
+}
+
+type v3OrgQuota struct {
+	GUID string `json:"guid"`
+	Name string `json:"name"`
+	Apps struct {
+		TotalMemoryInMB      *float64 `json:"total_memory_in_mb"`
+		PerProcessMemoryInMB *float64 `json:"per_process_memory_in_mb"`
+		TotalInstances       *float64 `json:"total_instances"`
+	} `json:"apps"`
+	Services struct {
+		TotalServiceInstances *float64 `json:"total_service_instances"`
+		TotalServiceKeys      *float64 `json:"total_service_keys"`
+	} `json:"services"`
+	Routes struct {
+		TotalRoutes        *float64 `json:"total_routes"`
+		TotalReservedPorts *float64 `json:"total_reserved_ports"`
+	} `json:"routes"`
+}
+
+type v3UsageSummary struct {
+	UsageSummary struct {
+		MemoryInMB *float64 `json:"memory_in_mb"`
+	} `json:"usage_summary"`
+}
+
+type v3ServiceInstance struct {
+	GUID          string `json:"guid"`
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	Relationships struct {
+		ServicePlan v3Relationship `json:"service_plan"`
+		Space       v3Relationship `json:"space"`
+	} `json:"relationships"`
+}
+
+type v3ServicePlan struct {
+	GUID          string `json:"guid"`
+	Name          string `json:"name"`
+	Relationships struct {
+		ServiceOffering v3Relationship `json:"service_offering"`
+	} `json:"relationships"`
+}
+
+type v3ServiceOffering struct {
+	GUID string `json:"guid"`
+	Name string `json:"name"`
+}
+
+type v3ServiceCredentialBinding struct {
+	GUID          string `json:"guid"`
+	Type          string `json:"type"`
+	Relationships struct {
+		App             v3Relationship `json:"app"`
+		ServiceInstance v3Relationship `json:"service_instance"`
+	} `json:"relationships"`
+}
+
+// v2 names of the service instance types, which the report relies on
+const (
+	managedServiceInstanceType      = "managed_service_instance"
+	userProvidedServiceInstanceType = "user_provided_service_instance"
+)
+
+// APIHelperV3 implements CFAPIHelper on top of the /v3 endpoints of the Cloud
+// Controller. The URLs it puts into Organization, Space and App point to v3
+// endpoints and are only understood by APIHelperV3 itself.
+type APIHelperV3 struct {
+	transport Transport
+	perPage   int
+	scope     Scope
+}
+
+// NewV3 returns a CFAPIHelper using the v3 API through cf curl.
+func NewV3(cli plugin.CliConnection) CFAPIHelper {
+	return NewV3WithTransport(NewCurlTransport(cli))
+}
+
+// NewV3WithTransport returns a CFAPIHelper using the v3 API through t.
+func NewV3WithTransport(t Transport) CFAPIHelper {
+	return &APIHelperV3{transport: t, perPage: DefaultPerPage}
+}
+
+// getAllPages fetches the v3 list at path and follows pagination.next until
+// the last page was processed. fn is called for every resource.
+func (api *APIHelperV3) getAllPages(ctx context.Context, path, resource string, fn func(raw json.RawMessage) error) error {
+	next := withPageSize(path, "per_page", api.perPage)
+
+	for page := 1; next != ""; page++ {
+		var p v3Page
+		if err := getJSON(ctx, api.transport, next, resource+" list", &p); err != nil {
+			return &PageError{Path: next, Page: page, Err: err}
+		}
+
+		for _, r := range p.Resources {
+			if err := fn(r); err != nil {
+				return &PageError{Path: next, Page: page, TotalPages: p.Pagination.TotalPages, Err: err}
+			}
+		}
+
+		next = ""
+		if p.Pagination.Next != nil {
+			next = requestPath(p.Pagination.Next.Href)
+		}
+		if p.Pagination.TotalPages > 0 && page >= p.Pagination.TotalPages {
+			break
+		}
+	}
+	return nil
+}
+
+// requestPath strips scheme and host from the absolute links returned by
+// the v3 API as cf curl expects a path.
+func requestPath(href string) string {
+	u, err := url.Parse(href)
+	if err != nil || u.Host == "" {
+		return href
+	}
+	return u.RequestURI()
+}
+
+// decodeResource decodes a single v3 resource into v.
+func decodeResource(resource string, raw json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(raw, v); err != nil {
+		var id struct {
+			GUID string `json:"guid"`
+		}
+		json.Unmarshal(raw, &id)
+		return newDecodeError(resource, id.GUID, err)
+	}
+	return nil
+}
+
+func v3OrgToOrg(o v3Org) Organization {
+	org := Organization{
+		GUID:      o.GUID,
+		Name:      o.Name,
+		URL:       "/v3/organizations/" + o.GUID,
+		SpacesURL: "/v3/spaces?organization_guids=" + o.GUID,
+	}
+	if quotaGUID := o.Relationships.Quota.guid(); quotaGUID != "" {
+		org.QuotaGUID = quotaGUID
+		org.QuotaURL = "/v3/organization_quotas/" + quotaGUID
+	}
+	return org
+}
+
+func (api *APIHelperV3) getOrgs(ctx context.Context, path string) ([]v3Org, error) {
+	orgs := []v3Org{}
+	err := api.getAllPages(ctx, path, "organization", func(raw json.RawMessage) error {
+		var o v3Org
+		if err := decodeResource("organization", raw, &o); nil != err {
+			return err
+		}
+		if err := requireFields("organization", o.GUID,
+			"guid", o.GUID,
+			"name", o.Name); nil != err {
+			return err
+		}
+		orgs = append(orgs, o)
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+// GetOrgs returns all organizations visible to the user.
+func (api *APIHelperV3) GetOrgs(ctx context.Context) ([]Organization, error) {
+	rawOrgs, err := api.getOrgs(ctx, api.scope.v3List("/v3/organizations", "guids", ""))
+	if nil != err {
+		return nil, err
+	}
+	orgs := make([]Organization, 0, len(rawOrgs))
+	for _, o := range rawOrgs {
+		orgs = append(orgs, v3OrgToOrg(o))
+	}
+	return orgs, nil
+}
+
+// GetOrg returns the organization with the given name.
+func (api *APIHelperV3) GetOrg(ctx context.Context, name string) (Organization, error) {
+	rawOrgs, err := api.getOrgs(ctx, "/v3/organizations?names="+url.QueryEscape(name))
+	if nil != err {
+		return Organization{}, err
+	}
+	if len(rawOrgs) == 0 {
+		return Organization{}, ErrOrgNotFound
+	}
+	return v3OrgToOrg(rawOrgs[0]), nil
+}
+
+// GetQuotaMemoryLimit returns the memory limit (in MB) of an organization
+// quota. Unlimited quotas are reported as -1 like in the v2 API.
+func (api *APIHelperV3) GetQuotaMemoryLimit(ctx context.Context, quotaURL string) (float64, error) {
+	if quotaURL == "" {
+		return 0, ErrNoQuota
+	}
+	var quota v3OrgQuota
+	if err := getJSON(ctx, api.transport, quotaURL, "organization quota", &quota); nil != err {
+		return 0, err
+	}
+	return quota.memoryLimit(), nil
+}
+
+// memoryLimit returns the total memory limit of the quota or -1 if it is
+// unlimited.
+func (q v3OrgQuota) memoryLimit() float64 {
+	return limit(q.Apps.TotalMemoryInMB)
+}
+
+// quota returns the limits of the quota, null limits are unlimited.
+func (q v3OrgQuota) quota() Quota {
+	return Quota{
+		GUID:                    q.GUID,
+		Name:                    q.Name,
+		MemoryLimit:             q.memoryLimit(),
+		InstanceMemoryLimit:     limit(q.Apps.PerProcessMemoryInMB),
+		AppInstanceLimit:        limit(q.Apps.TotalInstances),
+		TotalServices:           limit(q.Services.TotalServiceInstances),
+		TotalServiceKeys:        limit(q.Services.TotalServiceKeys),
+		TotalRoutes:             limit(q.Routes.TotalRoutes),
+		TotalReservedRoutePorts: limit(q.Routes.TotalReservedPorts),
+	}
+}
+
+// GetQuotaMap returns all organization quotas by GUID.
+func (api *APIHelperV3) GetQuotaMap(ctx context.Context) (map[string]Quota, error) {
+	qmap := make(map[string]Quota, 8)
+	err := api.getAllPages(ctx, api.scope.v3List("/v3/organization_quotas", "organization_guids", ""), "organization quota", func(raw json.RawMessage) error {
+		var q v3OrgQuota
+		if err := decodeResource("organization quota", raw, &q); nil != err {
+			return err
+		}
+		if err := requireFields("organization quota", q.GUID, "guid", q.GUID); nil != err {
+			return err
+		}
+		qmap[q.GUID] = q.quota()
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return qmap, nil
+}
+
+// GetSpaceQuotaMap returns all space quotas by GUID. They have the same
+// app limits as organization quotas.
+func (api *APIHelperV3) GetSpaceQuotaMap(ctx context.Context) (map[string]Quota, error) {
+	qmap := make(map[string]Quota, 8)
+	err := api.getAllPages(ctx, api.scope.v3List("/v3/space_quotas", "organization_guids", "space_guids"), "space quota", func(raw json.RawMessage) error {
+		var q v3OrgQuota
+		if err := decodeResource("space quota", raw, &q); nil != err {
+			return err
+		}
+		if err := requireFields("space quota", q.GUID, "guid", q.GUID); nil != err {
+			return err
+		}
+		qmap[q.GUID] = q.quota()
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return qmap, nil
+}
+
+// GetOrgMemoryUsage returns the amount of memory (in MB) that the org is
+// consuming according to its usage summary.
+func (api *APIHelperV3) GetOrgMemoryUsage(ctx context.Context, org Organization) (float64, error) {
+	var summary v3UsageSummary
+	if err := getJSON(ctx, api.transport, org.URL+"/usage_summary", "organization usage summary", &summary); nil != err {
+		return 0, err
+	}
+	if summary.UsageSummary.MemoryInMB == nil {
+		return 0, &DecodeError{Resource: "organization usage summary", GUID: org.URL,
+			Field: "usage_summary.memory_in_mb", Err: errFieldNull}
+	}
+	return *summary.UsageSummary.MemoryInMB, nil
+}
+
+// GetOrgSpaces returns the spaces of the org behind spacesURL.
+func (api *APIHelperV3) GetOrgSpaces(ctx context.Context, spacesURL string) ([]Space, error) {
+	spaces := []Space{}
+	err := api.getAllPages(ctx, spacesURL, "space", func(raw json.RawMessage) error {
+		var s v3Space
+		if err := decodeResource("space", raw, &s); nil != err {
+			return err
+		}
+		if err := requireFields("space", s.GUID,
+			"guid", s.GUID,
+			"name", s.Name); nil != err {
+			return err
+		}
+		spaces = append(spaces, Space{
+			GUID:      s.GUID,
+			Name:      s.Name,
+			AppsURL:   "/v3/apps?space_guids=" + s.GUID,
+			QuotaGUID: s.Relationships.Quota.guid(),
+		})
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return spaces, nil
+}
+
+// GetSpaceApps returns the apps behind appsURL. Instances and memory are
+// taken from the web process of each app, which is what the v2 API reports
+// for an app.
+func (api *APIHelperV3) GetSpaceApps(ctx context.Context, appsURL string) ([]App, error) {
+	rawApps, err := api.getApps(ctx, appsURL)
+	if nil != err {
+		return nil, err
+	}
+
+	guids := make([]string, 0, len(rawApps))
+	for _, a := range rawApps {
+		guids = append(guids, a.GUID)
+	}
+	processes, err := api.getAppProcesses(ctx, guids)
+	if nil != err {
+		return nil, err
+	}
+	return v3AppsToApps(rawApps, processes), nil
+}
+
+// GetApps returns all apps of the foundation. The processes are listed in
+// bulk instead of per app.
+func (api *APIHelperV3) GetApps(ctx context.Context) ([]App, error) {
+	rawApps, err := api.getApps(ctx, api.scope.v3List("/v3/apps", "organization_guids", "space_guids"))
+	if nil != err {
+		return nil, err
+	}
+	processes := make(map[string][]v3Process, len(rawApps))
+	if err := api.getProcesses(ctx, api.scope.v3List("/v3/processes", "organization_guids", "space_guids"), processes); nil != err {
+		return nil, err
+	}
+	return v3AppsToApps(rawApps, processes), nil
+}
+
+func (api *APIHelperV3) getApps(ctx context.Context, path string) ([]v3App, error) {
+	rawApps := []v3App{}
+	err := api.getAllPages(ctx, path, "app", func(raw json.RawMessage) error {
+		var a v3App
+		if err := decodeResource("app", raw, &a); nil != err {
+			return err
+		}
+		if err := requireFields("app", a.GUID,
+			"guid", a.GUID,
+			"name", a.Name); nil != err {
+			return err
+		}
+		rawApps = append(rawApps, a)
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return rawApps, nil
+}
+
+func v3AppsToApps(rawApps []v3App, processes map[string][]v3Process) []App {
+	apps := make([]App, 0, len(rawApps))
+	for _, a := range rawApps {
+		app := App{
+			Name:               a.Name,
+			GUID:               a.GUID,
+			SpaceGUID:          a.Relationships.Space.guid(),
+			Running:            "STARTED" == a.State,
+			ServiceBindingsURL: "/v3/service_credential_bindings?type=app&app_guids=" + a.GUID,
+		}
+		for _, p := range processes[a.GUID] {
+			app.Processes = append(app.Processes, Process{
+				Type:      p.Type,
+				Instances: *p.Instances,
+				RAM:       *p.MemoryInMB,
+				Disk:      valueOf(p.DiskInMB),
+			})
+		}
+		sortProcesses(app.Processes)
+		if len(app.Processes) > 0 && app.Processes[0].Type == webProcess {
+			app.Instances = app.Processes[0].Instances
+			app.RAM = app.Processes[0].RAM
+			app.Disk = app.Processes[0].Disk
+		}
+		apps = append(apps, app)
+	}
+	return apps
+}
+
+// getAppProcesses returns the processes of the given apps by app GUID.
+func (api *APIHelperV3) getAppProcesses(ctx context.Context, appGUIDs []string) (map[string][]v3Process, error) {
+	processes := make(map[string][]v3Process, len(appGUIDs))
+	for start := 0; start < len(appGUIDs); start += processQueryChunk {
+		end := start + processQueryChunk
+		if end > len(appGUIDs) {
+			end = len(appGUIDs)
+		}
+		path := "/v3/processes?app_guids=" + strings.Join(appGUIDs[start:end], ",")
+		if err := api.getProcesses(ctx, path, processes); nil != err {
+			return nil, err
+		}
+	}
+	return processes, nil
+}
+
+// getProcesses adds the processes behind path to processes by app GUID.
+func (api *APIHelperV3) getProcesses(ctx context.Context, path string, processes map[string][]v3Process) error {
+	return api.getAllPages(ctx, path, "process", func(raw json.RawMessage) error {
+		var p v3Process
+		if err := decodeResource("process", raw, &p); nil != err {
+			return err
+		}
+		if err := requireFields("process", p.GUID,
+			"relationships.app", p.Relationships.App.guid(),
+			"type", p.Type,
+			"instances", p.Instances,
+			"memory_in_mb", p.MemoryInMB); nil != err {
+			return err
+		}
+		appGUID := p.Relationships.App.guid()
+		processes[appGUID] = append(processes[appGUID], p)
+		return nil
+	})
+}
+
+func (api *APIHelperV3) getCredentialBindings(ctx context.Context, path string) ([]v3ServiceCredentialBinding, error) {
+	bindings := []v3ServiceCredentialBinding{}
+	err := api.getAllPages(ctx, path, "service credential binding", func(raw json.RawMessage) error {
+		var b v3ServiceCredentialBinding
+		if err := decodeResource("service credential binding", raw, &b); nil != err {
+			return err
+		}
+		if err := requireFields("service credential binding", b.GUID,
+			"relationships.service_instance", b.Relationships.ServiceInstance.guid()); nil != err {
+			return err
+		}
+		bindings = append(bindings, b)
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return bindings, nil
+}
+
+// GetServiceBindings returns the service bindings behind serviceBindingsURL.
+func (api *APIHelperV3) GetServiceBindings(ctx context.Context, serviceBindingsURL string) ([]ServiceBindings, error) {
+	bindings, err := api.getCredentialBindings(ctx, serviceBindingsURL)
+	if nil != err {
+		return nil, err
+	}
+	sbs := make([]ServiceBindings, 0, len(bindings))
+	for _, b := range bindings {
+		sbs = append(sbs, ServiceBindings{ServiceInstanceGUID: b.Relationships.ServiceInstance.guid()})
+	}
+	return sbs, nil
+}
+
+// GetServiceBindingsList returns all app bindings (app guid to service instance guid).
+func (api *APIHelperV3) GetServiceBindingsList(ctx context.Context) ([]ServiceBinding, error) {
+	paths, err := api.credentialBindingsPaths(ctx)
+	if nil != err {
+		return nil, err
+	}
+	var sbList []ServiceBinding
+	for _, path := range paths {
+		bindings, err := api.getCredentialBindings(ctx, path)
+		if nil != err {
+			return nil, err
+		}
+		for _, b := range bindings {
+			sbList = append(sbList, ServiceBinding{
+				AppGUID:             b.Relationships.App.guid(),
+				ServiceInstanceGUID: b.Relationships.ServiceInstance.guid(),
+			})
+		}
+	}
+	return sbList, nil
+}
+
+func (api *APIHelperV3) getServiceInstances(ctx context.Context, instanceType string) ([]v3ServiceInstance, error) {
+	instances := []v3ServiceInstance{}
+	err := api.getAllPages(ctx, api.scope.v3List("/v3/service_instances?type="+instanceType, "organization_guids", "space_guids"), "service instance", func(raw json.RawMessage) error {
+		var si v3ServiceInstance
+		if err := decodeResource("service instance", raw, &si); nil != err {
+			return err
+		}
+		if err := requireFields("service instance", si.GUID,
+			"guid", si.GUID,
+			"name", si.Name); nil != err {
+			return err
+		}
+		instances = append(instances, si)
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// GetServiceInstanceMap returns a map from service instance GUID to managed
+// service instance.
+func (api *APIHelperV3) GetServiceInstanceMap(ctx context.Context) (map[string]ServiceInstance, error) {
+	instances, err := api.getServiceInstances(ctx, "managed")
+	if nil != err {
+		return nil, err
+	}
+	simap := make(map[string]ServiceInstance, len(instances))
+	for _, si := range instances {
+		simap[si.GUID] = ServiceInstance{
+			GUID:            si.GUID,
+			Name:            si.Name,
+			Type:            managedServiceInstanceType,
+			ServicePlanGUID: si.Relationships.ServicePlan.guid(),
+			SpaceGUID:       si.Relationships.Space.guid(),
+		}
+	}
+	return simap, nil
+}
+
+// GetUserProvidedServiceMap returns a map from service instance GUID to user
+// provided service instance.
+func (api *APIHelperV3) GetUserProvidedServiceMap(ctx context.Context) (map[string]UserProvidedService, error) {
+	instances, err := api.getServiceInstances(ctx, "user-provided")
+	if nil != err {
+		return nil, err
+	}
+	upsMap := make(map[string]UserProvidedService, len(instances))
+	for _, si := range instances {
+		upsMap[si.GUID] = UserProvidedService{
+			GUID: si.GUID,
+			Name: si.Name,
+			Type: userProvidedServiceInstanceType,
+		}
+	}
+	return upsMap, nil
+}
+
+// GetServicePlanMap maps a service plan GUID to the plan and its service offering.
+func (api *APIHelperV3) GetServicePlanMap(ctx context.Context) (map[string]ServicePlan, error) {
+	spMap := make(map[string]ServicePlan, 32)
+	err := api.getAllPages(ctx, api.scope.v3List("/v3/service_plans", "organization_guids", "space_guids"), "service plan", func(raw json.RawMessage) error {
+		var sp v3ServicePlan
+		if err := decodeResource("service plan", raw, &sp); nil != err {
+			return err
+		}
+		if err := requireFields("service plan", sp.GUID, "guid", sp.GUID); nil != err {
+			return err
+		}
+		spMap[sp.GUID] = ServicePlan{
+			GUID:        sp.GUID,
+			Name:        sp.Name,
+			ServiceGUID: sp.Relationships.ServiceOffering.guid(),
+		}
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return spMap, nil
+}
+
+// GetServiceMap maps a service offering GUID to its name.
+func (api *APIHelperV3) GetServiceMap(ctx context.Context) (map[string]Service, error) {
+	sMap := make(map[string]Service, 32)
+	err := api.getAllPages(ctx, api.scope.v3List("/v3/service_offerings", "organization_guids", "space_guids"), "service offering", func(raw json.RawMessage) error {
+		var so v3ServiceOffering
+		if err := decodeResource("service offering", raw, &so); nil != err {
+			return err
+		}
+		if err := requireFields("service offering", so.GUID, "guid", so.GUID); nil != err {
+			return err
+		}
+		sMap[so.GUID] = Service{GUID: so.GUID, Label: so.Name}
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return sMap, nil
+}
+
+// GetSpaces returns all spaces in the order of the API.
+func (api *APIHelperV3) GetSpaces(ctx context.Context) ([]SpaceDetails, error) {
+	spaces := make([]SpaceDetails, 0, 32)
+	err := api.getAllPages(ctx, api.scope.v3List("/v3/spaces", "organization_guids", "guids"), "space", func(raw json.RawMessage) error {
+		var s v3Space
+		if err := decodeResource("space", raw, &s); nil != err {
+			return err
+		}
+		if err := requireFields("space", s.GUID, "guid", s.GUID); nil != err {
+			return err
+		}
+		spaces = append(spaces, SpaceDetails{
+			GUID:      s.GUID,
+			Name:      s.Name,
+			OrgGUID:   s.Relationships.Organization.guid(),
+			QuotaGUID: s.Relationships.Quota.guid(),
+		})
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return spaces, nil
+}
+
+// GetSpaceMap returns a map which has the GUID as key and the Name and GUID as value.
+func (api *APIHelperV3) GetSpaceMap(ctx context.Context) (map[string]SpaceDetails, error) {
+	spaces, err := api.GetSpaces(ctx)
+	if nil != err {
+		return nil, err
+	}
+	return SpaceMap(spaces), nil
+}
+
+// GetOrgMap returns a map from organization GUID to organization name.
+func (api *APIHelperV3) GetOrgMap(ctx context.Context) (map[string]OrgDetails, error) {
+	rawOrgs, err := api.getOrgs(ctx, api.scope.v3List("/v3/organizations", "guids", ""))
+	if nil != err {
+		return nil, err
+	}
+	omap := make(map[string]OrgDetails, len(rawOrgs))
+	for _, o := range rawOrgs {
+		omap[o.GUID] = OrgDetails{Name: o.Name}
+	}
+	return omap, nil
+}
+
+// rootInfo is the part of the API root document which tells which API
+// versions the Cloud Controller serves.
+type rootInfo struct {
+	Links struct {
+		CloudControllerV2 *v3Link `json:"cloud_controller_v2"`
+		CloudControllerV3 *v3Link `json:"cloud_controller_v3"`
+	} `json:"links"`
+}
+
+// DetectAPIVersion probes the API root and returns APIVersionV2 if the
+// Cloud Controller still serves the v2 API, otherwise APIVersionV3.
+func DetectAPIVersion(ctx context.Context, t Transport) (string, error) {
+	var root rootInfo
+	if err := getJSON(ctx, t, "/", "API root", &root); nil != err {
+		return "", fmt.Errorf("probing API version: %v", err)
+	}
+	if root.Links.CloudControllerV2 != nil && root.Links.CloudControllerV2.Href != "" {
+		return APIVersionV2, nil
+	}
+	if root.Links.CloudControllerV3 != nil && root.Links.CloudControllerV3.Href != "" {
+		return APIVersionV3, nil
+	}
+	return "", fmt.Errorf("probing API version: API root links neither cloud_controller_v2 nor cloud_controller_v3")
+}
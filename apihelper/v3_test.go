@@ -0,0 +1,279 @@
+package apihelper
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/cloudfoundry/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("v3 API", func() {
+	var api CFAPIHelper
+	var fakeCliConnection *pluginfakes.FakeCliConnection
+	var responses map[string]string
+
+	// serve returns the fixture of the longest path prefix matching the request
+	serve := func(args ...string) ([]string, error) {
+		match := ""
+		for prefix := range responses {
+			if strings.HasPrefix(args[1], prefix) && len(prefix) > len(match) {
+				match = prefix
+			}
+		}
+		if match == "" {
+			return nil, errors.New("unexpected request " + args[1])
+		}
+		return slurp(responses[match]), nil
+	}
+
+	BeforeEach(func() {
+		fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		fakeCliConnection.CliCommandWithoutTerminalOutputStub = serve
+		responses = map[string]string{}
+		api = NewV3(fakeCliConnection)
+	})
+
+	Describe("API version detection", func() {
+		It("prefers v2 while the foundation serves it", func() {
+			responses["/"] = "test-data/root-v2-and-v3.json"
+			version, err := DetectAPIVersion(ctx, NewCurlTransport(fakeCliConnection))
+			Expect(err).To(BeNil())
+			Expect(version).To(Equal(APIVersionV2))
+		})
+
+		It("falls back to v3 when v2 is disabled", func() {
+			responses["/"] = "test-data/root-v3-only.json"
+			helper, err := NewForAPIVersion(ctx, NewCurlTransport(fakeCliConnection), APIVersionAuto)
+			Expect(err).To(BeNil())
+			_, isV3 := helper.(*APIHelperV3)
+			Expect(isV3).To(BeTrue())
+		})
+
+		It("does not probe when the version is given", func() {
+			helper, err := NewForAPIVersion(ctx, NewCurlTransport(fakeCliConnection), APIVersionV2)
+			Expect(err).To(BeNil())
+			_, isV2 := helper.(*APIHelper)
+			Expect(isV2).To(BeTrue())
+			Expect(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("orgs", func() {
+		BeforeEach(func() {
+			responses["/v3/organizations"] = "test-data/v3-organizations-page-1.json"
+			responses["/v3/organizations?page=2"] = "test-data/v3-organizations-page-2.json"
+		})
+
+		It("follows the next link as a relative path", func() {
+			orgs, err := api.GetOrgs(ctx)
+			Expect(err).To(BeNil())
+			Expect(orgs).To(HaveLen(2))
+			args := fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)
+			Expect(args[1]).To(Equal("/v3/organizations?per_page=5000"))
+			args = fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(1)
+			Expect(args[1]).To(Equal("/v3/organizations?page=2&per_page=1"))
+		})
+
+		It("points the org URLs to v3 endpoints", func() {
+			orgs, _ := api.GetOrgs(ctx)
+			Expect(orgs[0].Name).To(Equal("jdk-org"))
+			Expect(orgs[0].URL).To(Equal("/v3/organizations/4e9ba0f1-4e6b-4c53-9e2b-7e0c6f4d2a11"))
+			Expect(orgs[0].QuotaURL).To(Equal("/v3/organization_quotas/9b370018-c38e-44c9-86d6-155c76801104"))
+			Expect(orgs[0].SpacesURL).To(Equal("/v3/spaces?organization_guids=4e9ba0f1-4e6b-4c53-9e2b-7e0c6f4d2a11"))
+			Expect(orgs[1].QuotaURL).To(Equal(""))
+		})
+
+		It("returns the org map", func() {
+			om, err := api.GetOrgMap(ctx)
+			Expect(err).To(BeNil())
+			Expect(om["7a1c2d3e-0000-4bbb-8ccc-123456789abc"].Name).To(Equal("test-org"))
+		})
+	})
+
+	Describe("quota and usage", func() {
+		It("returns the memory limit", func() {
+			responses["/v3/organization_quotas/"] = "test-data/v3-organization-quota.json"
+			limit, err := api.GetQuotaMemoryLimit(ctx, "/v3/organization_quotas/9b370018-c38e-44c9-86d6-155c76801104")
+			Expect(err).To(BeNil())
+			Expect(limit).To(Equal(float64(10240)))
+		})
+
+		It("returns -1 for unlimited memory", func() {
+			responses["/v3/organization_quotas/"] = "test-data/v3-organization-quota-unlimited.json"
+			limit, err := api.GetQuotaMemoryLimit(ctx, "/v3/organization_quotas/0c1d")
+			Expect(err).To(BeNil())
+			Expect(limit).To(Equal(float64(-1)))
+		})
+
+		It("returns all quotas with unlimited memory as -1", func() {
+			responses["/v3/organization_quotas"] = "test-data/v3-organization-quotas.json"
+			qm, err := api.GetQuotaMap(ctx)
+			Expect(err).To(BeNil())
+			Expect(qm["9b370018-c38e-44c9-86d6-155c76801104"].MemoryLimit).To(Equal(float64(10240)))
+			Expect(qm["0c1d2b6e-8b1f-4a51-9d47-5e2f0d0b7a3c"].MemoryLimit).To(Equal(float64(-1)))
+		})
+
+		It("returns all limits of the quotas with null as -1", func() {
+			responses["/v3/organization_quotas"] = "test-data/v3-organization-quotas.json"
+			qm, err := api.GetQuotaMap(ctx)
+			Expect(err).To(BeNil())
+			Expect(qm["9b370018-c38e-44c9-86d6-155c76801104"]).To(Equal(Quota{
+				GUID:                    "9b370018-c38e-44c9-86d6-155c76801104",
+				Name:                    "default",
+				MemoryLimit:             10240,
+				InstanceMemoryLimit:     2048,
+				AppInstanceLimit:        100,
+				TotalServices:           100,
+				TotalServiceKeys:        -1,
+				TotalRoutes:             1000,
+				TotalReservedRoutePorts: 0,
+			}))
+			Expect(qm["0c1d2b6e-8b1f-4a51-9d47-5e2f0d0b7a3c"].TotalRoutes).To(Equal(float64(-1)))
+		})
+
+		It("reads the routes with their ports", func() {
+			responses["/v3/routes"] = "test-data/v3-routes.json"
+			routes, err := api.GetRoutes(ctx)
+			Expect(err).To(BeNil())
+			Expect(routes).To(Equal([]Route{
+				{GUID: "c1d7e5a2-3b4f-4e6a-9c8d-0f1e2d3c4b5a", SpaceGUID: "81c310ed-d258-48d7-a57a-6522d93a4217"},
+				{GUID: "f0e9d8c7-b6a5-4d3c-8b2a-1f0e9d8c7b6a", SpaceGUID: "81c310ed-d258-48d7-a57a-6522d93a4217", Port: 1034},
+			}))
+		})
+
+		It("reads the usage summary", func() {
+			responses["/v3/organizations/"] = "test-data/v3-usage-summary.json"
+			usage, err := api.GetOrgMemoryUsage(ctx, Organization{URL: "/v3/organizations/4e9ba0f1-4e6b-4c53-9e2b-7e0c6f4d2a11"})
+			Expect(err).To(BeNil())
+			Expect(usage).To(Equal(float64(1536)))
+			args := fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)
+			Expect(args[1]).To(Equal("/v3/organizations/4e9ba0f1-4e6b-4c53-9e2b-7e0c6f4d2a11/usage_summary"))
+		})
+	})
+
+	Describe("spaces and apps", func() {
+		BeforeEach(func() {
+			responses["/v3/spaces"] = "test-data/v3-spaces.json"
+			responses["/v3/apps"] = "test-data/v3-apps.json"
+			responses["/v3/processes"] = "test-data/v3-processes.json"
+		})
+
+		It("returns the spaces with their apps URL", func() {
+			spaces, err := api.GetOrgSpaces(ctx, "/v3/spaces?organization_guids=4e9ba0f1-4e6b-4c53-9e2b-7e0c6f4d2a11")
+			Expect(err).To(BeNil())
+			Expect(spaces).To(HaveLen(2))
+			Expect(spaces[0].AppsURL).To(Equal("/v3/apps?space_guids=81c310ed-d258-48d7-a57a-6522d93a4217"))
+			Expect(spaces[0].QuotaGUID).To(Equal(""))
+			Expect(spaces[1].QuotaGUID).To(Equal("5d2a6c1e-9f3b-4e8a-b7c4-2a1e0f9d8c7b"))
+		})
+
+		It("takes instances, memory and disk from the web process and lists all processes", func() {
+			apps, err := api.GetSpaceApps(ctx, "/v3/apps?space_guids=81c310ed-d258-48d7-a57a-6522d93a4217")
+			Expect(err).To(BeNil())
+			Expect(apps).To(HaveLen(2))
+			Expect(apps[0].Name).To(Equal("ws"))
+			Expect(apps[0].Running).To(BeTrue())
+			Expect(apps[0].Instances).To(Equal(float64(2)))
+			Expect(apps[0].RAM).To(Equal(float64(1024)))
+			Expect(apps[0].Disk).To(Equal(float64(1024)))
+			Expect(apps[1].Running).To(BeFalse())
+			Expect(apps[1].RAM).To(Equal(float64(256)))
+			Expect(apps[1].Disk).To(Equal(float64(2048)))
+			Expect(apps[0].Processes).To(Equal([]Process{
+				{Type: "web", Instances: 2, RAM: 1024, Disk: 1024},
+				{Type: "worker", Instances: 3, RAM: 512, Disk: 1024},
+			}))
+			Expect(apps[1].Processes).To(HaveLen(1))
+
+			args := fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(1)
+			Expect(args[1]).To(HavePrefix("/v3/processes?app_guids=17ff8ef2-5f6a-4983-a23c-d52e785885d0,0c3c9d5e-0d84-4bd8-b6a3-7d3fa0b5b2a1"))
+		})
+
+		It("lists all apps with their processes in bulk", func() {
+			apps, err := api.GetApps(ctx)
+			Expect(err).To(BeNil())
+			Expect(apps).To(HaveLen(2))
+			Expect(apps[0].SpaceGUID).To(Equal("81c310ed-d258-48d7-a57a-6522d93a4217"))
+			Expect(apps[0].Instances).To(Equal(float64(2)))
+			Expect(apps[1].RAM).To(Equal(float64(256)))
+
+			Expect(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(2))
+			args := fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(1)
+			Expect(args[1]).To(Equal("/v3/processes?per_page=5000"))
+		})
+
+		It("returns the sidecars of an app", func() {
+			responses["/v3/apps/17ff8ef2-5f6a-4983-a23c-d52e785885d0/sidecars"] = "test-data/v3-sidecars.json"
+			sidecars, err := api.GetAppSidecars(ctx, "17ff8ef2-5f6a-4983-a23c-d52e785885d0")
+			Expect(err).To(BeNil())
+			Expect(sidecars).To(Equal([]Sidecar{{Name: "auth-sidecar", RAM: 300, ProcessTypes: []string{"web", "worker"}}}))
+		})
+
+		It("takes the builds of the apps from their lifecycle", func() {
+			builds, err := api.GetBuilds(ctx)
+			Expect(err).To(BeNil())
+			Expect(builds).To(Equal([]Build{
+				{AppGUID: "17ff8ef2-5f6a-4983-a23c-d52e785885d0", Stack: "cflinuxfs4", Buildpacks: []Buildpack{{Name: "nodejs_buildpack"}}},
+				{AppGUID: "0c3c9d5e-0d84-4bd8-b6a3-7d3fa0b5b2a1"},
+			}))
+			Expect(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(1))
+		})
+
+		It("takes the build of an app from its current droplet", func() {
+			responses["/v3/apps/17ff8ef2-5f6a-4983-a23c-d52e785885d0/droplets/current"] = "test-data/v3-droplet-current.json"
+			responses["/v3/apps/0c3c9d5e-0d84-4bd8-b6a3-7d3fa0b5b2a1/droplets/current"] = "test-data/v3-droplet-current-docker.json"
+			build, err := api.GetAppBuild(ctx, "17ff8ef2-5f6a-4983-a23c-d52e785885d0")
+			Expect(err).To(BeNil())
+			Expect(build).To(Equal(Build{AppGUID: "17ff8ef2-5f6a-4983-a23c-d52e785885d0", Stack: "cflinuxfs3", Buildpacks: []Buildpack{{Name: "nodejs_buildpack", Version: "1.8.2"}}}))
+			build, err = api.GetAppBuild(ctx, "0c3c9d5e-0d84-4bd8-b6a3-7d3fa0b5b2a1")
+			Expect(err).To(BeNil())
+			Expect(build).To(Equal(Build{AppGUID: "0c3c9d5e-0d84-4bd8-b6a3-7d3fa0b5b2a1", DockerImage: "cloudfoundry/lattice-app"}))
+		})
+
+		It("tells apps without current droplet", func() {
+			responses["/v3/apps/17ff8ef2-5f6a-4983-a23c-d52e785885d0/droplets/current"] = "test-data/v3-droplet-not-found.json"
+			_, err := api.GetAppBuild(ctx, "17ff8ef2-5f6a-4983-a23c-d52e785885d0")
+			Expect(err).To(Equal(ErrNoDroplet))
+		})
+
+		It("fails on other errors of the current droplet", func() {
+			responses["/v3/apps/17ff8ef2-5f6a-4983-a23c-d52e785885d0/droplets/current"] = "test-data/not-authorized.json"
+			_, err := api.GetAppBuild(ctx, "17ff8ef2-5f6a-4983-a23c-d52e785885d0")
+			Expect(err).ToNot(BeNil())
+			Expect(err).ToNot(Equal(ErrNoDroplet))
+		})
+
+		It("returns the space map", func() {
+			sm, err := api.GetSpaceMap(ctx)
+			Expect(err).To(BeNil())
+			Expect(sm["de5db872-5b9e-4775-8d4a-f018133f9aaa"].OrgGUID).To(Equal("4e9ba0f1-4e6b-4c53-9e2b-7e0c6f4d2a11"))
+		})
+	})
+
+	Describe("services", func() {
+		It("maps managed instances to the v2 type and keeps instances without plan", func() {
+			responses["/v3/service_instances"] = "test-data/v3-service-instances.json"
+			siMap, err := api.GetServiceInstanceMap(ctx)
+			Expect(err).To(BeNil())
+			Expect(siMap).To(HaveLen(2))
+			si := siMap["215b97be-ec77-4224-9c38-c4f2d86b56c1"]
+			Expect(si.Type).To(Equal("managed_service_instance"))
+			Expect(si.ServicePlanGUID).To(Equal("6fecf53b-7553-4cb3-b97e-930f9c4e3385"))
+			Expect(si.SpaceGUID).To(Equal("81c310ed-d258-48d7-a57a-6522d93a4217"))
+			Expect(siMap["5e6f7a8b-1111-4222-8333-944455566677"].ServicePlanGUID).To(Equal(""))
+			args := fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)
+			Expect(args[1]).To(Equal("/v3/service_instances?type=managed&per_page=5000"))
+		})
+
+		It("returns the app bindings", func() {
+			responses["/v3/service_credential_bindings"] = "test-data/v3-service-credential-bindings.json"
+			sb, err := api.GetServiceBindingsList(ctx)
+			Expect(err).To(BeNil())
+			Expect(sb).To(HaveLen(1))
+			Expect(sb[0].AppGUID).To(Equal("17ff8ef2-5f6a-4983-a23c-d52e785885d0"))
+			Expect(sb[0].ServiceInstanceGUID).To(Equal("215b97be-ec77-4224-9c38-c4f2d86b56c1"))
+		})
+	})
+})
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	plugin_models "code.cloudfoundry.org/cli/plugin/models"
+	"github.com/cloudfoundry/cli/plugin/pluginfakes"
+	"github.com/dgruber/usagereport-plugin/apihelper/fakecc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// testFoundation has two orgs, one of them with more spaces and apps than
+// fit on a page of the fake Cloud Controller.
+var testFoundation = fakecc.Foundation{
+	Quotas: []fakecc.Quota{
+		{GUID: "q-default", Name: "default", MemoryLimit: 10240, Limits: map[string]int{
+			"instance_memory_limit": 2048, "app_instance_limit": 10, "total_services": 10,
+			"total_service_keys": -1, "total_routes": 20, "total_reserved_route_ports": 2,
+		}},
+		{GUID: "q-small", Name: "small", MemoryLimit: 2048},
+	},
+	SpaceQuotas: []fakecc.SpaceQuota{
+		{GUID: "sq-prod", Name: "prod", OrgGUID: "o-dev", MemoryLimit: 8192},
+	},
+	Orgs: []fakecc.Org{
+		{GUID: "o-dev", Name: "dev-org", QuotaGUID: "q-default", Labels: map[string]string{"team": "payments"}},
+		{GUID: "o-test", Name: "test-org", QuotaGUID: "q-small", Labels: map[string]string{"team": "qa"}},
+	},
+	Spaces: []fakecc.Space{
+		{GUID: "s-dev", Name: "dev", OrgGUID: "o-dev", Labels: map[string]string{"env": "dev"}},
+		{GUID: "s-staging", Name: "staging", OrgGUID: "o-dev"},
+		{GUID: "s-prod", Name: "prod", OrgGUID: "o-dev", QuotaGUID: "sq-prod", Labels: map[string]string{"env": "prod"}},
+		{GUID: "s-test", Name: "test", OrgGUID: "o-test"},
+	},
+	Apps: []fakecc.App{
+		{GUID: "a-1", Name: "web", SpaceGUID: "s-dev", State: "STARTED", Instances: 2, Memory: 512, Disk: 1024, Stats: []fakecc.InstanceStats{
+			{MemoryMB: 200, DiskMB: 100, DiskQuotaMB: 1024, CPU: 0.02}, {MemoryMB: 150, DiskMB: 100, DiskQuotaMB: 1024, CPU: 0.01},
+		}},
+		{GUID: "a-2", Name: "worker", SpaceGUID: "s-dev", State: "STOPPED", Instances: 1, Memory: 1024, Disk: 1024},
+		{GUID: "a-3", Name: "web", SpaceGUID: "s-staging", State: "STARTED", Instances: 1, Memory: 256, Disk: 1024, Labels: map[string]string{"env": "prod"},
+			Stats: []fakecc.InstanceStats{{MemoryMB: 64, DiskMB: 80, DiskQuotaMB: 1024, CPU: 0.001}}},
+		{GUID: "a-4", Name: "web", SpaceGUID: "s-prod", State: "STARTED", Instances: 4, Memory: 1024, Disk: 2048, Stats: []fakecc.InstanceStats{
+			{MemoryMB: 512, DiskMB: 200, DiskQuotaMB: 2048, CPU: 0.5}, {MemoryMB: 512, DiskMB: 200, DiskQuotaMB: 2048, CPU: 0.25},
+			{MemoryMB: 256, DiskMB: 200, DiskQuotaMB: 2048, CPU: 0.125},
+		}},
+		{GUID: "a-5", Name: "batch", SpaceGUID: "s-prod", State: "STARTED", Instances: 1, Memory: 128, Disk: 1024, Labels: map[string]string{"tier": "batch"},
+			Stats: []fakecc.InstanceStats{{MemoryMB: 100, DiskMB: 10, DiskQuotaMB: 1024, CPU: 0.8}}},
+		{GUID: "a-6", Name: "tests", SpaceGUID: "s-test", State: "STARTED", Instances: 1, Memory: 512, Disk: 1024,
+			Stats: []fakecc.InstanceStats{{MemoryMB: 128, DiskMB: 50, DiskQuotaMB: 1024, CPU: 0.05}}},
+	},
+	Services: []fakecc.Service{
+		{GUID: "svc-mysql", Label: "p-mysql"},
+		{GUID: "svc-other", Label: "elephantsql"},
+	},
+	ServicePlans: []fakecc.ServicePlan{
+		{GUID: "plan-100mb", Name: "100mb", ServiceGUID: "svc-mysql"},
+		{GUID: "plan-turtle", Name: "turtle", ServiceGUID: "svc-other"},
+	},
+	ServiceInstances: []fakecc.ServiceInstance{
+		{GUID: "si-db", Name: "db", ServicePlanGUID: "plan-100mb", SpaceGUID: "s-prod"},
+		{GUID: "si-pg", Name: "pg", ServicePlanGUID: "plan-turtle", SpaceGUID: "s-dev"},
+	},
+	UserProvidedServiceInstances: []fakecc.UserProvidedServiceInstance{
+		{GUID: "ups-log", Name: "syslog", SpaceGUID: "s-prod"},
+	},
+	ServiceBindings: []fakecc.ServiceBinding{
+		{GUID: "b-1", AppGUID: "a-4", ServiceInstanceGUID: "si-db"},
+		{GUID: "b-2", AppGUID: "a-4", ServiceInstanceGUID: "ups-log"},
+		{GUID: "b-3", AppGUID: "a-1", ServiceInstanceGUID: "si-pg"},
+	},
+	Routes: []fakecc.Route{
+		{GUID: "r-dev", SpaceGUID: "s-dev"},
+		{GUID: "r-prod", SpaceGUID: "s-prod"},
+		{GUID: "r-tcp", SpaceGUID: "s-prod", Port: 1024},
+		{GUID: "r-test", SpaceGUID: "s-test"},
+	},
+	ServiceKeys: []fakecc.ServiceKey{
+		{GUID: "k-db", ServiceInstanceGUID: "si-db"},
+	},
+}
+
+var _ = Describe("End to end", func() {
+	var server *fakecc.Server
+	var fakeCliConnection *pluginfakes.FakeCliConnection
+
+	// report runs the plugin with args against the fake Cloud Controller
+	report := func(args ...string) string {
+		var out bytes.Buffer
+		cmd := &UsageReportCmd{out: &out}
+		cmd.Run(fakeCliConnection, append([]string{"usage-report-si"}, args...))
+		return out.String()
+	}
+
+	BeforeEach(func() {
+		server = fakecc.New(testFoundation)
+		server.Token = "bearer fake-token"
+		server.PageSize = 2
+
+		fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		fakeCliConnection.ApiEndpointReturns(server.URL, nil)
+		fakeCliConnection.AccessTokenReturns("bearer fake-token", nil)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("reports the memory usage of all orgs and spaces", func() {
+		Expect(report("-f", "csv")).To(Equal(
+			"OrgName, SpaceName, SpaceMemoryUsed, OrgMemoryQuota, AppsDeployed, AppsRunning, AppInstancesDeployed, AppInstancesRunning, SpaceMemoryQuota, SpaceDiskAllocated, OrgDiskAllocated, SpaceTasksRunning, SpaceTaskMemory\n" +
+				"dev-org, dev, 1024, 10240, 2, 1, 3, 2, none, 2048, 12288, 0, 0\n" +
+				"dev-org, staging, 256, 10240, 1, 1, 1, 1, none, 1024, 12288, 0, 0\n" +
+				"dev-org, prod, 4224, 10240, 2, 2, 5, 5, 8192, 9216, 12288, 0, 0\n" +
+				"test-org, test, 512, 2048, 1, 1, 1, 1, none, 1024, 1024, 0, 0\n" +
+				"\n"))
+	})
+
+	It("reports the same with bulk lookups", func() {
+		Expect(report("-bulk")).To(Equal(report()))
+		Expect(report("-bulk")).To(ContainSubstring("Org dev-org is consuming 5504 MB of 10240 MB."))
+	})
+
+	It("follows the pages of all lists", func() {
+		report("-bulk", "-f", "csv")
+		Expect(server.Requests()).To(ContainElement(ContainSubstring("/v2/apps?page=3")))
+		Expect(server.Requests()).To(ContainElement(ContainSubstring("/v2/spaces?page=2")))
+	})
+
+	It("traces the requests without changing the report", func() {
+		var out, errOut bytes.Buffer
+		cmd := &UsageReportCmd{out: &out, errOut: &errOut}
+		cmd.Run(fakeCliConnection, []string{"usage-report-si", "-trace", "-bulk", "-f", "csv"})
+		Expect(errOut.String()).To(ContainSubstring("GET /v2/apps?page=3&results-per-page=2 page=3 status=200"))
+		Expect(errOut.String()).To(MatchRegexp(`/v2/apps\s+3\s+0`))
+
+		calls := 0
+		for _, s := range cmd.trace.Stats() {
+			calls += s.Calls
+		}
+		Expect(calls).To(Equal(len(server.Requests())))
+		Expect(out.String()).To(Equal(report("-bulk", "-f", "csv")))
+	})
+
+	It("filters by org and space", func() {
+		Expect(report("-o", "dev-org", "-s", "prod", "-f", "csv")).To(Equal(
+			"OrgName, SpaceName, SpaceMemoryUsed, OrgMemoryQuota, AppsDeployed, AppsRunning, AppInstancesDeployed, AppInstancesRunning, SpaceMemoryQuota, SpaceDiskAllocated, OrgDiskAllocated, SpaceTasksRunning, SpaceTaskMemory\n" +
+				"dev-org, prod, 4224, 10240, 2, 2, 5, 5, 8192, 9216, 9216, 0, 0\n" +
+				"\n"))
+	})
+
+	It("selects orgs and spaces by globs, regular expressions, GUIDs and org/space pairs", func() {
+		header := "OrgName, SpaceName, SpaceMemoryUsed, OrgMemoryQuota, AppsDeployed, AppsRunning, AppInstancesDeployed, AppInstancesRunning, SpaceMemoryQuota, SpaceDiskAllocated, OrgDiskAllocated, SpaceTasksRunning, SpaceTaskMemory\n"
+		Expect(report("-o", "*-org", "-s", "~^(dev|test)$", "-f", "csv")).To(Equal(header +
+			"dev-org, dev, 1024, 10240, 2, 1, 3, 2, none, 2048, 2048, 0, 0\n" +
+			"test-org, test, 512, 2048, 1, 1, 1, 1, none, 1024, 1024, 0, 0\n" +
+			"\n"))
+		Expect(report("-s", "dev-org/prod,test-org/test", "-f", "csv")).To(Equal(report("-s", "prod", "-s", "test", "-f", "csv")))
+		Expect(report("-o", "dev-org,test-org", "-bulk", "-f", "csv")).To(Equal(report("-f", "csv")))
+	})
+
+	It("leaves out excluded orgs and spaces", func() {
+		Expect(report("-exclude-org", "test-org", "-exclude-space", "st*", "-f", "csv")).To(Equal(
+			"OrgName, SpaceName, SpaceMemoryUsed, OrgMemoryQuota, AppsDeployed, AppsRunning, AppInstancesDeployed, AppInstancesRunning, SpaceMemoryQuota, SpaceDiskAllocated, OrgDiskAllocated, SpaceTasksRunning, SpaceTaskMemory\n" +
+				"dev-org, dev, 1024, 10240, 2, 1, 3, 2, none, 2048, 11264, 0, 0\n" +
+				"dev-org, prod, 4224, 10240, 2, 2, 5, 5, 8192, 9216, 11264, 0, 0\n" +
+				"\n"))
+	})
+
+	It("applies the selection to the service instance reports", func() {
+		output := report("-i", "summary", "-exclude-space", "prod", "-f", "csv")
+		Expect(output).To(ContainSubstring("dev-org,dev,pg,"))
+		Expect(output).ToNot(ContainSubstring("dev-org,prod,db,"))
+
+		output = report("-i", "app", "-s", "dev-org/prod", "-f", "csv")
+		Expect(output).To(ContainSubstring("dev-org,prod,web,4,2,1,1,0,8192,4096,0,0,web,4,1024,2048,0,0\n"))
+		Expect(output).ToNot(ContainSubstring("dev-org,dev,"))
+	})
+
+	It("compares spaces with a space quota to it", func() {
+		Expect(report()).To(ContainSubstring("\tSpace prod is consuming 4224 MB memory (51%) of space quota.\n"))
+		Expect(report()).To(ContainSubstring("\tSpace dev is consuming 1024 MB memory (10%) of org quota.\n"))
+		Expect(report()).To(ContainSubstring("\t\t5 instances: 5 running, 0 stopped\n\t\t9216 MB disk allocated\n"))
+	})
+
+	It("reports unlimited and missing quotas", func() {
+		unlimited := fakecc.New(fakecc.Foundation{
+			Quotas:      []fakecc.Quota{{GUID: "q-unlimited", Name: "unlimited", MemoryLimit: -1}},
+			SpaceQuotas: []fakecc.SpaceQuota{{GUID: "sq-unlimited", Name: "unlimited", OrgGUID: "o-1", MemoryLimit: -1}},
+			Orgs: []fakecc.Org{
+				{GUID: "o-1", Name: "unlimited-org", QuotaGUID: "q-unlimited"},
+				{GUID: "o-2", Name: "org-without-quota"},
+			},
+			Spaces: []fakecc.Space{
+				{GUID: "s-1", Name: "dev", OrgGUID: "o-1"},
+				{GUID: "s-2", Name: "prod", OrgGUID: "o-1", QuotaGUID: "sq-unlimited"},
+				{GUID: "s-3", Name: "dev", OrgGUID: "o-2"},
+			},
+			Apps: []fakecc.App{{GUID: "a-1", Name: "web", SpaceGUID: "s-1", State: "STARTED", Instances: 1, Memory: 256}},
+		})
+		defer unlimited.Close()
+		fakeCliConnection.ApiEndpointReturns(unlimited.URL, nil)
+
+		Expect(report("-f", "csv")).To(Equal(
+			"OrgName, SpaceName, SpaceMemoryUsed, OrgMemoryQuota, AppsDeployed, AppsRunning, AppInstancesDeployed, AppInstancesRunning, SpaceMemoryQuota, SpaceDiskAllocated, OrgDiskAllocated, SpaceTasksRunning, SpaceTaskMemory\n" +
+				"unlimited-org, dev, 256, unlimited, 1, 1, 1, 1, none, 0, 0, 0, 0\n" +
+				"unlimited-org, prod, 0, unlimited, 0, 0, 0, 0, unlimited, 0, 0, 0, 0\n" +
+				"org-without-quota, dev, 0, none, 0, 0, 0, 0, none, 0, 0, 0, 0\n" +
+				"\n"))
+		Expect(report()).To(ContainSubstring(
+			"Org unlimited-org is consuming 256 MB of unlimited memory. Its apps have 0 MB disk allocated.\n" +
+				"\tSpace dev is consuming 256 MB memory of unlimited org quota.\n"))
+		Expect(report()).To(ContainSubstring("\tSpace prod is consuming 0 MB memory of unlimited space quota.\n"))
+		Expect(report()).To(ContainSubstring(
+			"Org org-without-quota is consuming 0 MB, it has no quota. Its apps have 0 MB disk allocated.\n" +
+				"\tSpace dev is consuming 0 MB memory without a quota.\n"))
+		Expect(report("-bulk")).To(Equal(report()))
+	})
+
+	It("counts the memory of running tasks", func() {
+		withTasks := fakecc.New(fakecc.Foundation{
+			Quotas: []fakecc.Quota{{GUID: "q-1", Name: "default", MemoryLimit: 4096}},
+			Orgs:   []fakecc.Org{{GUID: "o-1", Name: "batch-org", QuotaGUID: "q-1"}},
+			Spaces: []fakecc.Space{{GUID: "s-1", Name: "jobs", OrgGUID: "o-1"}},
+			Apps: []fakecc.App{
+				{GUID: "a-1", Name: "web", SpaceGUID: "s-1", State: "STARTED", Instances: 1, Memory: 256, Disk: 1024},
+				{GUID: "a-2", Name: "etl", SpaceGUID: "s-1", State: "STOPPED", Instances: 1, Memory: 512, Disk: 1024},
+			},
+			Tasks: []fakecc.Task{
+				{GUID: "t-1", Name: "migrate", AppGUID: "a-1", State: "RUNNING", Memory: 128, Disk: 512},
+				{GUID: "t-2", Name: "nightly", AppGUID: "a-2", State: "RUNNING", Memory: 1024, Disk: 2048},
+				{GUID: "t-3", Name: "done", AppGUID: "a-2", State: "SUCCEEDED", Memory: 1024, Disk: 2048},
+			},
+		})
+		defer withTasks.Close()
+		fakeCliConnection.ApiEndpointReturns(withTasks.URL, nil)
+
+		Expect(report("-f", "csv")).To(Equal(
+			"OrgName, SpaceName, SpaceMemoryUsed, OrgMemoryQuota, AppsDeployed, AppsRunning, AppInstancesDeployed, AppInstancesRunning, SpaceMemoryQuota, SpaceDiskAllocated, OrgDiskAllocated, SpaceTasksRunning, SpaceTaskMemory\n" +
+				"batch-org, jobs, 1408, 4096, 2, 1, 2, 1, none, 1024, 1024, 2, 1152\n" +
+				"\n"))
+		Expect(report()).To(ContainSubstring("Org batch-org is consuming 1408 MB of 4096 MB."))
+		Expect(report()).To(ContainSubstring("\t\t2 tasks running with 1152 MB memory\n"))
+		Expect(report("-bulk")).To(Equal(report()))
+		Expect(withTasks.Requests()).To(ContainElement(HavePrefix("/v3/tasks?states=RUNNING")))
+
+		output := report("-i", "app", "-f", "csv")
+		Expect(output).To(ContainSubstring("batch-org,jobs,web,1,0,0,0,0,1024,256,1,128,web,1,256,1024,0,0\n"))
+		Expect(output).To(ContainSubstring("batch-org,jobs,etl,1,0,0,0,0,0,0,1,1024,web,1,512,1024,0,0\n"))
+		Expect(report("-quotas", "-f", "csv")).To(ContainSubstring("batch-org,default,memory,1408,4096,34,"))
+	})
+
+	It("reports the apps per stack, buildpack and docker image", func() {
+		withBuilds := fakecc.New(fakecc.Foundation{
+			Quotas: []fakecc.Quota{{GUID: "q-1", Name: "default", MemoryLimit: 4096}},
+			Orgs:   []fakecc.Org{{GUID: "o-1", Name: "web-org", QuotaGUID: "q-1"}},
+			Spaces: []fakecc.Space{{GUID: "s-1", Name: "dev", OrgGUID: "o-1"}, {GUID: "s-2", Name: "prod", OrgGUID: "o-1"}},
+			Stacks: []fakecc.Stack{{GUID: "st-1", Name: "cflinuxfs3"}, {GUID: "st-2", Name: "cflinuxfs4"}},
+			Buildpacks: []fakecc.Buildpack{
+				{GUID: "bp-1", Name: "java_buildpack", Filename: "java-buildpack-cflinuxfs3-v4.16.1.zip"},
+				{GUID: "bp-2", Name: "go_buildpack", Filename: "go_buildpack-cached-cflinuxfs4-v1.10.2.zip"},
+			},
+			Apps: []fakecc.App{
+				{GUID: "a-1", Name: "shop", SpaceGUID: "s-1", State: "STARTED", Instances: 2, Memory: 512, StackGUID: "st-1", BuildpackGUID: "bp-1"},
+				{GUID: "a-2", Name: "shop", SpaceGUID: "s-2", State: "STARTED", Instances: 1, Memory: 1024, StackGUID: "st-1", BuildpackGUID: "bp-1"},
+				{GUID: "a-3", Name: "api", SpaceGUID: "s-2", State: "STOPPED", Instances: 1, Memory: 256, StackGUID: "st-2", BuildpackGUID: "bp-2"},
+				{GUID: "a-4", Name: "proxy", SpaceGUID: "s-2", State: "STARTED", Instances: 1, Memory: 128, StackGUID: "st-2", DockerImage: "nginx:1.19"},
+			},
+			Tasks: []fakecc.Task{{GUID: "t-1", Name: "migrate", AppGUID: "a-2", State: "RUNNING", Memory: 256}},
+		})
+		defer withBuilds.Close()
+		fakeCliConnection.ApiEndpointReturns(withBuilds.URL, nil)
+
+		output := report("-builds")
+		Expect(output).To(HavePrefix("Stack cflinuxfs3 has 2 apps, 2 running with 2304 MB memory.\n" +
+			"\tOrg web-org has 2 apps, 2 running with 2304 MB memory.\n" +
+			"\t\tSpace dev has 1 apps, 1 running with 1024 MB memory.\n" +
+			"\t\t\tApp shop is running with 1024 MB memory.\n" +
+			"\t\tSpace prod has 1 apps, 1 running with 1280 MB memory.\n" +
+			"\t\t\tApp shop is running with 1280 MB memory.\n" +
+			"Stack cflinuxfs4 has 1 apps, 0 running with 0 MB memory.\n"))
+		Expect(output).To(ContainSubstring("Buildpack go_buildpack 1.10.2 has 1 apps, 0 running with 0 MB memory.\n"))
+		Expect(output).To(ContainSubstring("Buildpack java_buildpack 4.16.1 has 2 apps, 2 running with 2304 MB memory.\n"))
+		Expect(output).To(ContainSubstring("Docker image nginx:1.19 has 1 apps, 1 running with 128 MB memory.\n" +
+			"\tOrg web-org has 1 apps, 1 running with 128 MB memory.\n" +
+			"\t\tSpace prod has 1 apps, 1 running with 128 MB memory.\n" +
+			"\t\t\tApp proxy is running with 128 MB memory.\n"))
+		Expect(report("-builds", "-bulk")).To(Equal(output))
+
+		Expect(report("-builds", "-f", "csv")).To(ContainSubstring(
+			"buildpack,java_buildpack,4.16.1,web-org,prod,shop,true,1280,1,1280,2,2304,2,2304\n"))
+		Expect(withBuilds.Requests()).To(ContainElement(HavePrefix("/v2/stacks")))
+		Expect(withBuilds.Requests()).To(ContainElement(HavePrefix("/v2/buildpacks")))
+	})
+
+	It("reports the usage of every quota dimension", func() {
+		Expect(report("-quotas", "-f", "csv")).To(Equal(
+			"OrgName,QuotaName,Dimension,Used,Limit,PercentUsed,ClosestToLimit\n" +
+				"dev-org,default,memory,5504,10240,53,false\n" +
+				"dev-org,default,instance memory,1024,2048,50,false\n" +
+				"dev-org,default,app instances,8,10,80,true\n" +
+				"dev-org,default,services,2,10,20,false\n" +
+				"dev-org,default,service keys,1,unlimited,,false\n" +
+				"dev-org,default,routes,3,20,15,false\n" +
+				"dev-org,default,reserved route ports,1,2,50,false\n" +
+				"test-org,small,memory,512,2048,25,true\n" +
+				"test-org,small,instance memory,512,unlimited,,false\n" +
+				"test-org,small,app instances,1,unlimited,,false\n" +
+				"test-org,small,services,0,unlimited,,false\n" +
+				"test-org,small,service keys,0,unlimited,,false\n" +
+				"test-org,small,routes,1,unlimited,,false\n" +
+				"test-org,small,reserved route ports,0,unlimited,,false\n" +
+				"\n"))
+		Expect(report("-quotas")).To(ContainSubstring("Org dev-org uses quota default:\n" +
+			"\tmemory: 5504 MB of 10240 MB (53%)\n" +
+			"\tinstance memory: 1024 MB of 2048 MB (50%)\n" +
+			"\tapp instances: 8 of 10 (80%)\n"))
+		Expect(report("-quotas")).To(ContainSubstring("\tClosest to its limit: app instances (80%)\n"))
+		Expect(report("-quotas", "-bulk")).To(Equal(report("-quotas")))
+		Expect(report("-quotas", "-s", "prod", "-f", "csv")).To(ContainSubstring("dev-org,default,routes,3,20,15,false\n"))
+	})
+
+	It("compares the memory used by the running instances with their allocation", func() {
+		Expect(report("-stats", "-f", "csv")).To(Equal(
+			"OrgName,SpaceName,AppName,InstanceIndex,MemoryUsed,MemoryAllocated,DiskUsed,DiskAllocated,CPUPercent,AppOverProvisioning,SpaceOverProvisioning,OrgOverProvisioning,ProcessType\n" +
+				"dev-org,dev,web,0,200,512,100,1024,2.0,2.93,2.93,3.07,web\n" +
+				"dev-org,dev,web,1,150,512,100,1024,1.0,2.93,2.93,3.07,web\n" +
+				"dev-org,staging,web,0,64,256,80,1024,0.1,4.00,4.00,3.07,web\n" +
+				"dev-org,prod,web,0,512,1024,200,2048,50.0,3.20,3.06,3.07,web\n" +
+				"dev-org,prod,web,1,512,1024,200,2048,25.0,3.20,3.06,3.07,web\n" +
+				"dev-org,prod,web,2,256,1024,200,2048,12.5,3.20,3.06,3.07,web\n" +
+				"dev-org,prod,batch,0,100,128,10,1024,80.0,1.28,3.06,3.07,web\n" +
+				"test-org,test,tests,0,128,512,50,1024,5.0,4.00,4.00,4.00,web\n" +
+				"\n"))
+		Expect(report("-stats", "-s", "dev-org/dev")).To(Equal(
+			"Org dev-org has 1024 MB allocated to running apps and uses 350 MB (2.9x over-provisioned).\n" +
+				"\tSpace dev has 1024 MB allocated and uses 350 MB (2.9x over-provisioned).\n" +
+				"\t\tApp web has 1024 MB allocated and uses 350 MB (2.9x over-provisioned).\n" +
+				"\t\t\tInstance 0 of web uses 200 of 512 MB memory, 100 of 1024 MB disk and 2.0% CPU.\n" +
+				"\t\t\tInstance 1 of web uses 150 of 512 MB memory, 100 of 1024 MB disk and 1.0% CPU.\n" +
+				"\n"))
+		Expect(server.Requests()).ToNot(ContainElement(HavePrefix("/v2/apps/a-2/stats")))
+		Expect(report("-stats", "-bulk", "-f", "csv")).To(Equal(report("-stats", "-f", "csv")))
+	})
+
+	It("selects orgs, spaces and apps by their labels", func() {
+		Expect(report("-selector", "env=prod,tier!=batch", "-labels", "team,env", "-f", "csv")).To(Equal(
+			"OrgName, SpaceName, SpaceMemoryUsed, OrgMemoryQuota, AppsDeployed, AppsRunning, AppInstancesDeployed, AppInstancesRunning, SpaceMemoryQuota, SpaceDiskAllocated, OrgDiskAllocated, SpaceTasksRunning, SpaceTaskMemory, team, env\n" +
+				"dev-org, staging, 256, 10240, 1, 1, 1, 1, none, 1024, 9216, 0, 0, payments, \n" +
+				"dev-org, prod, 4096, 10240, 1, 1, 4, 4, 8192, 8192, 9216, 0, 0, payments, prod\n" +
+				"\n"))
+		Expect(server.Requests()).To(ContainElement(HavePrefix("/v3/apps?")))
+		Expect(report("-selector", "env in (prod, staging)", "-bulk")).To(Equal(report("-selector", "env in (prod,staging)")))
+		Expect(report("-selector", "team=qa")).ToNot(ContainSubstring("dev-org"))
+	})
+
+	It("applies the label selector to the service instance reports", func() {
+		output := report("-i", "app", "-selector", "team=payments", "-labels", "env", "-f", "csv")
+		Expect(output).To(HavePrefix("OrgName,SpaceName,AppName,AppInstances,BoundServiceInstances,BoundPCFServices,BoundUserProvidedServices,Bound3rdPartyServices,AppDiskAllocated,AppMemoryAllocated,TasksRunning,TaskMemory,ProcessType,ProcessInstances,ProcessMemory,ProcessDisk,Sidecars,SidecarMemory,env\n"))
+		Expect(output).To(ContainSubstring("dev-org,dev,web,2,1,0,0,1,2048,1024,0,0,web,2,512,1024,0,0,dev\n"))
+		Expect(output).To(ContainSubstring("dev-org,staging,web,1,0,0,0,0,1024,256,0,0,web,1,256,1024,0,0,prod\n"))
+		Expect(output).ToNot(ContainSubstring("test-org"))
+
+		output = report("-i", "summary", "-selector", "env=prod", "-f", "csv")
+		Expect(output).To(ContainSubstring("dev-org,prod,db,"))
+		Expect(output).ToNot(ContainSubstring("dev-org,dev,pg,"))
+	})
+
+	Describe("scoped to the target", func() {
+		BeforeEach(func() {
+			org := plugin_models.Organization{}
+			org.Guid, org.Name = "o-dev", "dev-org"
+			fakeCliConnection.GetCurrentOrgReturns(org, nil)
+		})
+
+		It("reports only the targeted org without foundation-wide lists", func() {
+			output := report("-current", "-f", "csv")
+			requests := server.Requests()
+			Expect(requests).ToNot(ContainElement(HavePrefix("/v2/apps?results-per-page")))
+			Expect(requests).ToNot(ContainElement(HavePrefix("/v2/spaces?results-per-page")))
+			Expect(requests).ToNot(ContainElement(HavePrefix("/v2/service_instances?results-per-page")))
+			Expect(requests).ToNot(ContainElement(HavePrefix("/v2/service_bindings?results-per-page")))
+			Expect(requests).ToNot(ContainElement(HavePrefix("/v2/quota_definitions?")))
+			Expect(output).To(Equal(report("-o", "dev-org", "-f", "csv")))
+		})
+
+		It("reports only the targeted space with the usage of its org", func() {
+			space := plugin_models.Space{}
+			space.Guid, space.Name = "s-prod", "prod"
+			fakeCliConnection.GetCurrentSpaceReturns(space, nil)
+			Expect(report("-current", "-f", "csv")).To(Equal(
+				"OrgName, SpaceName, SpaceMemoryUsed, OrgMemoryQuota, AppsDeployed, AppsRunning, AppInstancesDeployed, AppInstancesRunning, SpaceMemoryQuota, SpaceDiskAllocated, OrgDiskAllocated, SpaceTasksRunning, SpaceTaskMemory\n" +
+					"dev-org, prod, 4224, 10240, 2, 2, 5, 5, 8192, 9216, 9216, 0, 0\n" +
+					"\n"))
+			Expect(report("-current")).To(ContainSubstring("Org dev-org is consuming 5504 MB of 10240 MB."))
+
+			output := report("-current", "-i", "summary", "-f", "csv")
+			Expect(output).To(ContainSubstring("dev-org,prod,db,"))
+			Expect(output).ToNot(ContainSubstring("dev-org,dev,pg,"))
+
+			// quotas apply to the whole org of the targeted space
+			Expect(report("-current", "-quotas", "-f", "csv")).To(Equal(report("-o", "dev-org", "-quotas", "-f", "csv")))
+		})
+	})
+
+	It("counts the service instances bound to apps", func() {
+		output := report("-i", "app", "-f", "csv")
+		Expect(output).To(ContainSubstring("dev-org,prod,web,4,2,1,1,0,8192,4096,0,0,web,4,1024,2048,0,0\n"))
+		Expect(output).To(ContainSubstring("dev-org,dev,web,2,1,0,0,1,2048,1024,0,0,web,2,512,1024,0,0\n"))
+	})
+
+	It("summarizes the service instances", func() {
+		output := report("-i", "summary", "-f", "csv")
+		Expect(output).To(ContainSubstring("dev-org,prod,db,managed_service_instance,p-mysql,100mb,1,a-4\n"))
+		Expect(output).To(ContainSubstring("dev-org,dev,pg,managed_service_instance,elephantsql,turtle,1,a-1\n"))
+	})
+
+	Describe("several foundations", func() {
+		var other *fakecc.Server
+		var dir string
+
+		BeforeEach(func() {
+			other = fakecc.New(fakecc.Foundation{
+				Quotas: []fakecc.Quota{{GUID: "q-1", Name: "default", MemoryLimit: 4096}},
+				Orgs:   []fakecc.Org{{GUID: "o-1", Name: "dev-org", QuotaGUID: "q-1"}},
+				Spaces: []fakecc.Space{{GUID: "s-1", Name: "dev", OrgGUID: "o-1"}},
+				Apps: []fakecc.App{
+					{GUID: "a-1", Name: "web", SpaceGUID: "s-1", State: "STARTED", Instances: 3, Memory: 256},
+				},
+			})
+			other.Token = "bearer other-token"
+			other.Users = map[string]string{"admin": "secret"}
+
+			var err error
+			dir, err = ioutil.TempDir("", "usagereport-targets")
+			Expect(err).To(BeNil())
+
+			// the first foundation is the one a cf CLI is logged in to
+			cfHome := filepath.Join(dir, "eu")
+			Expect(os.MkdirAll(filepath.Join(cfHome, ".cf"), 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(cfHome, ".cf", "config.json"), []byte(fmt.Sprintf(
+				`{"Target": %q, "AccessToken": "bearer fake-token"}`, server.URL)), 0600)).To(Succeed())
+
+			os.Setenv("USAGEREPORT_TEST_PASSWORD", "secret")
+			Expect(ioutil.WriteFile(filepath.Join(dir, "targets.json"), []byte(fmt.Sprintf(`[
+				{"name": "eu", "cf_home": %q},
+				{"name": "us", "api": %q, "username": "admin", "password": "$USAGEREPORT_TEST_PASSWORD"}
+			]`, cfHome, other.URL)), 0600)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			other.Close()
+			os.RemoveAll(dir)
+			os.Unsetenv("USAGEREPORT_TEST_PASSWORD")
+		})
+
+		It("adds a Foundation column to the CSV", func() {
+			Expect(report("-targets", filepath.Join(dir, "targets.json"), "-f", "csv")).To(Equal(
+				"Foundation, OrgName, SpaceName, SpaceMemoryUsed, OrgMemoryQuota, AppsDeployed, AppsRunning, AppInstancesDeployed, AppInstancesRunning, SpaceMemoryQuota, SpaceDiskAllocated, OrgDiskAllocated, SpaceTasksRunning, SpaceTaskMemory\n" +
+					"eu, dev-org, dev, 1024, 10240, 2, 1, 3, 2, none, 2048, 12288, 0, 0\n" +
+					"eu, dev-org, staging, 256, 10240, 1, 1, 1, 1, none, 1024, 12288, 0, 0\n" +
+					"eu, dev-org, prod, 4224, 10240, 2, 2, 5, 5, 8192, 9216, 12288, 0, 0\n" +
+					"eu, test-org, test, 512, 2048, 1, 1, 1, 1, none, 1024, 1024, 0, 0\n" +
+					"us, dev-org, dev, 768, 4096, 1, 1, 3, 3, none, 0, 0, 0, 0\n" +
+					"\n"))
+		})
+
+		It("prints a section per foundation and the grand totals", func() {
+			output := report("-targets", filepath.Join(dir, "targets.json"))
+			Expect(output).To(ContainSubstring("Foundation eu\nOrg dev-org is consuming 5504 MB of 10240 MB. Its apps have 12288 MB disk allocated.\n"))
+			Expect(output).To(ContainSubstring("Foundation eu is running 6 apps in 2 org(s), with a total of 10 instances.\n"))
+			Expect(output).To(ContainSubstring("Foundation us\nOrg dev-org is consuming 768 MB of 4096 MB. Its apps have 0 MB disk allocated.\n"))
+			Expect(output).To(ContainSubstring("You are running 7 apps in 3 org(s) on 2 foundation(s), with a total of 13 instances.\n"))
+		})
+
+		It("adds the foundation to the service instance summary", func() {
+			output := report("-targets", filepath.Join(dir, "targets.json"), "-i", "summary", "-f", "csv")
+			Expect(output).To(HavePrefix("Foundation,OrgName,SpaceName,"))
+			Expect(output).To(ContainSubstring("eu,dev-org,prod,db,managed_service_instance,p-mysql,100mb,1,a-4\n"))
+		})
+	})
+})
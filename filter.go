@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/dgruber/usagereport-plugin/apihelper"
+	"github.com/dgruber/usagereport-plugin/models"
+)
+
+// listFlag is a flag which can be repeated and takes comma separated values.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+var guidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// pattern matches an org or space by name or GUID, by a glob like team-*
+// or, if prefixed with ~, by a regular expression on the name.
+type pattern struct {
+	text string
+	glob bool
+	re   *regexp.Regexp
+}
+
+func parsePattern(text string) (pattern, error) {
+	if strings.HasPrefix(text, "~") {
+		re, err := regexp.Compile(text[1:])
+		if nil != err {
+			return pattern{}, fmt.Errorf("invalid regular expression %q: %v", text[1:], err)
+		}
+		return pattern{text: text, re: re}, nil
+	}
+	if strings.ContainsAny(text, "*?[") {
+		if _, err := path.Match(text, ""); nil != err {
+			return pattern{}, fmt.Errorf("invalid glob %q: %v", text, err)
+		}
+		return pattern{text: text, glob: true}, nil
+	}
+	return pattern{text: text}, nil
+}
+
+func (p pattern) match(name, guid string) bool {
+	switch {
+	case p.re != nil:
+		return p.re.MatchString(name)
+	case p.glob:
+		matched, _ := path.Match(p.text, name)
+		return matched
+	}
+	return p.text == name || (guid != "" && p.text == guid)
+}
+
+// name returns the name a plain pattern matches, which can be looked up
+// directly. Globs, regular expressions and GUIDs have none.
+func (p pattern) name() (string, bool) {
+	if p.re != nil || p.glob || guidPattern.MatchString(p.text) {
+		return "", false
+	}
+	return p.text, true
+}
+
+// spacePattern matches a space, only in the orgs matching org if it was
+// qualified as org/space.
+type spacePattern struct {
+	org   *pattern
+	space pattern
+}
+
+func parseSpacePattern(text string) (spacePattern, error) {
+	var p spacePattern
+	if i := strings.Index(text, "/"); i >= 0 {
+		org, err := parsePattern(text[:i])
+		if nil != err {
+			return p, err
+		}
+		p.org = &org
+		text = text[i+1:]
+	}
+	space, err := parsePattern(text)
+	p.space = space
+	return p, err
+}
+
+func (p spacePattern) match(org apihelper.Organization, name, guid string) bool {
+	if p.org != nil && !p.org.match(org.Name, org.GUID) {
+		return false
+	}
+	return p.space.match(name, guid)
+}
+
+// selection holds the orgs and spaces selected by -o and -s and those
+// left out by -exclude-org and -exclude-space. Without -o every org is
+// selected, unless -s only lists org/space pairs, which select their
+// orgs. Without -s every space of the selected orgs is.
+type selection struct {
+	orgs          []pattern
+	spaces        []spacePattern
+	excludeOrgs   []pattern
+	excludeSpaces []spacePattern
+}
+
+func newSelection(orgs, spaces, excludeOrgs, excludeSpaces []string) (selection, error) {
+	var sel selection
+	var err error
+	if sel.orgs, err = parsePatterns(orgs); nil != err {
+		return sel, err
+	}
+	if sel.excludeOrgs, err = parsePatterns(excludeOrgs); nil != err {
+		return sel, err
+	}
+	if sel.spaces, err = parseSpacePatterns(spaces); nil != err {
+		return sel, err
+	}
+	sel.excludeSpaces, err = parseSpacePatterns(excludeSpaces)
+	return sel, err
+}
+
+func parsePatterns(texts []string) ([]pattern, error) {
+	patterns := make([]pattern, 0, len(texts))
+	for _, text := range texts {
+		p, err := parsePattern(text)
+		if nil != err {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+func parseSpacePatterns(texts []string) ([]spacePattern, error) {
+	patterns := make([]spacePattern, 0, len(texts))
+	for _, text := range texts {
+		p, err := parseSpacePattern(text)
+		if nil != err {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// orgPatterns returns the patterns selecting orgs, nil if all orgs are.
+func (sel selection) orgPatterns() []pattern {
+	if len(sel.orgs) > 0 {
+		return sel.orgs
+	}
+	var orgs []pattern
+	for _, p := range sel.spaces {
+		if p.org == nil {
+			return nil
+		}
+		orgs = append(orgs, *p.org)
+	}
+	return orgs
+}
+
+// orgNames returns the names of the selected orgs if they are all given
+// by plain names, so that they can be looked up one by one instead of
+// listing all orgs.
+func (sel selection) orgNames() ([]string, bool) {
+	patterns := sel.orgPatterns()
+	if len(patterns) == 0 {
+		return nil, false
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, p := range patterns {
+		name, ok := p.name()
+		if !ok {
+			return nil, false
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, true
+}
+
+// org tells whether o is selected.
+func (sel selection) org(o apihelper.Organization) bool {
+	for _, p := range sel.excludeOrgs {
+		if p.match(o.Name, o.GUID) {
+			return false
+		}
+	}
+	patterns := sel.orgPatterns()
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if p.match(o.Name, o.GUID) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterOrgs returns the selected orgs of rawOrgs.
+func (sel selection) filterOrgs(rawOrgs []apihelper.Organization) []apihelper.Organization {
+	orgs := make([]apihelper.Organization, 0, len(rawOrgs))
+	for _, o := range rawOrgs {
+		if sel.org(o) {
+			orgs = append(orgs, o)
+		}
+	}
+	return orgs
+}
+
+// space tells whether the space with name and guid of org o is selected.
+func (sel selection) space(o apihelper.Organization, name, guid string) bool {
+	for _, p := range sel.excludeSpaces {
+		if p.match(o, name, guid) {
+			return false
+		}
+	}
+	if len(sel.spaces) == 0 {
+		return true
+	}
+	for _, p := range sel.spaces {
+		if p.match(o, name, guid) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterServices returns the service instances in the selected orgs and
+// spaces. Orgs and spaces are looked up in the query cache by GUID.
+func (sel selection) filterServices(services []models.Service, cache globalQueryCache) []models.Service {
+	filtered := make([]models.Service, 0, len(services))
+	for _, s := range services {
+		space := cache.spaceMap[cache.siMap[s.ServiceInstanceGUID].SpaceGUID]
+		org := apihelper.Organization{GUID: space.OrgGUID, Name: s.OrgName}
+		if sel.org(org) && sel.space(org, s.SpaceName, space.GUID) {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
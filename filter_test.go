@@ -0,0 +1,99 @@
+package main
+
+import (
+	"github.com/dgruber/usagereport-plugin/apihelper"
+	"github.com/dgruber/usagereport-plugin/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Selection", func() {
+	guid := "2d5e4f7a-7a0b-4c53-9a2b-46e1e2a7c1f0"
+	shop := apihelper.Organization{GUID: guid, Name: "shop"}
+	system := apihelper.Organization{GUID: "o-system", Name: "system"}
+
+	newSel := func(orgs, spaces, excludeOrgs, excludeSpaces []string) selection {
+		sel, err := newSelection(orgs, spaces, excludeOrgs, excludeSpaces)
+		Expect(err).To(BeNil())
+		return sel
+	}
+
+	It("splits repeated and comma separated values", func() {
+		var l listFlag
+		Expect(l.Set("a, b")).To(Succeed())
+		Expect(l.Set("c")).To(Succeed())
+		Expect([]string(l)).To(Equal([]string{"a", "b", "c"}))
+	})
+
+	It("matches names, GUIDs, globs and regular expressions", func() {
+		for _, p := range []string{"shop", guid, "sh*", "~^s.o"} {
+			Expect(newSel([]string{p}, nil, nil, nil).org(shop)).To(BeTrue(), p)
+		}
+		Expect(newSel([]string{"sh*"}, nil, nil, nil).org(system)).To(BeFalse())
+		Expect(newSel(nil, nil, nil, nil).org(system)).To(BeTrue())
+	})
+
+	It("excludes orgs and spaces", func() {
+		sel := newSel(nil, nil, []string{"system"}, []string{"~tmp$", "shop/dev"})
+		Expect(sel.org(shop)).To(BeTrue())
+		Expect(sel.org(system)).To(BeFalse())
+		Expect(sel.space(shop, "prod", "s-1")).To(BeTrue())
+		Expect(sel.space(shop, "dev", "s-2")).To(BeFalse())
+		Expect(sel.space(system, "dev", "s-3")).To(BeTrue())
+		Expect(sel.space(system, "build-tmp", "s-4")).To(BeFalse())
+	})
+
+	It("selects the orgs of org/space pairs", func() {
+		sel := newSel(nil, []string{"shop/prod"}, nil, nil)
+		Expect(sel.org(shop)).To(BeTrue())
+		Expect(sel.org(system)).To(BeFalse())
+		Expect(sel.space(shop, "prod", "")).To(BeTrue())
+		Expect(sel.space(shop, "dev", "")).To(BeFalse())
+
+		sel = newSel(nil, []string{"shop/prod", "dev"}, nil, nil)
+		Expect(sel.org(system)).To(BeTrue())
+		Expect(sel.space(system, "dev", "")).To(BeTrue())
+		Expect(sel.space(system, "prod", "")).To(BeFalse())
+	})
+
+	It("looks up orgs by name only if all are given by plain names", func() {
+		names, ok := newSel([]string{"shop", "billing", "shop"}, nil, nil, nil).orgNames()
+		Expect(ok).To(BeTrue())
+		Expect(names).To(Equal([]string{"shop", "billing"}))
+
+		_, ok = newSel([]string{"shop", "bill*"}, nil, nil, nil).orgNames()
+		Expect(ok).To(BeFalse())
+		_, ok = newSel([]string{guid}, nil, nil, nil).orgNames()
+		Expect(ok).To(BeFalse())
+		_, ok = newSel(nil, []string{"dev"}, nil, nil).orgNames()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("rejects invalid globs and regular expressions", func() {
+		_, err := newSelection([]string{"~(team"}, nil, nil, nil)
+		Expect(err).ToNot(BeNil())
+		_, err = newSelection(nil, []string{"[team/dev"}, nil, nil)
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("filters service instances by the org and space they are in", func() {
+		cache := globalQueryCache{
+			siMap: map[string]apihelper.ServiceInstance{
+				"si-1": apihelper.ServiceInstance{GUID: "si-1", SpaceGUID: "s-1"},
+				"si-2": apihelper.ServiceInstance{GUID: "si-2", SpaceGUID: "s-2"},
+			},
+			spaceMap: map[string]apihelper.SpaceDetails{
+				"s-1": apihelper.SpaceDetails{GUID: "s-1", Name: "prod", OrgGUID: guid},
+				"s-2": apihelper.SpaceDetails{GUID: "s-2", Name: "prod", OrgGUID: "o-system"},
+			},
+		}
+		services := []models.Service{
+			models.Service{ServiceInstanceGUID: "si-1", OrgName: "shop", SpaceName: "prod"},
+			models.Service{ServiceInstanceGUID: "si-2", OrgName: "system", SpaceName: "prod"},
+		}
+		filtered := newSel([]string{guid}, nil, nil, nil).filterServices(services, cache)
+		Expect(filtered).To(HaveLen(1))
+		Expect(filtered[0].ServiceInstanceGUID).To(Equal("si-1"))
+	})
+})
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/dgruber/usagereport-plugin/apihelper"
+	"github.com/dgruber/usagereport-plugin/models"
+)
+
+// target is a foundation of a combined report as given in the -targets
+// file. It is either the foundation a cf CLI with the given CF_HOME is
+// logged in to, or an API endpoint with username and password, client
+// credentials or a refresh token. Secrets which are just $VAR or ${VAR} are
+// taken from the environment.
+type target struct {
+	Name              string `json:"name"`
+	CFHome            string `json:"cf_home"`
+	API               string `json:"api"`
+	Username          string `json:"username"`
+	Password          string `json:"password"`
+	ClientID          string `json:"client_id"`
+	ClientSecret      string `json:"client_secret"`
+	RefreshToken      string `json:"refresh_token"`
+	SkipSSLValidation bool   `json:"skip_ssl_validation"`
+}
+
+// envReference matches secrets which reference an environment variable as
+// a whole, other secrets like pa$$word are taken literally.
+var envReference = regexp.MustCompile(`^\$(?:\{(\w+)\}|(\w+))$`)
+
+// secret returns the value of the environment variable value references or
+// value itself.
+func secret(value string) string {
+	m := envReference.FindStringSubmatch(value)
+	if m == nil {
+		return value
+	}
+	return os.Getenv(m[1] + m[2])
+}
+
+// loadTargets reads the list of foundations from a JSON file.
+func loadTargets(path string) ([]target, error) {
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, fmt.Errorf("reading targets: %v", err)
+	}
+	var targets []target
+	if err := json.Unmarshal(data, &targets); nil != err {
+		return nil, fmt.Errorf("parsing targets %s: %v", path, err)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("targets %s: no foundations listed", path)
+	}
+
+	names := make(map[string]bool)
+	for i, t := range targets {
+		if t.Name == "" {
+			return nil, fmt.Errorf("targets %s: foundation %d has no name", path, i+1)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("targets %s: foundation %s is listed twice", path, t.Name)
+		}
+		names[t.Name] = true
+		if (t.CFHome == "") == (t.API == "") {
+			return nil, fmt.Errorf("targets %s: foundation %s needs either cf_home or api", path, t.Name)
+		}
+		if t.API != "" && (t.Username == "" || t.Password == "") &&
+			(t.ClientID == "" || t.ClientSecret == "") && t.RefreshToken == "" {
+			return nil, fmt.Errorf("targets %s: foundation %s needs username and password, client_id and client_secret or refresh_token", path, t.Name)
+		}
+	}
+	return targets, nil
+}
+
+// connection returns the Connection to the foundation of t.
+func (t target) connection() (apihelper.Connection, error) {
+	switch {
+	case t.CFHome != "":
+		return apihelper.NewCFHomeConnection(t.CFHome)
+	case t.Username != "":
+		return apihelper.NewPasswordConnection(t.API, t.Username, secret(t.Password), t.SkipSSLValidation), nil
+	case t.ClientSecret != "":
+		return apihelper.NewClientCredentialsConnection(t.API, t.ClientID, secret(t.ClientSecret), t.SkipSSLValidation), nil
+	}
+	return apihelper.NewRefreshTokenConnection(t.API, t.ClientID, "", secret(t.RefreshToken), t.SkipSSLValidation), nil
+}
+
+// MultiFoundationReportCommand runs the report against every foundation of
+// the -targets file one after another and prints a combined report. In
+// partial mode foundations which fail are listed as warnings.
+func (cmd *UsageReportCmd) MultiFoundationReportCommand(ctx context.Context, flagVals flagVal) {
+	targets, err := loadTargets(flagVals.Targets)
+	if nil != err {
+		fmt.Fprintln(cmd.stderr(), err)
+		os.Exit(1)
+	}
+
+	var report models.Report
+	for _, t := range targets {
+		foundation := cmd.foundation(t.Name)
+		foundationReport, err := foundation.targetReport(ctx, t, flagVals)
+		report.AddFoundation(t.Name, foundationReport)
+		if nil == err {
+			continue
+		}
+		if nil != ctx.Err() {
+			break
+		}
+		err = fmt.Errorf("foundation %s: %v", t.Name, err)
+		if !cmd.partial {
+			cmd.exit(ctx, flagVals.Format, err)
+		}
+		report.Warnings = append(report.Warnings, models.Warning{Foundation: t.Name, Message: err.Error()})
+	}
+	cmd.printReport(ctx, flagVals, report, ctx.Err())
+}
+
+// foundation returns the command gathering the report of one foundation
+// with the settings of cmd.
+func (cmd *UsageReportCmd) foundation(name string) *UsageReportCmd {
+	foundation := &UsageReportCmd{
+		name:        name,
+		parallelism: cmd.parallelism,
+		bulk:        cmd.bulk,
+		withLabels:  cmd.withLabels,
+		quotas:      cmd.quotas,
+		builds:      cmd.builds,
+		partial:     cmd.partial,
+		out:         cmd.out,
+		errOut:      cmd.errOut,
+	}
+	cmd.foundations = append(cmd.foundations, foundation)
+	return foundation
+}
+
+// targetReport connects to the foundation of t and gathers its report.
+// Responses are recorded to and replayed from a subdirectory per
+// foundation.
+func (cmd *UsageReportCmd) targetReport(ctx context.Context, t target, flagVals flagVal) (models.Report, error) {
+	var conn apihelper.Connection
+	if flagVals.Replay != "" {
+		flagVals.Replay = filepath.Join(flagVals.Replay, t.Name)
+	} else {
+		var err error
+		if conn, err = t.connection(); nil != err {
+			return models.Report{}, err
+		}
+	}
+	if flagVals.Record != "" {
+		flagVals.Record = filepath.Join(flagVals.Record, t.Name)
+	}
+
+	if err := cmd.connect(ctx, conn, flagVals); nil != err {
+		return models.Report{}, err
+	}
+	return cmd.gatherReport(ctx, flagVals)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Targets", func() {
+	var file *os.File
+
+	load := func(targets string) ([]target, error) {
+		Expect(ioutil.WriteFile(file.Name(), []byte(targets), 0600)).To(Succeed())
+		return loadTargets(file.Name())
+	}
+
+	BeforeEach(func() {
+		var err error
+		file, err = ioutil.TempFile("", "usagereport-targets")
+		Expect(err).To(BeNil())
+		file.Close()
+	})
+
+	AfterEach(func() {
+		os.Remove(file.Name())
+	})
+
+	It("reads CF_HOME and API endpoint targets", func() {
+		targets, err := load(`[
+			{"name": "eu", "cf_home": "/home/me/cf-eu"},
+			{"name": "us", "api": "https://api.us.example.com", "username": "admin", "password": "secret"},
+			{"name": "ap", "api": "https://api.ap.example.com", "client_id": "reporter", "client_secret": "secret"},
+			{"name": "sa", "api": "https://api.sa.example.com", "refresh_token": "token"}
+		]`)
+		Expect(err).To(BeNil())
+		Expect(targets).To(HaveLen(4))
+		Expect(targets[0].CFHome).To(Equal("/home/me/cf-eu"))
+		Expect(targets[1].API).To(Equal("https://api.us.example.com"))
+		Expect(targets[2].ClientID).To(Equal("reporter"))
+		Expect(targets[3].RefreshToken).To(Equal("token"))
+	})
+
+	It("takes secrets from the environment only if they reference a variable as a whole", func() {
+		os.Setenv("USAGEREPORT_TEST_SECRET", "from-env")
+		defer os.Unsetenv("USAGEREPORT_TEST_SECRET")
+		Expect(secret("$USAGEREPORT_TEST_SECRET")).To(Equal("from-env"))
+		Expect(secret("${USAGEREPORT_TEST_SECRET}")).To(Equal("from-env"))
+		Expect(secret("pa$$word")).To(Equal("pa$$word"))
+		Expect(secret("pa$USAGEREPORT_TEST_SECRET")).To(Equal("pa$USAGEREPORT_TEST_SECRET"))
+		Expect(secret("secret")).To(Equal("secret"))
+	})
+
+	It("rejects incomplete targets", func() {
+		for _, targets := range []string{
+			`[]`,
+			`[{"cf_home": "/home/me/cf-eu"}]`,
+			`[{"name": "eu"}]`,
+			`[{"name": "eu", "cf_home": "/home/me/cf-eu", "api": "https://api.eu.example.com"}]`,
+			`[{"name": "us", "api": "https://api.us.example.com", "username": "admin"}]`,
+			`[{"name": "us", "api": "https://api.us.example.com", "client_id": "reporter"}]`,
+			`[{"name": "eu", "cf_home": "/a"}, {"name": "eu", "cf_home": "/b"}]`,
+		} {
+			_, err := load(targets)
+			Expect(err).ToNot(BeNil(), targets)
+		}
+	})
+})
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dgruber/usagereport-plugin/models"
+)
+
+// label keys with an optional DNS prefix and label values as accepted by the
+// Cloud Controller
+var (
+	labelKeyPattern   = regexp.MustCompile(`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*/)?[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+	labelValuePattern = regexp.MustCompile(`^([A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?)?$`)
+	setPattern        = regexp.MustCompile(`^(\S+)\s+(in|notin)\s+\((.*)\)$`)
+)
+
+// operators of the requirements of a label selector
+const (
+	opExists    = "exists"
+	opNotExists = "!exists"
+	opEqual     = "="
+	opNotEqual  = "!="
+	opIn        = "in"
+	opNotIn     = "notin"
+)
+
+// requirement is a single requirement of a label selector like env=prod.
+type requirement struct {
+	key    string
+	op     string
+	values []string
+}
+
+// labelSelector selects orgs, spaces and apps by their labels with the
+// syntax of the label_selector of the Cloud Controller. All requirements
+// have to be met. A nil labelSelector selects everything.
+type labelSelector []requirement
+
+// parseSelector parses comma separated requirements like env=prod, env==prod,
+// env!=prod, env in (prod,staging), env notin (dev), env and !env.
+func parseSelector(text string) (labelSelector, error) {
+	var sel labelSelector
+	for _, part := range splitRequirements(text) {
+		r, err := parseRequirement(strings.TrimSpace(part))
+		if nil != err {
+			return nil, err
+		}
+		sel = append(sel, r)
+	}
+	return sel, nil
+}
+
+// splitRequirements splits text at the commas outside of parentheses.
+func splitRequirements(text string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range text {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, text[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, text[start:])
+}
+
+func parseRequirement(text string) (requirement, error) {
+	var r requirement
+	switch {
+	case setPattern.MatchString(text):
+		m := setPattern.FindStringSubmatch(text)
+		r = requirement{key: m[1], op: m[2]}
+		for _, v := range strings.Split(m[3], ",") {
+			r.values = append(r.values, strings.TrimSpace(v))
+		}
+	case strings.Contains(text, "!="):
+		i := strings.Index(text, "!=")
+		r = requirement{key: text[:i], op: opNotEqual, values: []string{text[i+2:]}}
+	case strings.Contains(text, "=="):
+		i := strings.Index(text, "==")
+		r = requirement{key: text[:i], op: opEqual, values: []string{text[i+2:]}}
+	case strings.Contains(text, "="):
+		i := strings.Index(text, "=")
+		r = requirement{key: text[:i], op: opEqual, values: []string{text[i+1:]}}
+	case strings.HasPrefix(text, "!"):
+		r = requirement{key: strings.TrimSpace(text[1:]), op: opNotExists}
+	default:
+		r = requirement{key: text, op: opExists}
+	}
+
+	r.key = strings.TrimSpace(r.key)
+	if !labelKeyPattern.MatchString(r.key) {
+		return r, fmt.Errorf("invalid requirement %q: invalid label key %q", text, r.key)
+	}
+	for i, v := range r.values {
+		r.values[i] = strings.TrimSpace(v)
+		if !labelValuePattern.MatchString(r.values[i]) {
+			return r, fmt.Errorf("invalid requirement %q: invalid label value %q", text, r.values[i])
+		}
+	}
+	return r, nil
+}
+
+// match tells whether labels meet the requirement. Like the Cloud
+// Controller, != and notin also match if the label is missing.
+func (r requirement) match(labels map[string]string) bool {
+	value, exists := labels[r.key]
+	switch r.op {
+	case opExists:
+		return exists
+	case opNotExists:
+		return !exists
+	case opEqual, opIn:
+		return exists && contains(r.values, value)
+	case opNotEqual, opNotIn:
+		return !exists || !contains(r.values, value)
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// match tells whether labels meet all requirements of the selector.
+func (sel labelSelector) match(labels map[string]string) bool {
+	for _, r := range sel {
+		if !r.match(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeLabels returns the labels of parent overridden by those of child.
+func mergeLabels(parent, child map[string]string) map[string]string {
+	if len(child) == 0 {
+		return parent
+	}
+	if len(parent) == 0 {
+		return child
+	}
+	merged := make(map[string]string, len(parent)+len(child))
+	for k, v := range parent {
+		merged[k] = v
+	}
+	for k, v := range child {
+		merged[k] = v
+	}
+	return merged
+}
+
+// inheritLabels adds the labels of the orgs to their spaces and those of the
+// spaces to their apps, unless they have their own label of the same key.
+func inheritLabels(orgs []models.Org) {
+	for i := range orgs {
+		for j := range orgs[i].Spaces {
+			space := &orgs[i].Spaces[j]
+			space.Labels = mergeLabels(orgs[i].Labels, space.Labels)
+			for k := range space.Apps {
+				space.Apps[k].Labels = mergeLabels(space.Labels, space.Apps[k].Labels)
+			}
+		}
+	}
+}
+
+// filterOrgs returns the orgs selected by their inherited labels. Only the
+// matching apps are kept. A space is kept if it matches or has matching
+// apps, an org if it matches or has spaces which are kept.
+func (sel labelSelector) filterOrgs(orgs []models.Org) []models.Org {
+	if sel == nil {
+		return orgs
+	}
+	var filtered []models.Org
+	for _, org := range orgs {
+		spaces := make([]models.Space, 0, len(org.Spaces))
+		for _, space := range org.Spaces {
+			apps := make([]models.App, 0, len(space.Apps))
+			for _, app := range space.Apps {
+				if sel.match(app.Labels) {
+					apps = append(apps, app)
+				}
+			}
+			if len(apps) > 0 || sel.match(space.Labels) {
+				space.Apps = apps
+				spaces = append(spaces, space)
+			}
+		}
+		if len(spaces) > 0 || sel.match(org.Labels) {
+			org.Spaces = spaces
+			filtered = append(filtered, org)
+		}
+	}
+	return filtered
+}
+
+// labelServices sets the labels of the service instances to those of their
+// spaces. With a selector only the instances are kept whose space or one of
+// whose bound apps matches. Orgs and spaces are looked up in the query cache
+// by GUID.
+func (sel labelSelector) labelServices(services []models.Service, cache globalQueryCache) []models.Service {
+	labels := cache.labels
+	filtered := make([]models.Service, 0, len(services))
+	for _, s := range services {
+		space := cache.spaceMap[cache.siMap[s.ServiceInstanceGUID].SpaceGUID]
+		s.Labels = mergeLabels(labels.Orgs[space.OrgGUID], labels.Spaces[space.GUID])
+		matches := sel.match(s.Labels)
+		for _, appGUID := range s.AppGUIDs {
+			matches = matches || sel.match(mergeLabels(s.Labels, labels.Apps[appGUID]))
+		}
+		if matches {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
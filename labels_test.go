@@ -0,0 +1,113 @@
+package main
+
+import (
+	"github.com/dgruber/usagereport-plugin/apihelper"
+	"github.com/dgruber/usagereport-plugin/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Label selector", func() {
+	parse := func(text string) labelSelector {
+		sel, err := parseSelector(text)
+		Expect(err).To(BeNil(), text)
+		return sel
+	}
+
+	It("parses the requirements of the Cloud Controller syntax", func() {
+		Expect(parse("team=payments, env==prod,tier!=batch")).To(Equal(labelSelector{
+			{key: "team", op: opEqual, values: []string{"payments"}},
+			{key: "env", op: opEqual, values: []string{"prod"}},
+			{key: "tier", op: opNotEqual, values: []string{"batch"}},
+		}))
+		Expect(parse("env in (prod, staging),example.com/tier notin (batch),team,!legacy")).To(Equal(labelSelector{
+			{key: "env", op: opIn, values: []string{"prod", "staging"}},
+			{key: "example.com/tier", op: opNotIn, values: []string{"batch"}},
+			{key: "team", op: opExists},
+			{key: "legacy", op: opNotExists},
+		}))
+	})
+
+	It("rejects invalid keys and values", func() {
+		for _, text := range []string{"", "=prod", "env in prod", "env=pro d", "-team=x", "team=payments,"} {
+			_, err := parseSelector(text)
+			Expect(err).ToNot(BeNil(), text)
+		}
+		Expect(parse("team=")).To(Equal(labelSelector{{key: "team", op: opEqual, values: []string{""}}}))
+	})
+
+	It("matches labels like the Cloud Controller", func() {
+		labels := map[string]string{"team": "payments", "env": "prod"}
+		Expect(parse("team=payments,env in (prod,staging)").match(labels)).To(BeTrue())
+		Expect(parse("team,!legacy").match(labels)).To(BeTrue())
+		Expect(parse("tier!=batch,tier notin (web)").match(labels)).To(BeTrue())
+		Expect(parse("team=qa").match(labels)).To(BeFalse())
+		Expect(parse("env notin (prod)").match(labels)).To(BeFalse())
+		Expect(parse("tier").match(labels)).To(BeFalse())
+		Expect(labelSelector(nil).match(nil)).To(BeTrue())
+	})
+
+	Describe("orgs", func() {
+		var orgs []models.Org
+
+		BeforeEach(func() {
+			orgs = []models.Org{
+				{Name: "shop", Labels: map[string]string{"team": "payments"}, Spaces: []models.Space{
+					{Name: "prod", Labels: map[string]string{"env": "prod"}, Apps: []models.App{
+						{Name: "web"},
+						{Name: "batch", Labels: map[string]string{"env": "dev"}},
+					}},
+					{Name: "dev", Labels: map[string]string{"env": "dev"}, Apps: []models.App{{Name: "web"}}},
+				}},
+				{Name: "system", Spaces: []models.Space{{Name: "prod", Apps: []models.App{{Name: "router"}}}}},
+			}
+			inheritLabels(orgs)
+		})
+
+		It("inherits the labels of the org and space", func() {
+			Expect(orgs[0].Spaces[0].Labels).To(Equal(map[string]string{"team": "payments", "env": "prod"}))
+			Expect(orgs[0].Spaces[0].Apps[1].Labels).To(Equal(map[string]string{"team": "payments", "env": "dev"}))
+			Expect(orgs[0].Labels).To(Equal(map[string]string{"team": "payments"}))
+			Expect(orgs[1].Spaces[0].Apps[0].Labels).To(BeNil())
+		})
+
+		It("keeps the matching apps and their spaces and orgs", func() {
+			filtered := parse("env=prod").filterOrgs(orgs)
+			Expect(filtered).To(HaveLen(1))
+			Expect(filtered[0].Spaces).To(HaveLen(1))
+			Expect(filtered[0].Spaces[0].Apps).To(HaveLen(1))
+			Expect(filtered[0].Spaces[0].Apps[0].Name).To(Equal("web"))
+
+			filtered = parse("team=payments").filterOrgs(orgs)
+			Expect(filtered).To(HaveLen(1))
+			Expect(filtered[0].Spaces).To(HaveLen(2))
+			Expect(parse("!team").filterOrgs(orgs)[0].Name).To(Equal("system"))
+			Expect(labelSelector(nil).filterOrgs(orgs)).To(Equal(orgs))
+		})
+	})
+
+	It("selects service instances by their space or bound apps", func() {
+		cache := globalQueryCache{
+			siMap: map[string]apihelper.ServiceInstance{
+				"si-1": {GUID: "si-1", SpaceGUID: "s-1"},
+				"si-2": {GUID: "si-2", SpaceGUID: "s-1"},
+			},
+			spaceMap: map[string]apihelper.SpaceDetails{"s-1": {GUID: "s-1", OrgGUID: "o-1"}},
+			labels: apihelper.Labels{
+				Orgs: map[string]map[string]string{"o-1": {"team": "payments"}},
+				Apps: map[string]map[string]string{"a-1": {"env": "prod"}},
+			},
+		}
+		services := []models.Service{
+			{ServiceInstanceGUID: "si-1", AppGUIDs: []string{"a-1"}},
+			{ServiceInstanceGUID: "si-2"},
+		}
+
+		selected := parse("env=prod").labelServices(services, cache)
+		Expect(selected).To(HaveLen(1))
+		Expect(selected[0].ServiceInstanceGUID).To(Equal("si-1"))
+		Expect(selected[0].Labels).To(Equal(map[string]string{"team": "payments"}))
+		Expect(labelSelector(nil).labelServices(services, cache)).To(HaveLen(2))
+	})
+})
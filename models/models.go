@@ -2,35 +2,127 @@ package models
 
 import (
 	"bytes"
+	"encoding/csv"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// Unlimited is the memory quota of orgs and spaces whose quota definition
+// does not limit the memory.
+const Unlimited = -1
+
 type Org struct {
+	Foundation  string // only set in reports of several foundations
 	Name        string
-	MemoryQuota int
+	MemoryQuota int // MB or Unlimited
 	MemoryUsage int
 	Spaces      []Space
+	Labels      map[string]string
+	NoQuota     bool // no quota definition applies to the org
+
+	// QuotaName and QuotaUsages are only set for the quota report.
+	QuotaName   string
+	QuotaUsages []QuotaUsage
+}
+
+// QuotaUsage is what an org uses of one dimension of its quota, like its
+// app instances or routes.
+type QuotaUsage struct {
+	Dimension string
+	Unit      string // MB for memory, empty for counts
+	Used      int
+	Limit     int // Unlimited if the quota does not limit the dimension
 }
 
 type Space struct {
-	Apps      []App
-	Instances []Instance // all service instances in a space
-	Name      string
+	Apps        []App
+	Instances   []Instance // all service instances in a space
+	Name        string
+	Labels      map[string]string
+	MemoryQuota int  // MB of the space quota or Unlimited
+	HasQuota    bool // a space quota applies, otherwise only the org quota
 }
 
 type App struct {
 	Ram       int
+	Disk      int // MB per instance
 	Instances int
 	Running   bool
 	Name      string
 	SiTotal   int // Bound Service Instances Total
 	SiPCF     int // Bound PCF Service Instances
 	SiUP      int // Bound User Provided Service Instances
+	Labels    map[string]string
+	GUID      string
+
+	// Stats are the running instances, only set for the stats report.
+	Stats []InstanceStats
+
+	// Processes are the process types of the app, web first. Apps without
+	// them have a single web process of Instances, Ram and Disk.
+	Processes []Process
+
+	// Tasks are the running tasks of the app, also of a stopped one.
+	Tasks []Task
+
+	// Build is how the app is built, only set for the builds report.
+	Build Build
+}
+
+// Build is how an app is built: with buildpacks on a stack, or from a
+// docker image.
+type Build struct {
+	Stack       string
+	Buildpacks  []Buildpack
+	DockerImage string
+}
+
+// Buildpack is a buildpack an app is built with, Version is empty if
+// unknown.
+type Buildpack struct {
+	Name    string
+	Version string
+}
+
+// Task is a running task of an app. Memory and disk are in MB.
+type Task struct {
+	Name string
+	Ram  int
+	Disk int
+}
+
+// Process is a process type of an app. Memory and disk are in MB per
+// instance.
+type Process struct {
+	Type      string
+	Instances int
+	Ram       int
+	Disk      int
+	Sidecars  []Sidecar
+}
+
+// Sidecar runs next to the instances of a process. Its memory is part of
+// the memory of the process, 0 when it is not limited separately.
+type Sidecar struct {
+	Name string
+	Ram  int
+}
+
+// InstanceStats is the actual usage of a running instance of a process of
+// an app.
+type InstanceStats struct {
+	ProcessType string
+	Index       int
+	MemoryUsed  int     // MB
+	DiskUsed    int     // MB
+	DiskQuota   int     // MB
+	CPU         float64 // percent of a core
 }
 
 type Service struct {
+	Foundation          string // only set in reports of several foundations
 	ServiceInstanceGUID string
 	ServiceInstanceName string
 	ServiceInstanceType string
@@ -40,11 +132,110 @@ type Service struct {
 	ServiceName         string
 	ServiceType         string
 	AppGUIDs            []string
+	Labels              map[string]string
 }
 
 type Report struct {
+	Foundations      []string // names of the foundations of a combined report
 	Orgs             []Org
 	ServiceInstances []Service
+	Warnings         []Warning
+
+	// LabelColumns are the label keys added as columns to the CSV output.
+	// Their values are those of the space in the memory report and the
+	// service instance summary, and of the app in the app report. Labels
+	// are inherited from the org and space.
+	LabelColumns []string
+}
+
+// Warning is an org or space left out of the report because its data
+// could not be gathered. SpaceName is empty if the whole org is missing,
+// OrgName too if the whole foundation is missing.
+type Warning struct {
+	Foundation string
+	OrgName    string
+	SpaceName  string
+	Message    string
+}
+
+// AddFoundation adds the orgs, service instances and warnings of the report
+// of a foundation to a combined report of several foundations.
+func (report *Report) AddFoundation(name string, r Report) {
+	report.Foundations = append(report.Foundations, name)
+	for _, org := range r.Orgs {
+		org.Foundation = name
+		report.Orgs = append(report.Orgs, org)
+	}
+	for _, service := range r.ServiceInstances {
+		service.Foundation = name
+		report.ServiceInstances = append(report.ServiceInstances, service)
+	}
+	for _, warning := range r.Warnings {
+		warning.Foundation = name
+		report.Warnings = append(report.Warnings, warning)
+	}
+}
+
+// combined tells whether the report covers several foundations and needs
+// a Foundation column.
+func (report *Report) combined() bool {
+	return len(report.Foundations) > 0
+}
+
+// withFoundation prepends the foundation to the CSV record of a combined
+// report.
+func (report *Report) withFoundation(foundation string, record ...string) []string {
+	if !report.combined() {
+		return record
+	}
+	return append([]string{foundation}, record...)
+}
+
+// withLabels appends the values of the label columns to a CSV record.
+func (report *Report) withLabels(labels map[string]string, record ...string) []string {
+	for _, key := range report.LabelColumns {
+		record = append(record, labels[key])
+	}
+	return record
+}
+
+// quotaCSV renders a memory quota for the CSV output.
+func quotaCSV(limit int, none bool) string {
+	switch {
+	case none:
+		return "none"
+	case limit == Unlimited:
+		return "unlimited"
+	}
+	return strconv.Itoa(limit)
+}
+
+// quotaShare describes the memory consumed by a space relative to its space
+// quota if it has one, otherwise to the quota of its org.
+func quotaShare(consumed int, org Org, space Space) string {
+	kind, limit := "org", org.MemoryQuota
+	if space.HasQuota {
+		kind, limit = "space", space.MemoryQuota
+	} else if org.NoQuota {
+		return "without a quota"
+	}
+	switch limit {
+	case Unlimited:
+		return fmt.Sprintf("of unlimited %s quota", kind)
+	case 0:
+		return fmt.Sprintf("of 0 MB %s quota", kind)
+	}
+	return fmt.Sprintf("(%d%%) of %s quota", 100*consumed/limit, kind)
+}
+
+// writeFoundationHeader starts the section of foundation in the text output
+// of a combined report if it differs from the one of the previous line.
+func (report *Report) writeFoundationHeader(response *bytes.Buffer, foundation string, previous *string) {
+	if !report.combined() || foundation == *previous {
+		return
+	}
+	response.WriteString(fmt.Sprintf("Foundation %s\n", foundation))
+	*previous = foundation
 }
 
 type ServiceInstance struct {
@@ -78,16 +269,119 @@ func (org *Org) AppsCount() int {
 	return appsCount
 }
 
+// ConsumedMemory is the memory of the running apps and tasks of the space.
 func (space *Space) ConsumedMemory() int {
 	consumed := 0
 	for _, app := range space.Apps {
-		if app.Running {
-			consumed += int(app.Instances * app.Ram)
-		}
+		consumed += app.AllocatedMemory() + app.TaskMemory()
 	}
 	return consumed
 }
 
+// TaskMemory is the memory of the running tasks of the app.
+func (app *App) TaskMemory() int {
+	memory := 0
+	for _, t := range app.Tasks {
+		memory += t.Ram
+	}
+	return memory
+}
+
+// TaskDisk is the disk of the running tasks of the app.
+func (app *App) TaskDisk() int {
+	disk := 0
+	for _, t := range app.Tasks {
+		disk += t.Disk
+	}
+	return disk
+}
+
+func (space *Space) TaskMemory() int {
+	memory := 0
+	for _, app := range space.Apps {
+		memory += app.TaskMemory()
+	}
+	return memory
+}
+
+func (space *Space) RunningTasksCount() int {
+	count := 0
+	for _, app := range space.Apps {
+		count += len(app.Tasks)
+	}
+	return count
+}
+
+// processes returns the process types of the app.
+func (app *App) processes() []Process {
+	if len(app.Processes) > 0 {
+		return app.Processes
+	}
+	return []Process{{Type: "web", Instances: app.Instances, Ram: app.Ram, Disk: app.Disk}}
+}
+
+// ProcessTypes returns the types of the processes of the app.
+func (app *App) ProcessTypes() []string {
+	var types []string
+	for _, p := range app.processes() {
+		types = append(types, p.Type)
+	}
+	return types
+}
+
+// processRam is the memory limit of the instances of the process type of
+// the app.
+func (app *App) processRam(processType string) int {
+	for _, p := range app.processes() {
+		if p.Type == processType {
+			return p.Ram
+		}
+	}
+	return 0
+}
+
+// InstancesCount is the number of instances of all processes of the app.
+func (app *App) InstancesCount() int {
+	count := 0
+	for _, p := range app.processes() {
+		count += p.Instances
+	}
+	return count
+}
+
+// AllocatedDisk is the disk of all instances of the running apps of the
+// space.
+func (space *Space) AllocatedDisk() int {
+	allocated := 0
+	for _, app := range space.Apps {
+		allocated += app.AllocatedDisk()
+	}
+	return allocated
+}
+
+// AllocatedDisk is the disk of all instances of all processes of a running
+// app.
+func (app *App) AllocatedDisk() int {
+	if !app.Running {
+		return 0
+	}
+	allocated := 0
+	for _, p := range app.processes() {
+		allocated += p.Instances * p.Disk
+	}
+	return allocated
+}
+
+// AllocatedDisk is the disk allocated to the running apps of the spaces of
+// the org in the report.
+func (org *Org) AllocatedDisk() int {
+	allocated := 0
+	for _, space := range org.Spaces {
+		allocated += space.AllocatedDisk()
+	}
+	return allocated
+}
+
 func (space *Space) RunningAppsCount() int {
 	runningAppsCount := 0
 	for _, app := range space.Apps {
@@ -101,7 +395,7 @@ func (space *Space) RunningAppsCount() int {
 func (space *Space) InstancesCount() int {
 	instancesCount := 0
 	for _, app := range space.Apps {
-		instancesCount += int(app.Instances)
+		instancesCount += app.InstancesCount()
 	}
 	return instancesCount
 }
@@ -110,7 +404,7 @@ func (space *Space) RunningInstancesCount() int {
 	runningInstancesCount := 0
 	for _, app := range space.Apps {
 		if app.Running {
-			runningInstancesCount += app.Instances
+			runningInstancesCount += app.InstancesCount()
 		}
 	}
 	return runningInstancesCount
@@ -123,13 +417,17 @@ func (report *Report) BuildOrgAndSpacesUsingServiceInstances() {
 	type org struct {
 		spaceNames map[string]struct{}
 	}
+	type orgKey struct {
+		foundation, name string
+	}
 
 	report.Orgs = nil
 
 	// build up map of org and space names
-	oMap := make(map[string]org)
+	oMap := make(map[orgKey]org)
 	for _, v := range report.ServiceInstances {
-		if om, orgExists := oMap[v.OrgName]; orgExists == true {
+		key := orgKey{v.Foundation, v.OrgName}
+		if om, orgExists := oMap[key]; orgExists == true {
 			if _, spaceExists := om.spaceNames[v.SpaceName]; spaceExists == true {
 				continue
 			} else {
@@ -138,17 +436,17 @@ func (report *Report) BuildOrgAndSpacesUsingServiceInstances() {
 		} else {
 			sMap := make(map[string]struct{})
 			sMap[v.SpaceName] = null
-			oMap[v.OrgName] = org{spaceNames: sMap}
+			oMap[key] = org{spaceNames: sMap}
 		}
 	}
 
 	// create the org lists containing all names from the spaces
-	for orgName, s := range oMap {
+	for key, s := range oMap {
 		var spaces []Space
 		for spaceName, _ := range s.spaceNames {
 			spaces = append(spaces, Space{Name: spaceName})
 		}
-		report.Orgs = append(report.Orgs, Org{Name: orgName, Spaces: spaces})
+		report.Orgs = append(report.Orgs, Org{Foundation: key.foundation, Name: key.name, Spaces: spaces})
 	}
 }
 
@@ -158,14 +456,20 @@ func (report *Report) ServiceInstanceSummaryCSV() string {
 
 	report.BuildOrgAndSpacesUsingServiceInstances()
 
-	response.WriteString(fmt.Sprintf("OrgName,SpaceName,ServiceInstanceName,ServiceInstanceType,ServiceName,ServicePlanName,AmountOfBoundApps,BoundApps\n"))
+	headers := report.withFoundation("Foundation", "OrgName", "SpaceName", "ServiceInstanceName", "ServiceInstanceType", "ServiceName", "ServicePlanName", "AmountOfBoundApps", "BoundApps")
+	headers = append(headers, report.LabelColumns...)
+	response.WriteString(strings.Join(headers, ",") + "\n")
 
 	for _, org := range report.Orgs {
 		for _, space := range org.Spaces {
 			for _, service := range report.ServiceInstances {
-				if service.SpaceName == space.Name && service.OrgName == org.Name {
+				if service.SpaceName == space.Name && service.OrgName == org.Name && service.Foundation == org.Foundation {
 					apps := strings.Join(service.AppGUIDs, " ")
-					record := fmt.Sprintf("%s,%s,%s,%s,%s,%s,%d,%s\n", service.OrgName, service.SpaceName, service.ServiceInstanceName, service.ServiceInstanceType, service.ServiceName, service.ServicePlanName, len(service.AppGUIDs), apps)
+					record := fmt.Sprintf("%s,%s,%s,%s,%s,%s,%d,%s", service.OrgName, service.SpaceName, service.ServiceInstanceName, service.ServiceInstanceType, service.ServiceName, service.ServicePlanName, len(service.AppGUIDs), apps)
+					record = strings.Join(report.withLabels(service.Labels, record), ",") + "\n"
+					if report.combined() {
+						record = service.Foundation + "," + record
+					}
 					response.WriteString(record)
 				}
 			}
@@ -181,12 +485,14 @@ func (report *Report) ServiceInstanceSummaryString() string {
 
 	report.BuildOrgAndSpacesUsingServiceInstances()
 
+	var foundation string
 	for _, org := range report.Orgs {
 		for _, space := range org.Spaces {
 			first := true
 			for _, service := range report.ServiceInstances {
-				if service.SpaceName == space.Name && service.OrgName == org.Name {
+				if service.SpaceName == space.Name && service.OrgName == org.Name && service.Foundation == org.Foundation {
 					if first {
+						report.writeFoundationHeader(&response, org.Foundation, &foundation)
 						response.WriteString(fmt.Sprintf("Org %s\n", org.Name))
 						response.WriteString(fmt.Sprintf("\tSpace %s\n", space.Name))
 						first = false
@@ -204,17 +510,40 @@ func (report *Report) ServiceInstanceSummaryString() string {
 	return response.String()
 }
 
+// sidecarMemory is the memory the sidecars of the process are limited to.
+func (p Process) sidecarMemory() int {
+	memory := 0
+	for _, s := range p.Sidecars {
+		memory += s.Ram
+	}
+	return memory
+}
+
+// ServiceInstanceReportCSV lists the apps with their bound service
+// instances, one row per process type. AppInstances are the instances of
+// the web process, the other app columns sum up all processes and repeat on
+// every row of the app. The process columns are per instance.
 func (report *Report) ServiceInstanceReportCSV() string {
 	var response bytes.Buffer
 
-	response.WriteString(fmt.Sprintf("OrgName,SpaceName,AppName,AppInstances,BoundServiceInstances,BoundPCFServices,BoundUserProvidedServices,Bound3rdPartyServices\n"))
+	headers := report.withFoundation("Foundation", "OrgName", "SpaceName", "AppName", "AppInstances", "BoundServiceInstances", "BoundPCFServices", "BoundUserProvidedServices", "Bound3rdPartyServices", "AppDiskAllocated",
+		"AppMemoryAllocated", "TasksRunning", "TaskMemory", "ProcessType", "ProcessInstances", "ProcessMemory", "ProcessDisk", "Sidecars", "SidecarMemory")
+	headers = append(headers, report.LabelColumns...)
+	response.WriteString(strings.Join(headers, ",") + "\n")
 
 	for _, org := range report.Orgs {
 		for _, space := range org.Spaces {
 			for _, app := range space.Apps {
 				thrdParty := app.SiTotal - app.SiPCF - app.SiUP
-				record := fmt.Sprintf("%s,%s,%s,%d,%d,%d,%d,%d\n", org.Name, space.Name, app.Name, app.Instances, app.SiTotal, app.SiPCF, app.SiUP, thrdParty)
-				response.WriteString(record)
+				for _, p := range app.processes() {
+					record := fmt.Sprintf("%s,%s,%s,%d,%d,%d,%d,%d,%d,%d,%d,%d,%s,%d,%d,%d,%d,%d", org.Name, space.Name, app.Name, app.Instances, app.SiTotal, app.SiPCF, app.SiUP, thrdParty, app.AllocatedDisk(),
+						app.AllocatedMemory(), len(app.Tasks), app.TaskMemory(), p.Type, p.Instances, p.Ram, p.Disk, len(p.Sidecars), p.sidecarMemory())
+					record = strings.Join(report.withLabels(app.Labels, record), ",") + "\n"
+					if report.combined() {
+						record = org.Foundation + "," + record
+					}
+					response.WriteString(record)
+				}
 			}
 		}
 	}
@@ -225,14 +554,30 @@ func (report *Report) ServiceInstanceReportCSV() string {
 func (report *Report) ServiceInstanceReportString() string {
 	var response bytes.Buffer
 
+	var foundation string
 	for _, org := range report.Orgs {
+		report.writeFoundationHeader(&response, org.Foundation, &foundation)
 		response.WriteString(fmt.Sprintf("Org %s\n", org.Name))
 		for _, space := range org.Spaces {
 			response.WriteString(fmt.Sprintf("\tSpace %s\n", space.Name))
 			for _, app := range space.Apps {
 				thrdParty := app.SiTotal - app.SiPCF - app.SiUP
 
-				response.WriteString(fmt.Sprintf("\t\tApp %s has %d instances in total.\n", app.Name, app.Instances))
+				response.WriteString(fmt.Sprintf("\t\tApp %s has %d instances in total.\n", app.Name, app.InstancesCount()))
+				response.WriteString(fmt.Sprintf("\t\tIt has %d MB memory and %d MB disk allocated.\n", app.AllocatedMemory(), app.AllocatedDisk()))
+				for _, p := range app.processes() {
+					response.WriteString(fmt.Sprintf("\t\t\tProcess %s has %d instances of %d MB memory and %d MB disk.\n", p.Type, p.Instances, p.Ram, p.Disk))
+					for _, s := range p.Sidecars {
+						if s.Ram == 0 {
+							response.WriteString(fmt.Sprintf("\t\t\t\tSidecar %s shares its memory.\n", s.Name))
+						} else {
+							response.WriteString(fmt.Sprintf("\t\t\t\tSidecar %s uses %d MB of it.\n", s.Name, s.Ram))
+						}
+					}
+				}
+				for _, t := range app.Tasks {
+					response.WriteString(fmt.Sprintf("\t\t\tTask %s is running with %d MB memory and %d MB disk.\n", t.Name, t.Ram, t.Disk))
+				}
 				response.WriteString(fmt.Sprintf("\t\tIt has %d service instances bound in total.\n", app.SiTotal))
 				response.WriteString(fmt.Sprintf("\t\tFrom that there are %d PCF service instances, %d user provided service instances,\n", app.SiPCF, app.SiUP))
 				response.WriteString(fmt.Sprintf("\t\tand %d 3rd party instances bound.\n\n", thrdParty))
@@ -246,12 +591,60 @@ func (report *Report) ServiceInstanceReportString() string {
 func (report *Report) String() string {
 	var response bytes.Buffer
 
+	if !report.combined() {
+		totalApps, totalInstances := writeOrgs(&response, report.Orgs)
+		response.WriteString(
+			fmt.Sprintf("You are running %d apps in %d org(s), with a total of %d instances.\n",
+				totalApps, len(report.Orgs), totalInstances))
+		return response.String()
+	}
+
+	// a section per foundation followed by the grand totals
 	totalApps := 0
 	totalInstances := 0
+	for _, foundation := range report.Foundations {
+		var orgs []Org
+		for _, org := range report.Orgs {
+			if org.Foundation == foundation {
+				orgs = append(orgs, org)
+			}
+		}
 
-	for _, org := range report.Orgs {
-		response.WriteString(fmt.Sprintf("Org %s is consuming %d MB of %d MB.\n",
-			org.Name, org.MemoryUsage, org.MemoryQuota))
+		response.WriteString(fmt.Sprintf("Foundation %s\n", foundation))
+		apps, instances := writeOrgs(&response, orgs)
+		response.WriteString(
+			fmt.Sprintf("Foundation %s is running %d apps in %d org(s), with a total of %d instances.\n\n",
+				foundation, apps, len(orgs), instances))
+		totalApps += apps
+		totalInstances += instances
+	}
+
+	response.WriteString(
+		fmt.Sprintf("You are running %d apps in %d org(s) on %d foundation(s), with a total of %d instances.\n",
+			totalApps, len(report.Orgs), len(report.Foundations), totalInstances))
+
+	return response.String()
+}
+
+// writeOrgs writes the memory usage of the orgs and their spaces and
+// returns the number of apps and instances.
+func writeOrgs(response *bytes.Buffer, orgs []Org) (int, int) {
+	totalApps := 0
+	totalInstances := 0
+
+	for _, org := range orgs {
+		switch {
+		case org.NoQuota:
+			response.WriteString(fmt.Sprintf("Org %s is consuming %d MB, it has no quota.",
+				org.Name, org.MemoryUsage))
+		case org.MemoryQuota == Unlimited:
+			response.WriteString(fmt.Sprintf("Org %s is consuming %d MB of unlimited memory.",
+				org.Name, org.MemoryUsage))
+		default:
+			response.WriteString(fmt.Sprintf("Org %s is consuming %d MB of %d MB.",
+				org.Name, org.MemoryUsage, org.MemoryQuota))
+		}
+		response.WriteString(fmt.Sprintf(" Its apps have %d MB disk allocated.\n", org.AllocatedDisk()))
 
 		for _, space := range org.Spaces {
 			spaceRunningAppsCount := space.RunningAppsCount()
@@ -260,32 +653,35 @@ func (report *Report) String() string {
 			spaceConsumedMemory := space.ConsumedMemory()
 
 			response.WriteString(
-				fmt.Sprintf("\tSpace %s is consuming %d MB memory (%d%%) of org quota.\n",
-					space.Name, spaceConsumedMemory, (100 * spaceConsumedMemory / org.MemoryQuota)))
+				fmt.Sprintf("\tSpace %s is consuming %d MB memory %s.\n",
+					space.Name, spaceConsumedMemory, quotaShare(spaceConsumedMemory, org, space)))
 			response.WriteString(
 				fmt.Sprintf("\t\t%d apps: %d running %d stopped\n", len(space.Apps),
 					spaceRunningAppsCount, len(space.Apps)-spaceRunningAppsCount))
 			response.WriteString(
 				fmt.Sprintf("\t\t%d instances: %d running, %d stopped\n", spaceInstancesCount,
 					spaceRunningInstancesCount, spaceInstancesCount-spaceRunningInstancesCount))
+			response.WriteString(
+				fmt.Sprintf("\t\t%d MB disk allocated\n", space.AllocatedDisk()))
+			if tasks := space.RunningTasksCount(); tasks > 0 {
+				response.WriteString(
+					fmt.Sprintf("\t\t%d tasks running with %d MB memory\n", tasks, space.TaskMemory()))
+			}
 		}
 
 		totalApps += org.AppsCount()
 		totalInstances += org.InstancesCount()
 	}
 
-	response.WriteString(
-		fmt.Sprintf("You are running %d apps in %d org(s), with a total of %d instances.\n",
-			totalApps, len(report.Orgs), totalInstances))
-
-	return response.String()
+	return totalApps, totalInstances
 }
 
 func (report *Report) CSV() string {
 	var rows = [][]string{}
 	var csv bytes.Buffer
 
-	var headers = []string{"OrgName", "SpaceName", "SpaceMemoryUsed", "OrgMemoryQuota", "AppsDeployed", "AppsRunning", "AppInstancesDeployed", "AppInstancesRunning"}
+	var headers = report.withFoundation("Foundation", "OrgName", "SpaceName", "SpaceMemoryUsed", "OrgMemoryQuota", "AppsDeployed", "AppsRunning", "AppInstancesDeployed", "AppInstancesRunning", "SpaceMemoryQuota", "SpaceDiskAllocated", "OrgDiskAllocated", "SpaceTasksRunning", "SpaceTaskMemory")
+	headers = append(headers, report.LabelColumns...)
 
 	rows = append(rows, headers)
 
@@ -293,16 +689,21 @@ func (report *Report) CSV() string {
 		for _, space := range org.Spaces {
 			appsDeployed := len(space.Apps)
 
-			spaceResult := []string{
+			spaceResult := report.withFoundation(org.Foundation, report.withLabels(space.Labels,
 				org.Name,
 				space.Name,
 				strconv.Itoa(space.ConsumedMemory()),
-				strconv.Itoa(org.MemoryQuota),
+				quotaCSV(org.MemoryQuota, org.NoQuota),
 				strconv.Itoa(appsDeployed),
 				strconv.Itoa(space.RunningAppsCount()),
 				strconv.Itoa(space.InstancesCount()),
 				strconv.Itoa(space.RunningInstancesCount()),
-			}
+				quotaCSV(space.MemoryQuota, !space.HasQuota),
+				strconv.Itoa(space.AllocatedDisk()),
+				strconv.Itoa(org.AllocatedDisk()),
+				strconv.Itoa(space.RunningTasksCount()),
+				strconv.Itoa(space.TaskMemory()),
+			)...)
 
 			rows = append(rows, spaceResult)
 		}
@@ -315,3 +716,522 @@ func (report *Report) CSV() string {
 
 	return csv.String()
 }
+
+// AllocatedMemory is the memory of all instances of all processes of a
+// running app.
+func (app *App) AllocatedMemory() int {
+	if !app.Running {
+		return 0
+	}
+	allocated := 0
+	for _, p := range app.processes() {
+		allocated += p.Instances * p.Ram
+	}
+	return allocated
+}
+
+// processMemory is the memory of all processes of the running apps without
+// their tasks, which the stats do not cover.
+func (space *Space) processMemory() int {
+	memory := 0
+	for _, app := range space.Apps {
+		memory += app.AllocatedMemory()
+	}
+	return memory
+}
+
+func (org *Org) processMemory() int {
+	memory := 0
+	for _, space := range org.Spaces {
+		memory += space.processMemory()
+	}
+	return memory
+}
+
+// UsedMemory is the memory its running instances actually use.
+func (app *App) UsedMemory() int {
+	used := 0
+	for _, s := range app.Stats {
+		used += s.MemoryUsed
+	}
+	return used
+}
+
+func (space *Space) UsedMemory() int {
+	used := 0
+	for _, app := range space.Apps {
+		used += app.UsedMemory()
+	}
+	return used
+}
+
+// AllocatedMemory is the memory allocated to the running apps of the spaces
+// of the org in the report.
+func (org *Org) AllocatedMemory() int {
+	allocated := 0
+	for _, space := range org.Spaces {
+		allocated += space.ConsumedMemory()
+	}
+	return allocated
+}
+
+func (org *Org) UsedMemory() int {
+	used := 0
+	for _, space := range org.Spaces {
+		used += space.UsedMemory()
+	}
+	return used
+}
+
+// overProvisioning returns the ratio of allocated to used memory. It is
+// false if no memory is used.
+func overProvisioning(allocated, used int) (float64, bool) {
+	if used == 0 {
+		return 0, false
+	}
+	return float64(allocated) / float64(used), true
+}
+
+// provisioningText describes how much more memory is allocated than used.
+func provisioningText(allocated, used int) string {
+	ratio, ok := overProvisioning(allocated, used)
+	if !ok {
+		return "no memory in use"
+	}
+	return fmt.Sprintf("%.1fx over-provisioned", ratio)
+}
+
+// provisioningCSV renders the over-provisioning ratio for the CSV output,
+// it is empty if no memory is used.
+func provisioningCSV(allocated, used int) string {
+	ratio, ok := overProvisioning(allocated, used)
+	if !ok {
+		return ""
+	}
+	return strconv.FormatFloat(ratio, 'f', 2, 64)
+}
+
+// StatsString compares the memory allocated to the running apps with the
+// memory their instances actually use, and lists the usage per instance.
+func (report *Report) StatsString() string {
+	var response bytes.Buffer
+
+	var foundation string
+	for _, org := range report.Orgs {
+		report.writeFoundationHeader(&response, org.Foundation, &foundation)
+		allocated, used := org.processMemory(), org.UsedMemory()
+		response.WriteString(fmt.Sprintf("Org %s has %d MB allocated to running apps and uses %d MB (%s).\n",
+			org.Name, allocated, used, provisioningText(allocated, used)))
+		for _, space := range org.Spaces {
+			allocated, used := space.processMemory(), space.UsedMemory()
+			response.WriteString(fmt.Sprintf("\tSpace %s has %d MB allocated and uses %d MB (%s).\n",
+				space.Name, allocated, used, provisioningText(allocated, used)))
+			for _, app := range space.Apps {
+				if !app.Running {
+					continue
+				}
+				allocated, used := app.AllocatedMemory(), app.UsedMemory()
+				response.WriteString(fmt.Sprintf("\t\tApp %s has %d MB allocated and uses %d MB (%s).\n",
+					app.Name, allocated, used, provisioningText(allocated, used)))
+				for _, s := range app.Stats {
+					response.WriteString(fmt.Sprintf("\t\t\tInstance %d of %s uses %d of %d MB memory, %d of %d MB disk and %.1f%% CPU.\n",
+						s.Index, s.ProcessType, s.MemoryUsed, app.processRam(s.ProcessType), s.DiskUsed, s.DiskQuota, s.CPU))
+				}
+			}
+		}
+	}
+
+	return response.String()
+}
+
+// StatsCSV lists the usage of every running instance of every process next
+// to its allocation, with the over-provisioning ratios of its app, space and
+// org. The label columns hold the labels of the app.
+func (report *Report) StatsCSV() string {
+	var response bytes.Buffer
+
+	w := csv.NewWriter(&response)
+	headers := report.withFoundation("Foundation", "OrgName", "SpaceName", "AppName", "InstanceIndex", "MemoryUsed", "MemoryAllocated", "DiskUsed", "DiskAllocated", "CPUPercent", "AppOverProvisioning", "SpaceOverProvisioning", "OrgOverProvisioning", "ProcessType")
+	w.Write(append(headers, report.LabelColumns...))
+	for _, org := range report.Orgs {
+		orgRatio := provisioningCSV(org.processMemory(), org.UsedMemory())
+		for _, space := range org.Spaces {
+			spaceRatio := provisioningCSV(space.processMemory(), space.UsedMemory())
+			for _, app := range space.Apps {
+				appRatio := provisioningCSV(app.AllocatedMemory(), app.UsedMemory())
+				for _, s := range app.Stats {
+					w.Write(report.withFoundation(org.Foundation, report.withLabels(app.Labels,
+						org.Name,
+						space.Name,
+						app.Name,
+						strconv.Itoa(s.Index),
+						strconv.Itoa(s.MemoryUsed),
+						strconv.Itoa(app.processRam(s.ProcessType)),
+						strconv.Itoa(s.DiskUsed),
+						strconv.Itoa(s.DiskQuota),
+						strconv.FormatFloat(s.CPU, 'f', 1, 64),
+						appRatio,
+						spaceRatio,
+						orgRatio,
+						s.ProcessType,
+					)...))
+				}
+			}
+		}
+	}
+	w.Flush()
+
+	return response.String()
+}
+
+// Percent returns the share of its limit the usage reaches. It is false for
+// unlimited dimensions and for limits of 0 which nothing uses.
+func (u QuotaUsage) Percent() (int, bool) {
+	switch {
+	case u.Limit == Unlimited:
+		return 0, false
+	case u.Limit == 0 && u.Used == 0:
+		return 0, false
+	case u.Limit == 0:
+		return 100, true
+	}
+	return 100 * u.Used / u.Limit, true
+}
+
+// ClosestToLimit returns the quota dimension of the org with the highest
+// share of its limit in use. It is false if no dimension is limited.
+func (org *Org) ClosestToLimit() (QuotaUsage, bool) {
+	var closest QuotaUsage
+	found := false
+	highest := -1
+	for _, u := range org.QuotaUsages {
+		if percent, ok := u.Percent(); ok && !org.NoQuota && percent > highest {
+			closest, found, highest = u, true, percent
+		}
+	}
+	return closest, found
+}
+
+// withUnit appends the unit of the usage to a number.
+func (u QuotaUsage) withUnit(n int) string {
+	if u.Unit == "" {
+		return strconv.Itoa(n)
+	}
+	return fmt.Sprintf("%d %s", n, u.Unit)
+}
+
+// QuotaString lists the usage of every dimension of the quota of the orgs.
+func (report *Report) QuotaString() string {
+	var response bytes.Buffer
+
+	var foundation string
+	for _, org := range report.Orgs {
+		report.writeFoundationHeader(&response, org.Foundation, &foundation)
+		if org.NoQuota {
+			response.WriteString(fmt.Sprintf("Org %s has no quota:\n", org.Name))
+		} else {
+			response.WriteString(fmt.Sprintf("Org %s uses quota %s:\n", org.Name, org.QuotaName))
+		}
+		for _, u := range org.QuotaUsages {
+			percent, limited := u.Percent()
+			switch {
+			case org.NoQuota:
+				response.WriteString(fmt.Sprintf("\t%s: %s\n", u.Dimension, u.withUnit(u.Used)))
+			case u.Limit == Unlimited:
+				response.WriteString(fmt.Sprintf("\t%s: %s of unlimited\n", u.Dimension, u.withUnit(u.Used)))
+			case !limited:
+				response.WriteString(fmt.Sprintf("\t%s: %s of %s\n", u.Dimension, u.withUnit(u.Used), u.withUnit(u.Limit)))
+			default:
+				response.WriteString(fmt.Sprintf("\t%s: %s of %s (%d%%)\n", u.Dimension, u.withUnit(u.Used), u.withUnit(u.Limit), percent))
+			}
+		}
+		if closest, ok := org.ClosestToLimit(); ok {
+			percent, _ := closest.Percent()
+			response.WriteString(fmt.Sprintf("\tClosest to its limit: %s (%d%%)\n", closest.Dimension, percent))
+		}
+	}
+
+	return response.String()
+}
+
+// QuotaCSV lists the usage of every dimension of the quota of the orgs, a
+// row per org and dimension. ClosestToLimit marks the dimension of each org
+// with the highest share of its limit in use.
+func (report *Report) QuotaCSV() string {
+	var response bytes.Buffer
+
+	w := csv.NewWriter(&response)
+	w.Write(report.withFoundation("Foundation", "OrgName", "QuotaName", "Dimension", "Used", "Limit", "PercentUsed", "ClosestToLimit"))
+	for _, org := range report.Orgs {
+		closest, hasClosest := org.ClosestToLimit()
+		for _, u := range org.QuotaUsages {
+			var percentUsed string
+			if percent, ok := u.Percent(); ok && !org.NoQuota {
+				percentUsed = strconv.Itoa(percent)
+			}
+			isClosest := hasClosest && u.Dimension == closest.Dimension
+			w.Write(report.withFoundation(org.Foundation, org.Name, org.QuotaName, u.Dimension,
+				strconv.Itoa(u.Used), quotaCSV(u.Limit, org.NoQuota), percentUsed, strconv.FormatBool(isClosest)))
+		}
+	}
+	w.Flush()
+
+	return response.String()
+}
+
+// buildGroup is a stack, a buildpack in a version or a docker image apps
+// are built with. Kind is stack, buildpack or docker, in this order in the
+// builds report.
+type buildGroup struct {
+	Kind    string
+	Name    string
+	Version string
+}
+
+var buildKinds = map[string]int{"stack": 0, "buildpack": 1, "docker": 2}
+
+func (g buildGroup) String() string {
+	switch {
+	case g.Kind == "stack":
+		return "Stack " + g.Name
+	case g.Kind == "docker":
+		return "Docker image " + g.Name
+	case g.Version == "":
+		return "Buildpack " + g.Name
+	}
+	return fmt.Sprintf("Buildpack %s %s", g.Name, g.Version)
+}
+
+// buildGroups returns the groups of the app. Docker apps only belong to
+// the one of their image, apps of unknown build to none.
+func (app *App) buildGroups() []buildGroup {
+	if app.Build.DockerImage != "" {
+		return []buildGroup{{Kind: "docker", Name: app.Build.DockerImage}}
+	}
+	var groups []buildGroup
+	if app.Build.Stack != "" {
+		groups = append(groups, buildGroup{Kind: "stack", Name: app.Build.Stack})
+	}
+	for _, bp := range app.Build.Buildpacks {
+		groups = append(groups, buildGroup{Kind: "buildpack", Name: bp.Name, Version: bp.Version})
+	}
+	return groups
+}
+
+// inBuildGroup tells whether the app belongs to group.
+func (app *App) inBuildGroup(group buildGroup) bool {
+	for _, g := range app.buildGroups() {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// buildCount counts the apps of a build group and the memory of the
+// running ones and their tasks.
+type buildCount struct {
+	Apps    int
+	Running int
+	Memory  int
+}
+
+func (c buildCount) String() string {
+	return fmt.Sprintf("%d apps, %d running with %d MB memory", c.Apps, c.Running, c.Memory)
+}
+
+// buildCounts counts the apps of the spaces per build group.
+func buildCounts(spaces ...Space) map[buildGroup]buildCount {
+	counts := make(map[buildGroup]buildCount)
+	for _, space := range spaces {
+		for _, app := range space.Apps {
+			for _, group := range app.buildGroups() {
+				c := counts[group]
+				c.Apps++
+				if app.Running {
+					c.Running++
+				}
+				c.Memory += app.AllocatedMemory() + app.TaskMemory()
+				counts[group] = c
+			}
+		}
+	}
+	return counts
+}
+
+// sortedBuildGroups returns the groups of counts, stacks first, then
+// buildpacks and docker images, each by name and version.
+func sortedBuildGroups(counts map[buildGroup]buildCount) []buildGroup {
+	groups := make([]buildGroup, 0, len(counts))
+	for group := range counts {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		if a.Kind != b.Kind {
+			return buildKinds[a.Kind] < buildKinds[b.Kind]
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Version < b.Version
+	})
+	return groups
+}
+
+// foundationOrgs splits the orgs of the report by foundation.
+func (report *Report) foundationOrgs() [][]Org {
+	var split [][]Org
+	for i, org := range report.Orgs {
+		if i == 0 || org.Foundation != report.Orgs[i-1].Foundation {
+			split = append(split, nil)
+		}
+		split[len(split)-1] = append(split[len(split)-1], org)
+	}
+	return split
+}
+
+// allSpaces returns the spaces of the orgs.
+func allSpaces(orgs []Org) []Space {
+	var spaces []Space
+	for _, org := range orgs {
+		spaces = append(spaces, org.Spaces...)
+	}
+	return spaces
+}
+
+// BuildsString lists the apps per stack, buildpack and docker image with
+// their counts and memory per foundation, org and space.
+func (report *Report) BuildsString() string {
+	var response bytes.Buffer
+
+	var foundation string
+	for _, orgs := range report.foundationOrgs() {
+		report.writeFoundationHeader(&response, orgs[0].Foundation, &foundation)
+		counts := buildCounts(allSpaces(orgs)...)
+		for _, group := range sortedBuildGroups(counts) {
+			response.WriteString(fmt.Sprintf("%s has %s.\n", group, counts[group]))
+			for _, org := range orgs {
+				orgCount, exists := buildCounts(org.Spaces...)[group]
+				if !exists {
+					continue
+				}
+				response.WriteString(fmt.Sprintf("\tOrg %s has %s.\n", org.Name, orgCount))
+				for _, space := range org.Spaces {
+					spaceCount, exists := buildCounts(space)[group]
+					if !exists {
+						continue
+					}
+					response.WriteString(fmt.Sprintf("\t\tSpace %s has %s.\n", space.Name, spaceCount))
+					for _, app := range space.Apps {
+						if !app.inBuildGroup(group) {
+							continue
+						}
+						if app.Running {
+							response.WriteString(fmt.Sprintf("\t\t\tApp %s is running with %d MB memory.\n", app.Name, app.AllocatedMemory()+app.TaskMemory()))
+						} else {
+							response.WriteString(fmt.Sprintf("\t\t\tApp %s is stopped.\n", app.Name))
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return response.String()
+}
+
+// BuildsCSV lists the apps per stack, buildpack and docker image, a row
+// per group and app with the counts of the group in its space, org and
+// foundation. The label columns hold the labels of the app.
+func (report *Report) BuildsCSV() string {
+	var response bytes.Buffer
+
+	w := csv.NewWriter(&response)
+	headers := report.withFoundation("Foundation", "BuildKind", "BuildName", "BuildVersion", "OrgName", "SpaceName", "AppName", "AppRunning", "AppMemoryAllocated",
+		"SpaceApps", "SpaceMemoryAllocated", "OrgApps", "OrgMemoryAllocated", "FoundationApps", "FoundationMemoryAllocated")
+	w.Write(append(headers, report.LabelColumns...))
+	for _, orgs := range report.foundationOrgs() {
+		counts := buildCounts(allSpaces(orgs)...)
+		for _, group := range sortedBuildGroups(counts) {
+			for _, org := range orgs {
+				orgCount := buildCounts(org.Spaces...)[group]
+				for _, space := range org.Spaces {
+					spaceCount := buildCounts(space)[group]
+					for _, app := range space.Apps {
+						if !app.inBuildGroup(group) {
+							continue
+						}
+						w.Write(report.withFoundation(org.Foundation, report.withLabels(app.Labels,
+							group.Kind,
+							group.Name,
+							group.Version,
+							org.Name,
+							space.Name,
+							app.Name,
+							strconv.FormatBool(app.Running),
+							strconv.Itoa(app.AllocatedMemory()+app.TaskMemory()),
+							strconv.Itoa(spaceCount.Apps),
+							strconv.Itoa(spaceCount.Memory),
+							strconv.Itoa(orgCount.Apps),
+							strconv.Itoa(orgCount.Memory),
+							strconv.Itoa(counts[group].Apps),
+							strconv.Itoa(counts[group].Memory),
+						)...))
+					}
+				}
+			}
+		}
+	}
+	w.Flush()
+
+	return response.String()
+}
+
+// WarningsString lists the orgs and spaces missing in the report, it is
+// empty if there are none.
+func (report *Report) WarningsString() string {
+	var response bytes.Buffer
+
+	if len(report.Warnings) == 0 {
+		return ""
+	}
+
+	response.WriteString(fmt.Sprintf("%d foundation(s), org(s) or space(s) are missing in the report:\n", len(report.Warnings)))
+	for _, w := range report.Warnings {
+		var where string
+		switch {
+		case w.OrgName == "":
+			where = "Foundation " + w.Foundation
+		case w.SpaceName == "":
+			where = "Org " + w.OrgName
+		default:
+			where = fmt.Sprintf("Space %s of org %s", w.SpaceName, w.OrgName)
+		}
+		if w.OrgName != "" && w.Foundation != "" {
+			where += " on foundation " + w.Foundation
+		}
+		response.WriteString(fmt.Sprintf("\t%s: %s\n", where, w.Message))
+	}
+
+	return response.String()
+}
+
+// WarningsCSV lists the orgs and spaces missing in the report as CSV, it is
+// empty if there are none. Messages are quoted as they may contain commas.
+func (report *Report) WarningsCSV() string {
+	var response bytes.Buffer
+
+	if len(report.Warnings) == 0 {
+		return ""
+	}
+
+	w := csv.NewWriter(&response)
+	w.Write(report.withFoundation("Foundation", "OrgName", "SpaceName", "Warning"))
+	for _, warning := range report.Warnings {
+		w.Write(report.withFoundation(warning.Foundation, warning.OrgName, warning.SpaceName, warning.Message))
+	}
+	w.Flush()
+
+	return response.String()
+}
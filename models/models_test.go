@@ -23,8 +23,8 @@ var _ = Describe("Models", func() {
 						Spaces: []Space{Space{
 							Name: "test-space",
 							Apps: []App{
-								App{Ram: 128, Instances: 2, Running: true, SiTotal: 10, SiPCF: 6, SiUP: 2, Name: "sample"},
-								App{Ram: 128, Instances: 1, Running: false, SiTotal: 4, SiPCF: 2, SiUP: 0, Name: "test"},
+								App{Ram: 128, Disk: 512, Instances: 2, Running: true, SiTotal: 10, SiPCF: 6, SiUP: 2, Name: "sample"},
+								App{Ram: 128, Disk: 1024, Instances: 1, Running: false, SiTotal: 4, SiPCF: 2, SiUP: 0, Name: "test"},
 							},
 						},
 						},
@@ -96,6 +96,213 @@ var _ = Describe("Models", func() {
 				Expect(report.ServiceInstanceSummaryString()).To(Equal(string(expectedOutput)))
 			})
 		})
+
+		Describe("Report#String with other quotas", func() {
+			It("should compare spaces with their space quota", func() {
+				report.Orgs[0].Spaces[0].MemoryQuota = 512
+				report.Orgs[0].Spaces[0].HasQuota = true
+				Expect(report.String()).To(ContainSubstring("\tSpace test-space is consuming 256 MB memory (50%) of space quota.\n"))
+				Expect(report.CSV()).To(ContainSubstring("test-org, test-space, 256, 4096, 2, 1, 3, 2, 512, 1024, 1024, 0, 0\n"))
+			})
+
+			It("should not divide by a zero or unlimited quota", func() {
+				report.Orgs[0].MemoryQuota = 0
+				Expect(report.String()).To(ContainSubstring("consuming 256 MB memory of 0 MB org quota.\n"))
+
+				report.Orgs[0].MemoryQuota = Unlimited
+				Expect(report.String()).To(ContainSubstring("Org test-org is consuming 256 MB of unlimited memory. Its apps have 1024 MB disk allocated.\n"))
+				Expect(report.String()).To(ContainSubstring("consuming 256 MB memory of unlimited org quota.\n"))
+				Expect(report.CSV()).To(ContainSubstring("test-org, test-space, 256, unlimited, 2, 1, 3, 2, none, 1024, 1024, 0, 0\n"))
+			})
+
+			It("should render orgs without a quota", func() {
+				report.Orgs[0].MemoryQuota = 0
+				report.Orgs[0].NoQuota = true
+				Expect(report.String()).To(ContainSubstring("Org test-org is consuming 256 MB, it has no quota. Its apps have 1024 MB disk allocated.\n" +
+					"\tSpace test-space is consuming 256 MB memory without a quota.\n"))
+				Expect(report.CSV()).To(ContainSubstring("test-org, test-space, 256, none, 2, 1, 3, 2, none, 1024, 1024, 0, 0\n"))
+			})
+		})
+
+		Describe("Report#Processes", func() {
+			BeforeEach(func() {
+				report.Orgs[0].Spaces[0].Apps[0].Processes = []Process{
+					{Type: "web", Instances: 2, Ram: 128, Disk: 512, Sidecars: []Sidecar{{Name: "proxy", Ram: 32}}},
+					{Type: "worker", Instances: 3, Ram: 256, Disk: 1024, Sidecars: []Sidecar{{Name: "agent"}, {Name: "logger", Ram: 16}}},
+				}
+			})
+
+			It("should sum the memory and instances of all processes", func() {
+				space := report.Orgs[0].Spaces[0]
+				Expect(space.ConsumedMemory()).To(Equal(1024))
+				Expect(space.AllocatedDisk()).To(Equal(4096))
+				Expect(space.InstancesCount()).To(Equal(6))
+				Expect(space.RunningInstancesCount()).To(Equal(5))
+			})
+
+			It("should list the processes with their sidecars", func() {
+				Expect(report.ServiceInstanceReportCSV()).To(ContainSubstring(
+					"test-org,test-space,sample,2,10,6,2,2,4096,1024,0,0,web,2,128,512,1,32\n" +
+						"test-org,test-space,sample,2,10,6,2,2,4096,1024,0,0,worker,3,256,1024,2,16\n"))
+				Expect(report.ServiceInstanceReportString()).To(ContainSubstring(
+					"\t\tApp sample has 5 instances in total.\n" +
+						"\t\tIt has 1024 MB memory and 4096 MB disk allocated.\n" +
+						"\t\t\tProcess web has 2 instances of 128 MB memory and 512 MB disk.\n" +
+						"\t\t\t\tSidecar proxy uses 32 MB of it.\n" +
+						"\t\t\tProcess worker has 3 instances of 256 MB memory and 1024 MB disk.\n" +
+						"\t\t\t\tSidecar agent shares its memory.\n" +
+						"\t\t\t\tSidecar logger uses 16 MB of it.\n"))
+			})
+		})
+
+		Describe("Report#Tasks", func() {
+			BeforeEach(func() {
+				report.Orgs[0].Spaces[0].Apps[1].Tasks = []Task{{Name: "migrate", Ram: 512, Disk: 1024}, {Name: "report", Ram: 256, Disk: 512}}
+			})
+
+			It("should count the memory of running tasks, also of stopped apps", func() {
+				Expect(report.Orgs[0].Spaces[0].ConsumedMemory()).To(Equal(1024))
+				Expect(report.String()).To(ContainSubstring("\tSpace test-space is consuming 1024 MB memory (25%) of org quota.\n"))
+				Expect(report.String()).To(ContainSubstring("\t\t1024 MB disk allocated\n\t\t2 tasks running with 768 MB memory\n"))
+				Expect(report.CSV()).To(ContainSubstring("test-org, test-space, 1024, 4096, 2, 1, 3, 2, none, 1024, 1024, 2, 768\n"))
+			})
+
+			It("should list the tasks of the apps", func() {
+				Expect(report.ServiceInstanceReportCSV()).To(ContainSubstring(
+					"test-org,test-space,test,1,4,2,0,2,0,0,2,768,web,1,128,1024,0,0\n"))
+				Expect(report.ServiceInstanceReportString()).To(ContainSubstring(
+					"\t\t\tProcess web has 1 instances of 128 MB memory and 1024 MB disk.\n" +
+						"\t\t\tTask migrate is running with 512 MB memory and 1024 MB disk.\n" +
+						"\t\t\tTask report is running with 256 MB memory and 512 MB disk.\n"))
+			})
+		})
+
+		Describe("Report#LabelColumns", func() {
+			It("should add the label values as CSV columns", func() {
+				report.LabelColumns = []string{"team", "env"}
+				report.Orgs[0].Spaces[0].Labels = map[string]string{"team": "payments", "env": "prod"}
+				report.Orgs[0].Spaces[0].Apps[0].Labels = map[string]string{"team": "payments"}
+				report.ServiceInstances[0].Labels = map[string]string{"env": "prod"}
+
+				Expect(report.CSV()).To(HavePrefix("OrgName, SpaceName, SpaceMemoryUsed, OrgMemoryQuota, AppsDeployed, AppsRunning, AppInstancesDeployed, AppInstancesRunning, SpaceMemoryQuota, SpaceDiskAllocated, OrgDiskAllocated, SpaceTasksRunning, SpaceTaskMemory, team, env\n" +
+					"test-org, test-space, 256, 4096, 2, 1, 3, 2, none, 1024, 1024, 0, 0, payments, prod\n"))
+				Expect(report.ServiceInstanceReportCSV()).To(ContainSubstring("test-org,test-space,sample,2,10,6,2,2,1024,256,0,0,web,2,128,512,0,0,payments,\n"))
+				Expect(report.ServiceInstanceReportCSV()).To(ContainSubstring("test-org,test-space,test,1,4,2,0,2,0,0,0,0,web,1,128,1024,0,0,,\n"))
+				Expect(report.ServiceInstanceSummaryCSV()).To(ContainSubstring(",BoundApps,team,env\n"))
+				Expect(report.ServiceInstanceSummaryCSV()).To(ContainSubstring(",2,123 321,,prod\n"))
+			})
+		})
+
+		Describe("Report#Stats", func() {
+			It("should compare the used memory with the allocation", func() {
+				report.Orgs[0].Spaces[0].Apps[0].Stats = []InstanceStats{{ProcessType: "web", Index: 0, MemoryUsed: 64, DiskUsed: 20, DiskQuota: 1024, CPU: 1.5}}
+				Expect(report.StatsString()).To(Equal("Org test-org has 256 MB allocated to running apps and uses 64 MB (4.0x over-provisioned).\n" +
+					"\tSpace test-space has 256 MB allocated and uses 64 MB (4.0x over-provisioned).\n" +
+					"\t\tApp sample has 256 MB allocated and uses 64 MB (4.0x over-provisioned).\n" +
+					"\t\t\tInstance 0 of web uses 64 of 128 MB memory, 20 of 1024 MB disk and 1.5% CPU.\n"))
+				Expect(report.StatsCSV()).To(HaveSuffix("test-org,test-space,sample,0,64,128,20,1024,1.5,4.00,4.00,4.00,web\n"))
+			})
+
+			It("should compare the used memory of all processes with their allocation", func() {
+				report.Orgs[0].Spaces[0].Apps[0].Processes = []Process{
+					{Type: "web", Instances: 2, Ram: 128, Disk: 512},
+					{Type: "worker", Instances: 1, Ram: 256, Disk: 512},
+				}
+				report.Orgs[0].Spaces[0].Apps[0].Stats = []InstanceStats{
+					{ProcessType: "web", Index: 0, MemoryUsed: 64, DiskUsed: 20, DiskQuota: 512, CPU: 1.5},
+					{ProcessType: "worker", Index: 0, MemoryUsed: 64, DiskUsed: 20, DiskQuota: 512, CPU: 3},
+				}
+				Expect(report.StatsString()).To(ContainSubstring("\tSpace test-space has 512 MB allocated and uses 128 MB (4.0x over-provisioned).\n" +
+					"\t\tApp sample has 512 MB allocated and uses 128 MB (4.0x over-provisioned).\n" +
+					"\t\t\tInstance 0 of web uses 64 of 128 MB memory, 20 of 512 MB disk and 1.5% CPU.\n" +
+					"\t\t\tInstance 0 of worker uses 64 of 256 MB memory, 20 of 512 MB disk and 3.0% CPU.\n"))
+				Expect(report.StatsCSV()).To(HaveSuffix("test-org,test-space,sample,0,64,128,20,512,1.5,4.00,4.00,4.00,web\n" +
+					"test-org,test-space,sample,0,64,256,20,512,3.0,4.00,4.00,4.00,worker\n"))
+			})
+
+			It("should not divide by an unused memory", func() {
+				Expect(report.StatsString()).To(ContainSubstring("App sample has 256 MB allocated and uses 0 MB (no memory in use).\n"))
+				Expect(report.StatsString()).ToNot(ContainSubstring("App test"))
+			})
+		})
+
+		Describe("Report#Builds", func() {
+			BeforeEach(func() {
+				report.Orgs[0].Spaces[0].Apps[0].Build = Build{Stack: "cflinuxfs3", Buildpacks: []Buildpack{{Name: "java_buildpack", Version: "4.16.1"}}}
+				report.Orgs[0].Spaces[0].Apps[1].Build = Build{DockerImage: "nginx:1.19"}
+			})
+
+			It("should list the apps per stack, buildpack and docker image", func() {
+				Expect(report.BuildsString()).To(Equal("Stack cflinuxfs3 has 1 apps, 1 running with 256 MB memory.\n" +
+					"\tOrg test-org has 1 apps, 1 running with 256 MB memory.\n" +
+					"\t\tSpace test-space has 1 apps, 1 running with 256 MB memory.\n" +
+					"\t\t\tApp sample is running with 256 MB memory.\n" +
+					"Buildpack java_buildpack 4.16.1 has 1 apps, 1 running with 256 MB memory.\n" +
+					"\tOrg test-org has 1 apps, 1 running with 256 MB memory.\n" +
+					"\t\tSpace test-space has 1 apps, 1 running with 256 MB memory.\n" +
+					"\t\t\tApp sample is running with 256 MB memory.\n" +
+					"Docker image nginx:1.19 has 1 apps, 0 running with 0 MB memory.\n" +
+					"\tOrg test-org has 1 apps, 0 running with 0 MB memory.\n" +
+					"\t\tSpace test-space has 1 apps, 0 running with 0 MB memory.\n" +
+					"\t\t\tApp test is stopped.\n"))
+			})
+
+			It("should return csv formated string with the counts of space, org and foundation", func() {
+				Expect(report.BuildsCSV()).To(Equal("BuildKind,BuildName,BuildVersion,OrgName,SpaceName,AppName,AppRunning,AppMemoryAllocated,SpaceApps,SpaceMemoryAllocated,OrgApps,OrgMemoryAllocated,FoundationApps,FoundationMemoryAllocated\n" +
+					"stack,cflinuxfs3,,test-org,test-space,sample,true,256,1,256,1,256,1,256\n" +
+					"buildpack,java_buildpack,4.16.1,test-org,test-space,sample,true,256,1,256,1,256,1,256\n" +
+					"docker,nginx:1.19,,test-org,test-space,test,false,0,1,0,1,0,1,0\n"))
+			})
+
+			It("should leave out apps of unknown build", func() {
+				report.Orgs[0].Spaces[0].Apps[1].Build = Build{}
+				Expect(report.BuildsString()).ToNot(ContainSubstring("App test"))
+			})
+		})
+
+		Describe("Report#Quota", func() {
+			BeforeEach(func() {
+				report.Orgs[0].QuotaName = "default"
+				report.Orgs[0].QuotaUsages = []QuotaUsage{
+					{Dimension: "memory", Unit: "MB", Used: 256, Limit: 4096},
+					{Dimension: "app instances", Used: 2, Limit: 4},
+					{Dimension: "service keys", Used: 3, Limit: Unlimited},
+					{Dimension: "reserved route ports", Used: 0, Limit: 0},
+				}
+			})
+
+			It("should return human readable formated string", func() {
+				Expect(report.QuotaString()).To(Equal("Org test-org uses quota default:\n" +
+					"\tmemory: 256 MB of 4096 MB (6%)\n" +
+					"\tapp instances: 2 of 4 (50%)\n" +
+					"\tservice keys: 3 of unlimited\n" +
+					"\treserved route ports: 0 of 0\n" +
+					"\tClosest to its limit: app instances (50%)\n"))
+			})
+
+			It("should return csv formated string", func() {
+				Expect(report.QuotaCSV()).To(Equal("OrgName,QuotaName,Dimension,Used,Limit,PercentUsed,ClosestToLimit\n" +
+					"test-org,default,memory,256,4096,6,false\n" +
+					"test-org,default,app instances,2,4,50,true\n" +
+					"test-org,default,service keys,3,unlimited,,false\n" +
+					"test-org,default,reserved route ports,0,0,,false\n"))
+			})
+
+			It("should count a used dimension with a limit of 0 as exhausted", func() {
+				report.Orgs[0].QuotaUsages[3].Used = 1
+				closest, ok := report.Orgs[0].ClosestToLimit()
+				Expect(ok).To(BeTrue())
+				Expect(closest.Dimension).To(Equal("reserved route ports"))
+			})
+
+			It("should render orgs without a quota", func() {
+				report.Orgs[0].NoQuota = true
+				report.Orgs[0].QuotaName = ""
+				Expect(report.QuotaString()).To(HavePrefix("Org test-org has no quota:\n\tmemory: 256 MB\n"))
+				Expect(report.QuotaString()).ToNot(ContainSubstring("Closest"))
+				Expect(report.QuotaCSV()).To(ContainSubstring("test-org,,memory,256,none,,false\n"))
+			})
+		})
 	})
 
 	Describe("Internal report builder", func() {
@@ -188,4 +395,46 @@ var _ = Describe("Models", func() {
 
 	})
 
+	Describe("Report#Warnings", func() {
+		var report Report
+
+		BeforeEach(func() {
+			report = Report{
+				Warnings: []Warning{
+					Warning{OrgName: "org-1", Message: "You are not authorized, to do that"},
+					Warning{OrgName: "org-2", SpaceName: "dev", Message: "Bad Things"},
+				},
+			}
+		})
+
+		It("should list the missing orgs and spaces", func() {
+			Expect(report.WarningsString()).To(Equal("2 foundation(s), org(s) or space(s) are missing in the report:\n" +
+				"\tOrg org-1: You are not authorized, to do that\n" +
+				"\tSpace dev of org org-2: Bad Things\n"))
+		})
+
+		It("should return csv formated string", func() {
+			Expect(report.WarningsCSV()).To(Equal("OrgName,SpaceName,Warning\n" +
+				"org-1,,\"You are not authorized, to do that\"\n" +
+				"org-2,dev,Bad Things\n"))
+		})
+
+		It("should name the foundation of combined reports", func() {
+			var combined Report
+			combined.AddFoundation("eu", report)
+			combined.AddFoundation("us", Report{Warnings: []Warning{Warning{Message: "Bad Things"}}})
+			Expect(combined.WarningsCSV()).To(Equal("Foundation,OrgName,SpaceName,Warning\n" +
+				"eu,org-1,,\"You are not authorized, to do that\"\n" +
+				"eu,org-2,dev,Bad Things\n" +
+				"us,,,Bad Things\n"))
+			Expect(combined.WarningsString()).To(ContainSubstring("\tFoundation us: Bad Things\n"))
+			Expect(combined.WarningsString()).To(ContainSubstring("\tSpace dev of org org-2 on foundation eu: Bad Things\n"))
+		})
+
+		It("should be empty without warnings", func() {
+			report.Warnings = nil
+			Expect(report.WarningsString()).To(BeEmpty())
+			Expect(report.WarningsCSV()).To(BeEmpty())
+		})
+	})
 })
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+
+	"github.com/dgruber/usagereport-plugin/apihelper"
+	"github.com/dgruber/usagereport-plugin/models"
+)
+
+// toModelProcesses converts the process types of an app.
+func toModelProcesses(processes []apihelper.Process) []models.Process {
+	converted := make([]models.Process, 0, len(processes))
+	for _, p := range processes {
+		converted = append(converted, models.Process{
+			Type:      p.Type,
+			Instances: int(p.Instances),
+			Ram:       int(p.RAM),
+			Disk:      int(p.Disk),
+		})
+	}
+	return converted
+}
+
+// toModelTasks converts the running tasks of an app.
+func toModelTasks(tasks []apihelper.Task) []models.Task {
+	var converted []models.Task
+	for _, t := range tasks {
+		converted = append(converted, models.Task{Name: t.Name, Ram: int(t.RAM), Disk: int(t.Disk)})
+	}
+	return converted
+}
+
+// toModelBuild converts how an app is built.
+func toModelBuild(build apihelper.Build) models.Build {
+	converted := models.Build{Stack: build.Stack, DockerImage: build.DockerImage}
+	for _, bp := range build.Buildpacks {
+		converted.Buildpacks = append(converted.Buildpacks, models.Buildpack{Name: bp.Name, Version: bp.Version})
+	}
+	return converted
+}
+
+// addCurrentBuilds replaces the configured builds of the apps of the orgs
+// with the ones of their current droplets, one request per app. Apps
+// without droplet and v2 apps keep theirs.
+func (cmd *UsageReportCmd) addCurrentBuilds(ctx context.Context, orgs []models.Org) error {
+	all := func(app *models.App) bool { return true }
+	return cmd.eachApp(ctx, orgs, "droplet", all, func(ctx context.Context, app *models.App) error {
+		build, err := cmd.apiHelper.GetAppBuild(ctx, app.GUID)
+		if apihelper.ErrNoDroplet == err {
+			return nil
+		}
+		if nil != err {
+			return err
+		}
+		app.Build = toModelBuild(build)
+		return nil
+	})
+}
+
+// addSidecars queries the sidecars of the apps of the orgs, one request per
+// app, and adds them to the processes they run next to.
+func (cmd *UsageReportCmd) addSidecars(ctx context.Context, orgs []models.Org) error {
+	all := func(app *models.App) bool { return true }
+	return cmd.eachApp(ctx, orgs, "sidecars", all, func(ctx context.Context, app *models.App) error {
+		sidecars, err := cmd.apiHelper.GetAppSidecars(ctx, app.GUID)
+		if nil != err {
+			return err
+		}
+		for _, s := range sidecars {
+			for _, processType := range s.ProcessTypes {
+				for i := range app.Processes {
+					if app.Processes[i].Type == processType {
+						app.Processes[i].Sidecars = append(app.Processes[i].Sidecars, models.Sidecar{Name: s.Name, Ram: int(s.RAM)})
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"github.com/dgruber/usagereport-plugin/apihelper"
+	"github.com/dgruber/usagereport-plugin/models"
+)
+
+// orgUsage is what an org uses of the dimensions of its quota. Like in the
+// Cloud Controller only started apps and running tasks count, and only
+// managed service instances.
+type orgUsage struct {
+	memory         int // MB of all instances
+	instanceMemory int // MB of the largest instance
+	appInstances   int
+	services       int
+	serviceKeys    int
+	routes         int
+	reservedPorts  int
+}
+
+// countOrgUsage sums up the usage of the orgs by their GUID out of the
+// foundation-wide lists of the query cache.
+func countOrgUsage(cache globalQueryCache, routes []apihelper.Route, keys []apihelper.ServiceKey) map[string]*orgUsage {
+	usage := make(map[string]*orgUsage)
+	ofSpace := func(spaceGUID string) *orgUsage {
+		orgGUID := cache.spaceMap[spaceGUID].OrgGUID
+		if usage[orgGUID] == nil {
+			usage[orgGUID] = &orgUsage{}
+		}
+		return usage[orgGUID]
+	}
+
+	for spaceGUID, apps := range cache.appMap {
+		u := ofSpace(spaceGUID)
+		for _, a := range apps {
+			for _, t := range cache.tasks[a.GUID] {
+				u.memory += int(t.RAM)
+			}
+			if !a.Running {
+				continue
+			}
+			for _, p := range a.AllProcesses() {
+				u.memory += int(p.Instances * p.RAM)
+				u.appInstances += int(p.Instances)
+				if int(p.RAM) > u.instanceMemory {
+					u.instanceMemory = int(p.RAM)
+				}
+			}
+		}
+	}
+	for _, si := range cache.siMap {
+		ofSpace(si.SpaceGUID).services++
+	}
+	for _, k := range keys {
+		if si, exists := cache.siMap[k.ServiceInstanceGUID]; exists {
+			ofSpace(si.SpaceGUID).serviceKeys++
+		}
+	}
+	for _, r := range routes {
+		u := ofSpace(r.SpaceGUID)
+		u.routes++
+		if r.Port != 0 {
+			u.reservedPorts++
+		}
+	}
+	return usage
+}
+
+// quotaUsages returns the usage of every dimension of quota.
+func quotaUsages(quota apihelper.Quota, u orgUsage) []models.QuotaUsage {
+	return []models.QuotaUsage{
+		{Dimension: "memory", Unit: "MB", Used: u.memory, Limit: int(quota.MemoryLimit)},
+		{Dimension: "instance memory", Unit: "MB", Used: u.instanceMemory, Limit: int(quota.InstanceMemoryLimit)},
+		{Dimension: "app instances", Used: u.appInstances, Limit: int(quota.AppInstanceLimit)},
+		{Dimension: "services", Used: u.services, Limit: int(quota.TotalServices)},
+		{Dimension: "service keys", Used: u.serviceKeys, Limit: int(quota.TotalServiceKeys)},
+		{Dimension: "routes", Used: u.routes, Limit: int(quota.TotalRoutes)},
+		{Dimension: "reserved route ports", Used: u.reservedPorts, Limit: int(quota.TotalReservedRoutePorts)},
+	}
+}
+
+// setQuotaUsages sets the quota of o and the usage of its dimensions on
+// org. Orgs without a quota get their usage only.
+func (cmd *UsageReportCmd) setQuotaUsages(org *models.Org, o apihelper.Organization, quota apihelper.Quota) {
+	var u orgUsage
+	if counted := cmd.queryCache.orgUsage[o.GUID]; counted != nil {
+		u = *counted
+	}
+	org.QuotaName = quota.Name
+	org.QuotaUsages = quotaUsages(quota, u)
+}
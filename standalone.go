@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/dgruber/usagereport-plugin/apihelper"
+)
+
+// Environment variables configuring the foundation of standalone runs
+const (
+	envAPI               = "CF_API"
+	envClientID          = "CF_CLIENT_ID"
+	envClientSecret      = "CF_CLIENT_SECRET"
+	envRefreshToken      = "CF_REFRESH_TOKEN"
+	envSkipSSLValidation = "CF_SKIP_SSL_VALIDATION"
+)
+
+// standalone tells whether the binary was started directly instead of by
+// the cf CLI, which passes the port of its RPC server as first argument.
+func standalone(args []string) bool {
+	if len(args) < 2 {
+		return true
+	}
+	_, err := strconv.Atoi(args[1])
+	return err != nil
+}
+
+// envConnection returns the connection to the foundation configured in
+// the environment. It authenticates with the client credentials or, if
+// there is no client secret, with the refresh token.
+func envConnection(getenv func(string) string) (apihelper.Connection, error) {
+	endpoint := getenv(envAPI)
+	if endpoint == "" {
+		return nil, fmt.Errorf("%s needs to be set to the API endpoint, e.g. https://api.example.com", envAPI)
+	}
+	sslDisabled := false
+	if value := getenv(envSkipSSLValidation); value != "" {
+		var err error
+		if sslDisabled, err = strconv.ParseBool(value); nil != err {
+			return nil, fmt.Errorf("%s needs to be true or false", envSkipSSLValidation)
+		}
+	}
+
+	clientID, clientSecret := getenv(envClientID), getenv(envClientSecret)
+	switch {
+	case clientID != "" && clientSecret != "":
+		return apihelper.NewClientCredentialsConnection(endpoint, clientID, clientSecret, sslDisabled), nil
+	case getenv(envRefreshToken) != "":
+		return apihelper.NewRefreshTokenConnection(endpoint, clientID, clientSecret, getenv(envRefreshToken), sslDisabled), nil
+	}
+	return nil, fmt.Errorf("%s and %s or %s need to be set", envClientID, envClientSecret, envRefreshToken)
+}
+
+// RunStandalone runs the report without the cf CLI. The foundation is
+// taken from the environment unless -targets or -replay is used.
+func (cmd *UsageReportCmd) RunStandalone(args []string) {
+	flagVals := ParseFlags(args)
+
+	var conn apihelper.Connection
+	if flagVals.Targets == "" && flagVals.Replay == "" {
+		var err error
+		if conn, err = envConnection(os.Getenv); nil != err {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+	}
+	cmd.run(conn, flagVals)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/dgruber/usagereport-plugin/apihelper"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Standalone", func() {
+	It("detects that the cf CLI did not start it", func() {
+		Expect(standalone([]string{"usagereport-plugin"})).To(BeTrue())
+		Expect(standalone([]string{"usagereport-plugin", "-format", "csv"})).To(BeTrue())
+		Expect(standalone([]string{"usagereport-plugin", "52617", "SendMetadata"})).To(BeFalse())
+	})
+
+	Describe("envConnection", func() {
+		var env map[string]string
+		getenv := func(key string) string { return env[key] }
+
+		BeforeEach(func() {
+			env = map[string]string{"CF_API": "https://api.example.com"}
+		})
+
+		It("uses the client credentials", func() {
+			env["CF_CLIENT_ID"] = "reporter"
+			env["CF_CLIENT_SECRET"] = "secret"
+			env["CF_SKIP_SSL_VALIDATION"] = "true"
+			conn, err := envConnection(getenv)
+			Expect(err).To(BeNil())
+			Expect(conn).To(BeAssignableToTypeOf(&apihelper.UAAConnection{}))
+			endpoint, _ := conn.ApiEndpoint()
+			Expect(endpoint).To(Equal("https://api.example.com"))
+			sslDisabled, _ := conn.IsSSLDisabled()
+			Expect(sslDisabled).To(BeTrue())
+		})
+
+		It("uses the refresh token", func() {
+			env["CF_REFRESH_TOKEN"] = "token"
+			_, err := envConnection(getenv)
+			Expect(err).To(BeNil())
+		})
+
+		It("fails without API endpoint or credentials", func() {
+			env["CF_CLIENT_ID"] = "reporter"
+			_, err := envConnection(getenv)
+			Expect(err).ToNot(BeNil())
+
+			env = map[string]string{"CF_REFRESH_TOKEN": "token"}
+			_, err = envConnection(getenv)
+			Expect(err).ToNot(BeNil())
+
+			env = map[string]string{"CF_API": "https://api.example.com", "CF_REFRESH_TOKEN": "token",
+				"CF_SKIP_SSL_VALIDATION": "maybe"}
+			_, err = envConnection(getenv)
+			Expect(err).ToNot(BeNil())
+		})
+	})
+})
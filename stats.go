@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dgruber/usagereport-plugin/apihelper"
+	"github.com/dgruber/usagereport-plugin/models"
+)
+
+const bytesPerMB = 1024 * 1024
+
+// addStats queries the usage of the running instances of every process of
+// the started apps of the orgs with up to cmd.parallelism concurrent
+// lookups. In partial mode apps whose stats can not be read are reported
+// without them and listed as warnings.
+func (cmd *UsageReportCmd) addStats(ctx context.Context, orgs []models.Org) error {
+	return cmd.eachApp(ctx, orgs, "stats", func(app *models.App) bool { return app.Running }, func(ctx context.Context, app *models.App) error {
+		var all []models.InstanceStats
+		for _, processType := range app.ProcessTypes() {
+			stats, err := cmd.apiHelper.GetAppStats(ctx, app.GUID, processType)
+			if nil != err {
+				return err
+			}
+			all = append(all, toModelStats(processType, stats)...)
+		}
+		app.Stats = all
+		return nil
+	})
+}
+
+// eachApp calls lookup for the apps of the orgs selected by include with up
+// to cmd.parallelism concurrent lookups. In partial mode the apps whose
+// lookup fails are listed as warnings about their what.
+func (cmd *UsageReportCmd) eachApp(ctx context.Context, orgs []models.Org, what string, include func(*models.App) bool, lookup func(context.Context, *models.App) error) error {
+	type appRef struct {
+		org, space, app int
+	}
+	var refs []appRef
+	for i := range orgs {
+		for j := range orgs[i].Spaces {
+			for k := range orgs[i].Spaces[j].Apps {
+				if include(&orgs[i].Spaces[j].Apps[k]) {
+					refs = append(refs, appRef{org: i, space: j, app: k})
+				}
+			}
+		}
+	}
+
+	errs := make([]error, len(refs))
+	err := runParallel(ctx, len(refs), cmd.parallelism, func(ctx context.Context, n int) error {
+		ref := refs[n]
+		err := lookup(ctx, &orgs[ref.org].Spaces[ref.space].Apps[ref.app])
+		return cmd.skip(ctx, err, &errs[n])
+	})
+
+	for n, lookupErr := range errs {
+		if nil != lookupErr {
+			ref := refs[n]
+			space := orgs[ref.org].Spaces[ref.space]
+			cmd.warn(orgs[ref.org].Name, space.Name, fmt.Errorf("%s of app %s: %v", what, space.Apps[ref.app].Name, lookupErr))
+		}
+	}
+	return err
+}
+
+// toModelStats converts the usage of the instances of the process type to
+// MB and percent of a core.
+func toModelStats(processType string, stats []apihelper.InstanceStats) []models.InstanceStats {
+	instances := make([]models.InstanceStats, 0, len(stats))
+	for _, s := range stats {
+		instances = append(instances, models.InstanceStats{
+			ProcessType: processType,
+			Index:       s.Index,
+			MemoryUsed:  int(s.Memory / bytesPerMB),
+			DiskUsed:    int(s.Disk / bytesPerMB),
+			DiskQuota:   int(s.DiskQuota / bytesPerMB),
+			CPU:         100 * s.CPU,
+		})
+	}
+	return instances
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"time"
 
 	"github.com/cloudfoundry/cli/plugin"
 	"github.com/dgruber/usagereport-plugin/apihelper"
@@ -20,82 +25,308 @@ type globalQueryCache struct {
 	orgMap   map[string]apihelper.OrgDetails
 	sbList   []apihelper.ServiceBinding
 	sbMap    map[string][]string
+
+	// foundation-wide inventory, only loaded in bulk mode
+	spaceList []apihelper.SpaceDetails
+	appMap    map[string][]apihelper.App // apps by space GUID
+	quotaMap  map[string]apihelper.Quota
+
+	// labels of orgs, spaces and apps, only loaded for -selector and -labels
+	labels apihelper.Labels
+
+	// space quota definitions by GUID
+	spaceQuotaMap map[string]apihelper.Quota
+
+	// usage of the org quota dimensions by org GUID, only loaded for -quotas
+	orgUsage map[string]*orgUsage
+
+	// running tasks by app GUID
+	tasks map[string][]apihelper.Task
+
+	// how the apps are built by app GUID, only loaded for -builds
+	builds map[string]apihelper.Build
 }
 
 // UsageReportCmd the plugin
 type UsageReportCmd struct {
-	apiHelper  apihelper.CFAPIHelper
-	queryCache globalQueryCache
+	name        string // foundation of a combined report
+	foundations []*UsageReportCmd
+	apiHelper   apihelper.CFAPIHelper
+	queryCache  globalQueryCache
+	scope       apihelper.Scope
+	parallelism int  // max. concurrent API lookups
+	bulk        bool // join foundation-wide lists instead of per org and space lookups
+	withLabels  bool // load the labels of orgs, spaces and apps
+	quotas      bool // load the usage of all org quota dimensions
+	builds      bool // load how the apps are built
+	partial     bool // skip orgs and spaces which fail instead of the report
+	warnings    []models.Warning
+	cache       *apihelper.CacheTransport
+	trace       *apihelper.TraceTransport
+	out         io.Writer // report output, os.Stdout if nil
+	errOut      io.Writer // notices and traces, os.Stderr if nil
 }
 
+// exitPartial is the exit status of a report which lacks orgs or spaces
+// skipped in partial mode. Other failures exit with 1, bad flags with 2.
+const exitPartial = 3
+
 // contains CLI flag values
 type flagVal struct {
-	OrgName              string
-	SpaceName            string
+	Selection            selection
+	Selector             labelSelector
+	LabelColumns         []string
 	Format               string
 	ShowServiceInstances string
+	APIVersion           string
+	Transport            string
+	Parallelism          int
+	MaxRetries           int
+	MaxRetryTime         time.Duration
+	RateLimit            float64
+	Bulk                 bool
+	Partial              bool
+	CacheDir             string
+	CacheTTL             time.Duration
+	Refresh              bool
+	Record               string
+	Replay               string
+	Trace                bool
+	Targets              string
+	Current              bool
+	Quotas               bool
+	Stats                bool
+	Builds               bool
+	Sidecars             bool
+	Timeout              time.Duration
+	RequestTimeout       time.Duration
 }
 
 func ParseFlags(args []string) flagVal {
 	flagSet := flag.NewFlagSet(args[0], flag.ExitOnError)
 
 	// Create flags
-	orgName := flagSet.String("o", "", "-o orgName")
-	spaceName := flagSet.String("s", "", "-s spaceName")
+	var orgs, spaces, excludeOrgs, excludeSpaces, labelColumns listFlag
+	flagSet.Var(&orgs, "o", "-o orgName")
+	flagSet.Var(&spaces, "s", "-s spaceName")
+	flagSet.Var(&excludeOrgs, "exclude-org", "-exclude-org orgName")
+	flagSet.Var(&excludeSpaces, "exclude-space", "-exclude-space spaceName")
+	selector := flagSet.String("selector", "", "-selector team=payments,env=prod")
+	flagSet.Var(&labelColumns, "labels", "-labels team,env")
 	showSI := flagSet.String("i", "", "-i <app|summary>")
 	format := flagSet.String("f", "format", "-f csv")
+	apiVersion := flagSet.String("api", apihelper.APIVersionAuto, "-api <auto|v2|v3>")
+	transport := flagSet.String("transport", apihelper.TransportHTTP, "-transport <http|curl>")
+	parallelism := flagSet.Int("p", defaultParallelism, "-p 8")
+	maxRetries := flagSet.Int("retries", apihelper.DefaultMaxRetries, "-retries 5")
+	maxRetryTime := flagSet.Duration("retry-time", apihelper.DefaultMaxRetryTime, "-retry-time 1m")
+	rateLimit := flagSet.Float64("rate", 0, "-rate 10")
+	bulk := flagSet.Bool("bulk", false, "-bulk")
+	partial := flagSet.Bool("partial", false, "-partial")
+	cacheDir := flagSet.String("cache-dir", "", "-cache-dir ~/.usagereport-cache")
+	cacheTTL := flagSet.Duration("cache-ttl", apihelper.DefaultCacheTTL, "-cache-ttl 10m")
+	refresh := flagSet.Bool("refresh", false, "-refresh")
+	record := flagSet.String("record", "", "-record dir")
+	replay := flagSet.String("replay", "", "-replay dir")
+	trace := flagSet.Bool("trace", false, "-trace")
+	targets := flagSet.String("targets", "", "-targets foundations.json")
+	current := flagSet.Bool("current", false, "-current")
+	quotas := flagSet.Bool("quotas", false, "-quotas")
+	stats := flagSet.Bool("stats", false, "-stats")
+	builds := flagSet.Bool("builds", false, "-builds")
+	sidecars := flagSet.Bool("sidecars", false, "-sidecars")
+	timeout := flagSet.Duration("timeout", 0, "-timeout 10m")
+	requestTimeout := flagSet.Duration("request-timeout", apihelper.DefaultRequestTimeout, "-request-timeout 1m")
 
 	err := flagSet.Parse(args[1:])
 	if err != nil {
 		os.Exit(2)
 	}
 
+	sel, err := newSelection(orgs, spaces, excludeOrgs, excludeSpaces)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-o, -s, -exclude-org and -exclude-space: %v\n", err)
+		os.Exit(2)
+	}
+
+	var labelSel labelSelector
+	if *selector != "" {
+		if labelSel, err = parseSelector(*selector); err != nil {
+			fmt.Fprintf(os.Stderr, "-selector: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
 	if *showSI != "" && *showSI != "app" && *showSI != "summary" {
 		fmt.Fprintf(os.Stderr, "-i requires to be either \"app\" or \"summary\" if set.\n")
 		os.Exit(2)
 	}
 
-	return flagVal{
-		OrgName:              string(*orgName),
-		SpaceName:            string(*spaceName),
-		Format:               string(*format),
-		ShowServiceInstances: string(*showSI),
+	if *sidecars && *showSI != "app" {
+		fmt.Fprintf(os.Stderr, "-sidecars requires -i app.\n")
+		os.Exit(2)
 	}
-}
 
-// createQueryCache makes global REST queries just once and stores them as a cache.
-func (cmd *UsageReportCmd) createQueryCache() error {
-	siMap, err := cmd.apiHelper.GetServiceInstanceMap()
-	if err != nil {
-		return err
+	if *quotas && *showSI != "" {
+		fmt.Fprintf(os.Stderr, "-quotas can not be combined with -i.\n")
+		os.Exit(2)
 	}
 
-	spMap, err := cmd.apiHelper.GetServicePlanMap()
-	if err != nil {
-		return err
+	if *stats && (*showSI != "" || *quotas) {
+		fmt.Fprintf(os.Stderr, "-stats can not be combined with -i or -quotas.\n")
+		os.Exit(2)
 	}
 
-	sMap, err := cmd.apiHelper.GetServiceMap()
-	if err != nil {
-		return err
+	if *builds && (*showSI != "" || *quotas || *stats) {
+		fmt.Fprintf(os.Stderr, "-builds can not be combined with -i, -quotas or -stats.\n")
+		os.Exit(2)
 	}
 
-	upsMap, err := cmd.apiHelper.GetUserProvidedServiceMap()
-	if err != nil {
-		return err
+	if *apiVersion != apihelper.APIVersionAuto && *apiVersion != apihelper.APIVersionV2 && *apiVersion != apihelper.APIVersionV3 {
+		fmt.Fprintf(os.Stderr, "-api requires to be either \"auto\", \"v2\" or \"v3\" if set.\n")
+		os.Exit(2)
 	}
 
-	spaceMap, err := cmd.apiHelper.GetSpaceMap()
-	if err != nil {
-		return err
+	if *transport != apihelper.TransportHTTP && *transport != apihelper.TransportCurl {
+		fmt.Fprintf(os.Stderr, "-transport requires to be either \"http\" or \"curl\" if set.\n")
+		os.Exit(2)
 	}
 
-	orgMap, err := cmd.apiHelper.GetOrgMap()
-	if err != nil {
-		return err
+	if *parallelism < 1 {
+		fmt.Fprintf(os.Stderr, "-p requires to be at least 1.\n")
+		os.Exit(2)
+	}
+
+	if *maxRetries < 0 || *maxRetryTime < 0 {
+		fmt.Fprintf(os.Stderr, "-retries and -retry-time must not be negative.\n")
+		os.Exit(2)
+	}
+
+	if *rateLimit < 0 {
+		fmt.Fprintf(os.Stderr, "-rate requires to be 0 (unlimited) or a positive number of requests per second.\n")
+		os.Exit(2)
+	}
+
+	if *cacheTTL <= 0 {
+		fmt.Fprintf(os.Stderr, "-cache-ttl requires to be a positive duration.\n")
+		os.Exit(2)
+	}
+
+	if *refresh && *cacheDir == "" {
+		fmt.Fprintf(os.Stderr, "-refresh requires -cache-dir to be set.\n")
+		os.Exit(2)
+	}
+
+	if *timeout < 0 || *requestTimeout < 0 {
+		fmt.Fprintf(os.Stderr, "-timeout and -request-timeout must not be negative.\n")
+		os.Exit(2)
+	}
+
+	if *targets != "" && *transport == apihelper.TransportCurl {
+		fmt.Fprintf(os.Stderr, "-targets can not be combined with -transport curl.\n")
+		os.Exit(2)
+	}
+
+	if *current && (*targets != "" || len(orgs) > 0) {
+		fmt.Fprintf(os.Stderr, "-current can not be combined with -targets or -o.\n")
+		os.Exit(2)
+	}
+
+	if *replay != "" && (*record != "" || *cacheDir != "") {
+		fmt.Fprintf(os.Stderr, "-replay can not be combined with -record or -cache-dir.\n")
+		os.Exit(2)
+	}
+
+	return flagVal{
+		Selection:            sel,
+		Selector:             labelSel,
+		LabelColumns:         labelColumns,
+		Format:               string(*format),
+		ShowServiceInstances: string(*showSI),
+		APIVersion:           string(*apiVersion),
+		Transport:            string(*transport),
+		Parallelism:          *parallelism,
+		MaxRetries:           *maxRetries,
+		MaxRetryTime:         *maxRetryTime,
+		RateLimit:            *rateLimit,
+		Bulk:                 *bulk,
+		Partial:              *partial,
+		CacheDir:             string(*cacheDir),
+		CacheTTL:             *cacheTTL,
+		Refresh:              *refresh,
+		Record:               string(*record),
+		Replay:               string(*replay),
+		Trace:                *trace,
+		Targets:              string(*targets),
+		Current:              *current,
+		Quotas:               *quotas,
+		Stats:                *stats,
+		Builds:               *builds,
+		Sidecars:             *sidecars,
+		Timeout:              *timeout,
+		RequestTimeout:       *requestTimeout,
 	}
+}
 
-	sbList, err := cmd.apiHelper.GetServiceBindingsList()
+// createQueryCache makes global REST queries just once and stores them as a cache.
+// The queries are independent of each other and run in parallel. The running
+// tasks are always loaded as their memory counts like the apps'. In bulk mode
+// and for -quotas all apps and quotas are loaded as well, for -quotas also
+// the routes and service keys, for -builds how the apps are configured to
+// be built.
+func (cmd *UsageReportCmd) createQueryCache(ctx context.Context) error {
+	var siMap map[string]apihelper.ServiceInstance
+	var spMap map[string]apihelper.ServicePlan
+	var sMap map[string]apihelper.Service
+	var upsMap map[string]apihelper.UserProvidedService
+	var spaceList []apihelper.SpaceDetails
+	var orgMap map[string]apihelper.OrgDetails
+	var sbList []apihelper.ServiceBinding
+	var appList []apihelper.App
+	var quotaMap map[string]apihelper.Quota
+	var labels apihelper.Labels
+	var spaceQuotaMap map[string]apihelper.Quota
+	var routes []apihelper.Route
+	var keys []apihelper.ServiceKey
+	var tasks []apihelper.Task
+	var builds []apihelper.Build
+
+	queries := []func(context.Context) error{
+		func(c context.Context) (err error) { siMap, err = cmd.apiHelper.GetServiceInstanceMap(c); return },
+		func(c context.Context) (err error) { spMap, err = cmd.apiHelper.GetServicePlanMap(c); return },
+		func(c context.Context) (err error) { sMap, err = cmd.apiHelper.GetServiceMap(c); return },
+		func(c context.Context) (err error) { upsMap, err = cmd.apiHelper.GetUserProvidedServiceMap(c); return },
+		func(c context.Context) (err error) { spaceList, err = cmd.apiHelper.GetSpaces(c); return },
+		func(c context.Context) (err error) { orgMap, err = cmd.apiHelper.GetOrgMap(c); return },
+		func(c context.Context) (err error) { sbList, err = cmd.apiHelper.GetServiceBindingsList(c); return },
+		func(c context.Context) (err error) { spaceQuotaMap, err = cmd.apiHelper.GetSpaceQuotaMap(c); return },
+		func(c context.Context) (err error) { tasks, err = cmd.apiHelper.GetRunningTasks(c); return },
+	}
+	if cmd.withLabels {
+		queries = append(queries,
+			func(c context.Context) (err error) { labels, err = cmd.apiHelper.GetLabels(c); return },
+		)
+	}
+	if cmd.bulk || cmd.quotas {
+		queries = append(queries,
+			func(c context.Context) (err error) { appList, err = cmd.apiHelper.GetApps(c); return },
+			func(c context.Context) (err error) { quotaMap, err = cmd.apiHelper.GetQuotaMap(c); return },
+		)
+	}
+	if cmd.quotas {
+		queries = append(queries,
+			func(c context.Context) (err error) { routes, err = cmd.apiHelper.GetRoutes(c); return },
+			func(c context.Context) (err error) { keys, err = cmd.apiHelper.GetServiceKeys(c); return },
+		)
+	}
+	if cmd.builds {
+		queries = append(queries,
+			func(c context.Context) (err error) { builds, err = cmd.apiHelper.GetBuilds(c); return },
+		)
+	}
+	err := runParallel(ctx, len(queries), cmd.parallelism, func(ctx context.Context, i int) error {
+		return queries[i](ctx)
+	})
 	if err != nil {
 		return err
 	}
@@ -116,10 +347,35 @@ func (cmd *UsageReportCmd) createQueryCache() error {
 		spMap:    spMap,
 		sMap:     sMap,
 		upsMap:   upsMap,
-		spaceMap: spaceMap,
+		spaceMap: apihelper.SpaceMap(spaceList),
 		orgMap:   orgMap,
 		sbList:   sbList,
 		sbMap:    sbMap,
+		labels:   labels,
+
+		spaceQuotaMap: spaceQuotaMap,
+		tasks:         make(map[string][]apihelper.Task),
+	}
+	for _, t := range tasks {
+		cmd.queryCache.tasks[t.AppGUID] = append(cmd.queryCache.tasks[t.AppGUID], t)
+	}
+	if cmd.bulk || cmd.quotas {
+		appMap := make(map[string][]apihelper.App)
+		for _, a := range appList {
+			appMap[a.SpaceGUID] = append(appMap[a.SpaceGUID], a)
+		}
+		cmd.queryCache.spaceList = spaceList
+		cmd.queryCache.appMap = appMap
+		cmd.queryCache.quotaMap = quotaMap
+	}
+	if cmd.quotas {
+		cmd.queryCache.orgUsage = countOrgUsage(cmd.queryCache, routes, keys)
+	}
+	if cmd.builds {
+		cmd.queryCache.builds = make(map[string]apihelper.Build, len(builds))
+		for _, b := range builds {
+			cmd.queryCache.builds[b.AppGUID] = b
+		}
 	}
 	return nil
 }
@@ -138,12 +394,38 @@ func (cmd *UsageReportCmd) GetMetadata() plugin.PluginMetadata {
 				Name:     "usage-report-si",
 				HelpText: "Report AI and memory usage for orgs and spaces",
 				UsageDetails: plugin.Usage{
-					Usage: "cf usage-report-si [-o orgName] [-s spaceName] [-i <app|summary>] [-f <csv>]",
+					Usage: "cf usage-report-si [-o orgName,...] [-s [orgName/]spaceName,...] [-exclude-org orgName,...] [-exclude-space [orgName/]spaceName,...] [-selector labelSelector] [-labels key,...] [-i <app|summary> [-sidecars] | -quotas | -stats | -builds] [-f <csv>] [-api <auto|v2|v3>] [-transport <http|curl>] [-p parallelism] [-retries n] [-retry-time duration] [-rate requests/s] [-bulk] [-partial] [-cache-dir dir] [-cache-ttl duration] [-refresh] [-record dir | -replay dir] [-timeout duration] [-request-timeout duration] [-trace] [-targets file] [-current]",
 					Options: map[string]string{
-						"o": "Filter for Specific Orgranization",
-						"s": "Filter for Specific Space",
-						"i": "Count Service Instances",
-						"f": "Define Output Format (csv)",
+						"o":               "Filter for Specific Orgranizations by Name, GUID, Glob or ~Regex",
+						"s":               "Filter for Specific Spaces by Name, GUID, Glob or ~Regex, optionally as org/space",
+						"exclude-org":     "Leave out these Orgranizations",
+						"exclude-space":   "Leave out these Spaces",
+						"selector":        "Report only Orgs, Spaces and Apps matching this Label Selector, e.g. team=payments,env in (prod,staging)",
+						"labels":          "Add the Values of these Labels as Columns to the CSV Output",
+						"i":               "Count Service Instances",
+						"sidecars":        "List the Sidecars of the Processes in the -i app Report, one Request per App",
+						"quotas":          "Report the Usage of every Dimension of the Org Quotas instead of the Memory of the Spaces",
+						"stats":           "Report the Memory, CPU and Disk actually used by the running App Instances next to their Allocation",
+						"builds":          "Report the Apps and their Memory per Stack, Buildpack and Docker Image",
+						"f":               "Define Output Format (csv)",
+						"api":             "Cloud Controller API Version (auto detects v2 or v3 by default)",
+						"transport":       "Talk HTTP to the Cloud Controller directly (default) or use cf curl",
+						"p":               "Number of Parallel API Lookups (default 8)",
+						"retries":         "Number of Retries of Failed API Requests (default 5)",
+						"retry-time":      "Maximum Time Spent Retrying a Request (default 1m)",
+						"rate":            "Maximum Number of API Requests per Second (default unlimited)",
+						"bulk":            "Load Apps and Quotas of the whole Foundation at once instead of per Org and Space",
+						"partial":         "Skip Orgs and Spaces which can not be read and list them as Warnings",
+						"cache-dir":       "Cache API Responses in this Directory",
+						"cache-ttl":       "Time Cached API Responses are used for (default 10m)",
+						"refresh":         "Reload all Data from the API and update the Cache",
+						"record":          "Store all API Responses in this Directory",
+						"replay":          "Create the Report from Responses recorded with -record",
+						"timeout":         "Stop after this Time and print the Report gathered so far (default none)",
+						"request-timeout": "Maximum Time a single API Request may take (default 1m)",
+						"trace":           "Log every API Request and sum them up per Endpoint on stderr",
+						"targets":         "Combine the Reports of the Foundations listed in this JSON File",
+						"current":         "Report only the targeted Org, or Space if one is targeted",
 					},
 				},
 			},
@@ -151,144 +433,417 @@ func (cmd *UsageReportCmd) GetMetadata() plugin.PluginMetadata {
 	}
 }
 
-func (cmd *UsageReportCmd) getFilteredOrgs(orgName, spaceName string) []models.Org {
-	var orgs []models.Org
-
-	if orgName != "" {
-		org, err := cmd.getOrg(orgName, spaceName)
-		if nil != err {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-		orgs = append(orgs, org)
-	} else {
-		var err error
-		orgs, err = cmd.getOrgs(spaceName)
-		if nil != err {
-			fmt.Println(err)
-			os.Exit(1)
-		}
+// getFilteredOrgs returns the orgs matching the selection. Orgs selected
+// by name only are looked up one by one. When ctx is done before all orgs
+// were gathered, the complete ones are returned along with the error.
+func (cmd *UsageReportCmd) getFilteredOrgs(ctx context.Context, sel selection) ([]models.Org, error) {
+	if names, ok := sel.orgNames(); ok {
+		return cmd.getOrg(ctx, names, sel)
 	}
-	return orgs
+	return cmd.getOrgs(ctx, sel)
 }
 
 // UsageReportCommand doer
-func (cmd *UsageReportCmd) UsageReportCommand(args []string) {
-	flagVals := ParseFlags(args)
+func (cmd *UsageReportCmd) UsageReportCommand(ctx context.Context, flagVals flagVal) {
+	report, err := cmd.gatherReport(ctx, flagVals)
+	if nil != err && nil == ctx.Err() {
+		cmd.exit(ctx, flagVals.Format, err)
+	}
+	cmd.printReport(ctx, flagVals, report, err)
+}
 
+// gatherReport queries the data of the report selected by the flags. When
+// ctx is done before, the data gathered so far is returned with the error.
+func (cmd *UsageReportCmd) gatherReport(ctx context.Context, flagVals flagVal) (models.Report, error) {
 	var report models.Report
 
 	// make global queries to the API
-	if err := cmd.createQueryCache(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	if err := cmd.createQueryCache(ctx); err != nil {
+		return report, err
 	}
 
 	var err error
 	if report.ServiceInstances, err = CreateServiceInstanceOverview(cmd.queryCache); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return report, err
 	}
+	report.ServiceInstances = flagVals.Selection.filterServices(report.ServiceInstances, cmd.queryCache)
+	report.ServiceInstances = flagVals.Selector.labelServices(report.ServiceInstances, cmd.queryCache)
 
-	// process service instances
-	if flagVals.ShowServiceInstances == "app" {
-		report.Orgs = cmd.getFilteredOrgs(flagVals.OrgName, flagVals.SpaceName)
+	// the service instance summary needs no orgs
+	if flagVals.ShowServiceInstances != "summary" {
+		report.Orgs, err = cmd.getFilteredOrgs(ctx, flagVals.Selection)
+		inheritLabels(report.Orgs)
+		report.Orgs = flagVals.Selector.filterOrgs(report.Orgs)
+	}
+	if flagVals.Stats && nil == err {
+		err = cmd.addStats(ctx, report.Orgs)
+	}
+	if flagVals.Sidecars && nil == err {
+		err = cmd.addSidecars(ctx, report.Orgs)
+	}
+	if flagVals.Builds && nil == err {
+		err = cmd.addCurrentBuilds(ctx, report.Orgs)
+	}
+	report.Warnings = cmd.warnings
+	return report, err
+}
+
+// printReport prints the report in the selected mode and format and exits
+// if it is incomplete because of err, or lacks orgs or spaces.
+func (cmd *UsageReportCmd) printReport(ctx context.Context, flagVals flagVal, report models.Report, err error) {
+	report.LabelColumns = flagVals.LabelColumns
+	if flagVals.Quotas {
+		if flagVals.Format == "csv" {
+			fmt.Fprintln(cmd.stdout(), report.QuotaCSV())
+		} else {
+			fmt.Fprintln(cmd.stdout(), report.QuotaString())
+		}
+	} else if flagVals.Stats {
+		if flagVals.Format == "csv" {
+			fmt.Fprintln(cmd.stdout(), report.StatsCSV())
+		} else {
+			fmt.Fprintln(cmd.stdout(), report.StatsString())
+		}
+	} else if flagVals.Builds {
 		if flagVals.Format == "csv" {
-			fmt.Println(report.ServiceInstanceReportCSV())
+			fmt.Fprintln(cmd.stdout(), report.BuildsCSV())
 		} else {
-			fmt.Println(report.ServiceInstanceReportString())
+			fmt.Fprintln(cmd.stdout(), report.BuildsString())
+		}
+	} else if flagVals.ShowServiceInstances == "app" {
+		if flagVals.Format == "csv" {
+			fmt.Fprintln(cmd.stdout(), report.ServiceInstanceReportCSV())
+		} else {
+			fmt.Fprintln(cmd.stdout(), report.ServiceInstanceReportString())
 		}
 	} else if flagVals.ShowServiceInstances == "summary" {
 		if flagVals.Format == "csv" {
-			fmt.Println(report.ServiceInstanceSummaryCSV())
+			fmt.Fprintln(cmd.stdout(), report.ServiceInstanceSummaryCSV())
 		} else {
-			fmt.Println(report.ServiceInstanceSummaryString())
+			fmt.Fprintln(cmd.stdout(), report.ServiceInstanceSummaryString())
 		}
 	} else {
 		// standard memory report
 		if flagVals.Format == "csv" {
-			fmt.Println(report.CSV())
+			fmt.Fprintln(cmd.stdout(), report.CSV())
 		} else {
-			fmt.Println(report.String())
+			fmt.Fprintln(cmd.stdout(), report.String())
 		}
 	}
+	cmd.printWarnings(&report, flagVals.Format)
+	cmd.printCacheAge(flagVals.Format)
+	if nil != err {
+		cmd.exit(ctx, flagVals.Format, err)
+	}
+	cmd.printTraceSummary()
+	if len(report.Warnings) > 0 {
+		os.Exit(exitPartial)
+	}
+}
+
+// printWarnings prints the orgs and spaces skipped in partial mode after
+// the report. For CSV output they follow as a table of their own, so that a
+// saved report tells what is missing.
+func (cmd *UsageReportCmd) printWarnings(report *models.Report, format string) {
+	if len(report.Warnings) == 0 {
+		return
+	}
+	if format == "csv" {
+		fmt.Fprintln(cmd.stdout(), report.WarningsCSV())
+	} else {
+		fmt.Fprintln(cmd.stdout(), report.WarningsString())
+	}
+}
+
+// exit prints err and exits. If the report was stopped by an interrupt or
+// the -timeout, it is marked as incomplete instead.
+func (cmd *UsageReportCmd) exit(ctx context.Context, format string, err error) {
+	switch ctx.Err() {
+	case context.Canceled:
+		cmd.printNotice(format, "Report is incomplete, it was interrupted before all data was gathered.")
+	case context.DeadlineExceeded:
+		cmd.printNotice(format, "Report is incomplete, the -timeout was hit before all data was gathered.")
+	default:
+		fmt.Fprintln(cmd.stderr(), err)
+	}
+	cmd.printTraceSummary()
+	os.Exit(1)
 }
 
-func (cmd *UsageReportCmd) getOrgs(spaceName string) ([]models.Org, error) {
+func (cmd *UsageReportCmd) getOrgs(ctx context.Context, sel selection) ([]models.Org, error) {
 
-	rawOrgs, err := cmd.apiHelper.GetOrgs()
+	rawOrgs, err := cmd.apiHelper.GetOrgs(ctx)
 	if nil != err {
 		return nil, err
 	}
 
-	var orgs = []models.Org{}
+	return cmd.getOrgsDetails(ctx, sel.filterOrgs(rawOrgs), sel)
+}
 
-	for _, o := range rawOrgs {
-		orgDetails, err := cmd.getOrgDetails(o, spaceName)
-		if err != nil {
+// getOrg returns the orgs with the given names which are not excluded,
+// without those skipped in partial mode.
+func (cmd *UsageReportCmd) getOrg(ctx context.Context, orgNames []string, sel selection) ([]models.Org, error) {
+	rawOrgs := make([]apihelper.Organization, 0, len(orgNames))
+	for _, name := range orgNames {
+		rawOrg, err := cmd.apiHelper.GetOrg(ctx, name)
+		var skipped error
+		if err = cmd.skip(ctx, err, &skipped); nil != err {
 			return nil, err
 		}
-		orgs = append(orgs, orgDetails)
+		if nil != skipped {
+			cmd.warn(name, "", skipped)
+			continue
+		}
+		rawOrgs = append(rawOrgs, rawOrg)
 	}
-	return orgs, nil
+
+	return cmd.getOrgsDetails(ctx, sel.filterOrgs(rawOrgs), sel)
 }
 
-func (cmd *UsageReportCmd) getOrg(orgName, spaceName string) (models.Org, error) {
-	rawOrg, err := cmd.apiHelper.GetOrg(orgName)
+// getOrgsDetails queries usage, quota, spaces and apps of the given orgs
+// with up to cmd.parallelism concurrent lookups. Orgs and spaces keep the
+// order in which the API returned them. When ctx is done before all lookups
+// finished, the orgs gathered completely are returned with the error.
+func (cmd *UsageReportCmd) getOrgsDetails(ctx context.Context, rawOrgs []apihelper.Organization, sel selection) ([]models.Org, error) {
+	if cmd.bulk {
+		return cmd.joinOrgsDetails(rawOrgs, sel)
+	}
+
+	orgs := make([]models.Org, len(rawOrgs))
+	rawSpaces := make([][]apihelper.Space, len(rawOrgs))
+	orgErrs := make([]error, len(rawOrgs))
+	spaceErrs := make([][]error, len(rawOrgs))
+	// number of lookups still missing per org, the details count as one
+	missing := make([]int32, len(rawOrgs))
+	for i := range missing {
+		missing[i] = 1
+	}
+
+	err := runParallel(ctx, len(rawOrgs), cmd.parallelism, func(ctx context.Context, i int) error {
+		var err error
+		orgs[i], rawSpaces[i], err = cmd.getOrgDetails(ctx, rawOrgs[i], sel)
+		if nil == err {
+			missing[i] = int32(len(rawSpaces[i]))
+		}
+		return cmd.skip(ctx, err, &orgErrs[i])
+	})
+
+	if nil == err {
+		// the apps of all spaces of all orgs are fetched by one pool so that
+		// the parallelism is not multiplied per org
+		type spaceRef struct {
+			org, space int
+		}
+		var refs []spaceRef
+		for i := range orgs {
+			orgs[i].Spaces = make([]models.Space, len(rawSpaces[i]))
+			spaceErrs[i] = make([]error, len(rawSpaces[i]))
+			for j, s := range rawSpaces[i] {
+				orgs[i].Spaces[j].Name = s.Name
+				orgs[i].Spaces[j].Labels = cmd.queryCache.labels.Spaces[s.GUID]
+				cmd.setSpaceQuota(&orgs[i].Spaces[j], s.QuotaGUID)
+				refs = append(refs, spaceRef{org: i, space: j})
+			}
+		}
+
+		err = runParallel(ctx, len(refs), cmd.parallelism, func(ctx context.Context, k int) error {
+			ref := refs[k]
+			apps, err := cmd.getApps(ctx, rawSpaces[ref.org][ref.space].AppsURL)
+			if err = cmd.skip(ctx, err, &spaceErrs[ref.org][ref.space]); nil != err {
+				return err
+			}
+			orgs[ref.org].Spaces[ref.space].Apps = apps
+			atomic.AddInt32(&missing[ref.org], -1)
+			return nil
+		})
+	}
+
 	if nil != err {
-		return models.Org{}, err
+		if nil == ctx.Err() {
+			return nil, err
+		}
+		err = ctx.Err()
 	}
+	return cmd.completeOrgs(rawOrgs, orgs, missing, orgErrs, spaceErrs), err
+}
 
-	return cmd.getOrgDetails(rawOrg, spaceName)
+// skip returns err unless it is to be skipped in partial mode. Then it is
+// stored in skipped instead. Errors of a done ctx are never skipped.
+func (cmd *UsageReportCmd) skip(ctx context.Context, err error, skipped *error) error {
+	if nil == err || !cmd.partial || nil != ctx.Err() {
+		return err
+	}
+	*skipped = err
+	return nil
 }
 
-func (cmd *UsageReportCmd) getOrgDetails(o apihelper.Organization, spaceName string) (models.Org, error) {
-	usage, err := cmd.apiHelper.GetOrgMemoryUsage(o)
-	if nil != err {
-		return models.Org{}, err
+// completeOrgs returns the orgs without missing lookups. Orgs and spaces
+// skipped because of an error are left out and added to the warnings.
+func (cmd *UsageReportCmd) completeOrgs(rawOrgs []apihelper.Organization, orgs []models.Org, missing []int32, orgErrs []error, spaceErrs [][]error) []models.Org {
+	var complete []models.Org
+	for i, org := range orgs {
+		if nil != orgErrs[i] {
+			cmd.warn(rawOrgs[i].Name, "", orgErrs[i])
+			continue
+		}
+		if missing[i] != 0 {
+			continue
+		}
+		spaces := make([]models.Space, 0, len(org.Spaces))
+		for j, space := range org.Spaces {
+			if nil != spaceErrs[i][j] {
+				cmd.warn(org.Name, space.Name, spaceErrs[i][j])
+				continue
+			}
+			spaces = append(spaces, space)
+		}
+		org.Spaces = spaces
+		complete = append(complete, org)
+	}
+	return complete
+}
+
+// warn records that an org or space is missing in the report.
+func (cmd *UsageReportCmd) warn(orgName, spaceName string, err error) {
+	cmd.warnings = append(cmd.warnings, models.Warning{
+		OrgName:   orgName,
+		SpaceName: spaceName,
+		Message:   err.Error(),
+	})
+}
+
+// joinOrgsDetails builds the orgs out of the spaces, apps and quotas of the
+// query cache without further API requests. The memory usage of an org is
+// the memory of all instances of its started apps. In partial mode orgs
+// with an unknown quota are skipped, orgs without a quota are reported as
+// such.
+func (cmd *UsageReportCmd) joinOrgsDetails(rawOrgs []apihelper.Organization, sel selection) ([]models.Org, error) {
+	spacesByOrg := make(map[string][]apihelper.SpaceDetails)
+	for _, s := range cmd.queryCache.spaceList {
+		spacesByOrg[s.OrgGUID] = append(spacesByOrg[s.OrgGUID], s)
+	}
+
+	orgs := make([]models.Org, 0, len(rawOrgs))
+	for _, o := range rawOrgs {
+		quota, err := cmd.orgQuota(o)
+		if nil != err && apihelper.ErrNoQuota != err {
+			if !cmd.partial {
+				return nil, err
+			}
+			cmd.warn(o.Name, "", err)
+			continue
+		}
+
+		org := models.Org{
+			Name:        o.Name,
+			MemoryQuota: int(quota.MemoryLimit),
+			Spaces:      []models.Space{},
+			Labels:      cmd.queryCache.labels.Orgs[o.GUID],
+			NoQuota:     apihelper.ErrNoQuota == err,
+		}
+		if cmd.quotas {
+			cmd.setQuotaUsages(&org, o, quota)
+		}
+		for _, s := range spacesByOrg[o.GUID] {
+			rawApps := cmd.queryCache.appMap[s.GUID]
+			for _, a := range rawApps {
+				if a.Running {
+					for _, p := range a.AllProcesses() {
+						org.MemoryUsage += int(p.Instances * p.RAM)
+					}
+				}
+				for _, t := range cmd.queryCache.tasks[a.GUID] {
+					org.MemoryUsage += int(t.RAM)
+				}
+			}
+			if !sel.space(o, s.Name, s.GUID) {
+				continue
+			}
+			space := models.Space{
+				Name:   s.Name,
+				Apps:   cmd.toModelApps(rawApps),
+				Labels: cmd.queryCache.labels.Spaces[s.GUID],
+			}
+			cmd.setSpaceQuota(&space, s.QuotaGUID)
+			org.Spaces = append(org.Spaces, space)
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, nil
+}
+
+// setSpaceQuota sets the space quota with quotaGUID from the query cache.
+// Space quotas the user can not see are left out, the space is then
+// compared to the quota of its org.
+func (cmd *UsageReportCmd) setSpaceQuota(space *models.Space, quotaGUID string) {
+	if quota, exists := cmd.queryCache.spaceQuotaMap[quotaGUID]; exists {
+		space.MemoryQuota = int(quota.MemoryLimit)
+		space.HasQuota = true
+	}
+}
+
+// orgQuota returns the quota of o from the query cache, ErrNoQuota if it
+// has none.
+func (cmd *UsageReportCmd) orgQuota(o apihelper.Organization) (apihelper.Quota, error) {
+	if o.QuotaGUID == "" {
+		return apihelper.Quota{}, apihelper.ErrNoQuota
+	}
+	quota, exists := cmd.queryCache.quotaMap[o.QuotaGUID]
+	if !exists {
+		return apihelper.Quota{}, fmt.Errorf("quota definition %s of organization %s not found", o.QuotaGUID, o.Name)
 	}
-	quota, err := cmd.apiHelper.GetQuotaMemoryLimit(o.QuotaURL)
+	return quota, nil
+}
+
+// getOrgDetails returns the org with its usage and quota, and the spaces
+// of the selection. The apps of the spaces are not queried.
+func (cmd *UsageReportCmd) getOrgDetails(ctx context.Context, o apihelper.Organization, sel selection) (models.Org, []apihelper.Space, error) {
+	usage, err := cmd.apiHelper.GetOrgMemoryUsage(ctx, o)
 	if nil != err {
-		return models.Org{}, err
+		return models.Org{}, nil, err
+	}
+	quota, quotaErr := cmd.apiHelper.GetQuotaMemoryLimit(ctx, o.QuotaURL)
+	if nil != quotaErr && apihelper.ErrNoQuota != quotaErr {
+		return models.Org{}, nil, quotaErr
 	}
-	spaces, err := cmd.getSpaces(o.SpacesURL, spaceName)
+	spaces, err := cmd.getSpaces(ctx, o, sel)
 	if nil != err {
-		return models.Org{}, err
+		return models.Org{}, nil, err
 	}
 
-	return models.Org{
+	org := models.Org{
 		Name:        o.Name,
 		MemoryQuota: int(quota),
 		MemoryUsage: int(usage),
-		Spaces:      spaces,
-	}, nil
+		Labels:      cmd.queryCache.labels.Orgs[o.GUID],
+		NoQuota:     apihelper.ErrNoQuota == quotaErr,
+	}
+	if cmd.quotas {
+		limits, err := cmd.orgQuota(o)
+		if nil != err && apihelper.ErrNoQuota != err {
+			return models.Org{}, nil, err
+		}
+		cmd.setQuotaUsages(&org, o, limits)
+	}
+	return org, spaces, nil
 }
 
-func (cmd *UsageReportCmd) getSpaces(spaceURL, filteredSpaceName string) ([]models.Space, error) {
-	rawSpaces, err := cmd.apiHelper.GetOrgSpaces(spaceURL)
+func (cmd *UsageReportCmd) getSpaces(ctx context.Context, o apihelper.Organization, sel selection) ([]apihelper.Space, error) {
+	rawSpaces, err := cmd.apiHelper.GetOrgSpaces(ctx, o.SpacesURL)
 	if nil != err {
 		return nil, err
 	}
 
-	var spaces = []models.Space{}
+	var spaces = []apihelper.Space{}
 	for _, s := range rawSpaces {
 		// filter spaces
-		if filteredSpaceName != "" {
-			if s.Name != filteredSpaceName {
-				continue
-			}
+		if !sel.space(o, s.Name, s.GUID) {
+			continue
 		}
-
-		apps, err := cmd.getApps(s.AppsURL)
-		if nil != err {
-			return nil, err
+		if cmd.scope.SpaceGUID != "" && s.GUID != cmd.scope.SpaceGUID {
+			continue
 		}
-		spaces = append(spaces,
-			models.Space{
-				Apps: apps,
-				Name: s.Name,
-			},
-		)
+		spaces = append(spaces, s)
 	}
 	return spaces, nil
 }
@@ -306,12 +861,16 @@ func IsPCFInstance(serviceInstanceGUID string, siMap map[string]apihelper.Servic
 	}
 }
 
-func (cmd *UsageReportCmd) getApps(appsURL string) ([]models.App, error) {
-	rawApps, err := cmd.apiHelper.GetSpaceApps(appsURL)
+func (cmd *UsageReportCmd) getApps(ctx context.Context, appsURL string) ([]models.App, error) {
+	rawApps, err := cmd.apiHelper.GetSpaceApps(ctx, appsURL)
 	if nil != err {
 		return nil, err
 	}
+	return cmd.toModelApps(rawApps), nil
+}
 
+// toModelApps counts the service instances bound to the apps.
+func (cmd *UsageReportCmd) toModelApps(rawApps []apihelper.App) []models.App {
 	var apps = []models.App{}
 	for _, a := range rawApps {
 
@@ -336,24 +895,269 @@ func (cmd *UsageReportCmd) getApps(appsURL string) ([]models.App, error) {
 		apps = append(apps, models.App{
 			Instances: int(a.Instances),
 			Ram:       int(a.RAM),
+			Disk:      int(a.Disk),
 			Running:   a.Running,
 			Name:      a.Name,
 			SiTotal:   siTotal,
 			SiPCF:     siPCF,
 			SiUP:      siUP,
+			Labels:    cmd.queryCache.labels.Apps[a.GUID],
+			GUID:      a.GUID,
+			Processes: toModelProcesses(a.AllProcesses()),
+			Tasks:     toModelTasks(cmd.queryCache.tasks[a.GUID]),
+			Build:     toModelBuild(cmd.queryCache.builds[a.GUID]),
 		})
 	}
-	return apps, nil
+	return apps
 }
 
-//Run runs the plugin
+// Run runs the plugin
 func (cmd *UsageReportCmd) Run(cli plugin.CliConnection, args []string) {
 	if args[0] == "usage-report-si" {
-		cmd.apiHelper = apihelper.New(cli)
-		cmd.UsageReportCommand(args)
+		cmd.run(cli, ParseFlags(args))
+	}
+}
+
+// run creates the report of the foundation of conn, or of the foundations
+// of the -targets file.
+func (cmd *UsageReportCmd) run(conn apihelper.Connection, flagVals flagVal) {
+	ctx, cancel := interruptContext(flagVals.Timeout)
+	defer cancel()
+
+	cmd.parallelism = flagVals.Parallelism
+	cmd.bulk = flagVals.Bulk
+	cmd.partial = flagVals.Partial
+	cmd.withLabels = flagVals.Selector != nil || len(flagVals.LabelColumns) > 0
+	cmd.quotas = flagVals.Quotas
+	cmd.builds = flagVals.Builds
+	if flagVals.Targets != "" {
+		cmd.MultiFoundationReportCommand(ctx, flagVals)
+		return
+	}
+	if flagVals.Current {
+		if err := cmd.scopeToTarget(conn); err != nil {
+			cmd.exit(ctx, flagVals.Format, err)
+		}
+		// quotas apply to the whole org, also when a space is targeted
+		if cmd.quotas {
+			cmd.scope.SpaceGUID = ""
+		}
+	}
+
+	if err := cmd.connect(ctx, conn, flagVals); err != nil {
+		cmd.exit(ctx, flagVals.Format, err)
+	}
+	cmd.UsageReportCommand(ctx, flagVals)
+}
+
+// connect sets up the API helper for the foundation of conn.
+func (cmd *UsageReportCmd) connect(ctx context.Context, conn apihelper.Connection, flagVals flagVal) error {
+	transport, err := cmd.newTransport(conn, flagVals)
+	if err != nil {
+		return err
 	}
+
+	apiHelper, err := apihelper.NewScopedForAPIVersion(ctx, transport, flagVals.APIVersion, cmd.scope)
+	if err != nil {
+		return err
+	}
+	cmd.apiHelper = apiHelper
+	return nil
+}
+
+// scopeToTarget limits the report to the org and space the cf CLI targets.
+// The few lookups of a single org are cheaper than the foundation-wide
+// lists of bulk mode, which also would not cover the usage of the whole org
+// when only a space is targeted.
+func (cmd *UsageReportCmd) scopeToTarget(conn apihelper.Connection) error {
+	cli, ok := conn.(plugin.CliConnection)
+	if !ok {
+		return fmt.Errorf("-current only works when run by the cf CLI")
+	}
+	org, err := cli.GetCurrentOrg()
+	if err != nil {
+		return err
+	}
+	if org.Guid == "" {
+		return fmt.Errorf("-current needs a targeted org, use cf target -o")
+	}
+	space, err := cli.GetCurrentSpace()
+	if err != nil {
+		return err
+	}
+	cmd.scope = apihelper.Scope{OrgGUID: org.Guid, SpaceGUID: space.Guid}
+	cmd.bulk = false
+	return nil
+}
+
+// interruptContext returns a context which is cancelled on interrupt and,
+// unless timeout is 0, after timeout.
+func interruptContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(interrupt)
+	}()
+
+	if timeout > 0 {
+		timeoutCtx, cancelTimeout := context.WithTimeout(ctx, timeout)
+		return timeoutCtx, func() {
+			cancelTimeout()
+			cancel()
+		}
+	}
+	return ctx, cancel
+}
+
+// newTransport stacks the transports selected by the flags: either the
+// replayed recordings or the Cloud Controller connection with request
+// timeout, tracing, rate limit, retries, cache and recording. Only the
+// CLI connection can use cf curl.
+func (cmd *UsageReportCmd) newTransport(conn apihelper.Connection, flagVals flagVal) (apihelper.Transport, error) {
+	if flagVals.Replay != "" {
+		replay, err := apihelper.NewReplayTransport(flagVals.Replay)
+		if err != nil {
+			return nil, err
+		}
+		return cmd.traced(replay, flagVals), nil
+	}
+
+	var transport apihelper.Transport
+	var err error
+	if cli, ok := conn.(plugin.CliConnection); ok {
+		transport, err = apihelper.NewTransport(cli, flagVals.Transport)
+	} else if flagVals.Transport == apihelper.TransportCurl {
+		return nil, fmt.Errorf("-transport curl only works when run by the cf CLI")
+	} else {
+		transport, err = apihelper.NewHTTPTransport(conn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if flagVals.RequestTimeout > 0 {
+		transport = apihelper.NewTimeoutTransport(transport, flagVals.RequestTimeout)
+	}
+	transport = cmd.traced(transport, flagVals)
+	if flagVals.RateLimit > 0 {
+		transport = apihelper.NewRateLimitTransport(transport, flagVals.RateLimit)
+	}
+	transport = apihelper.NewRetryTransport(transport, flagVals.MaxRetries, flagVals.MaxRetryTime)
+	if flagVals.CacheDir != "" {
+		if transport, err = cmd.newCacheTransport(conn, transport, flagVals); err != nil {
+			return nil, err
+		}
+	}
+	if flagVals.Record != "" {
+		if transport, err = apihelper.NewRecordTransport(transport, flagVals.Record); err != nil {
+			return nil, err
+		}
+	}
+	return transport, nil
+}
+
+// traced wraps transport with the request log of -trace, so that every
+// request which is actually issued is logged, but no cache hits.
+func (cmd *UsageReportCmd) traced(transport apihelper.Transport, flagVals flagVal) apihelper.Transport {
+	if !flagVals.Trace {
+		return transport
+	}
+	cmd.trace = apihelper.NewTraceTransport(transport, cmd.stderr())
+	return cmd.trace
+}
+
+// newCacheTransport wraps transport with the on-disk cache. Cached responses
+// are bound to the API endpoint and user the connection is logged in with.
+func (cmd *UsageReportCmd) newCacheTransport(conn apihelper.Connection, transport apihelper.Transport, flagVals flagVal) (apihelper.Transport, error) {
+	endpoint, err := conn.ApiEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	user, err := conn.UserGuid()
+	if err != nil {
+		return nil, err
+	}
+	cmd.cache, err = apihelper.NewCacheTransport(transport, flagVals.CacheDir, endpoint, user, flagVals.CacheTTL, flagVals.Refresh)
+	if err != nil {
+		return nil, err
+	}
+	return cmd.cache, nil
+}
+
+func (cmd *UsageReportCmd) stdout() io.Writer {
+	if cmd.out == nil {
+		return os.Stdout
+	}
+	return cmd.out
+}
+
+func (cmd *UsageReportCmd) stderr() io.Writer {
+	if cmd.errOut == nil {
+		return os.Stderr
+	}
+	return cmd.errOut
+}
+
+// printCacheAge tells how old the cached data used for the report is.
+func (cmd *UsageReportCmd) printCacheAge(format string) {
+	for _, foundation := range cmd.foundations {
+		foundation.printCacheAge(format)
+	}
+	if cmd.cache == nil {
+		return
+	}
+	since, ok := cmd.cache.CachedSince()
+	if !ok {
+		return
+	}
+	cmd.printNotice(format, fmt.Sprintf("Report%s uses cached data from %s (%s old), use -refresh to reload.",
+		cmd.ofFoundation(), since.Format(time.RFC3339), time.Since(since).Truncate(time.Second)))
+}
+
+// printTraceSummary prints the requests per endpoint to stderr if -trace
+// is set.
+func (cmd *UsageReportCmd) printTraceSummary() {
+	for _, foundation := range cmd.foundations {
+		foundation.printTraceSummary()
+	}
+	if cmd.trace == nil {
+		return
+	}
+	fmt.Fprintln(cmd.stderr())
+	if cmd.name != "" {
+		fmt.Fprintf(cmd.stderr(), "Requests to foundation %s:\n", cmd.name)
+	}
+	cmd.trace.WriteSummary(cmd.stderr())
+}
+
+// ofFoundation names the foundation in notices about a combined report.
+func (cmd *UsageReportCmd) ofFoundation() string {
+	if cmd.name == "" {
+		return ""
+	}
+	return " of foundation " + cmd.name
+}
+
+// printNotice prints a remark about the report. For CSV it goes to stderr
+// to keep the output parseable.
+func (cmd *UsageReportCmd) printNotice(format, notice string) {
+	out := cmd.stdout()
+	if format == "csv" {
+		out = cmd.stderr()
+	}
+	fmt.Fprintln(out, notice)
 }
 
 func main() {
+	if standalone(os.Args) {
+		new(UsageReportCmd).RunStandalone(os.Args)
+		return
+	}
 	plugin.Start(new(UsageReportCmd))
 }
@@ -1,15 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/dgruber/usagereport-plugin/apihelper"
 	"github.com/dgruber/usagereport-plugin/apihelper/fakes"
+	"github.com/dgruber/usagereport-plugin/models"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
+var ctx = context.Background()
+
 var _ = Describe("Usagereport", func() {
 	var fakeAPI *fakes.FakeCFAPIHelper
 	var cmd *UsageReportCmd
@@ -22,7 +30,7 @@ var _ = Describe("Usagereport", func() {
 	Describe("get single org errors", func() {
 		It("should return an error if cf curl /v2/organizations fails", func() {
 			fakeAPI.GetOrgReturns(apihelper.Organization{}, errors.New("Bad Things"))
-			_, err := cmd.getOrg("test", "")
+			_, err := cmd.getOrg(ctx, []string{"test"}, selection{})
 			Expect(err).ToNot(BeNil())
 		})
 	})
@@ -31,7 +39,7 @@ var _ = Describe("Usagereport", func() {
 
 		It("should return an error if cf curl /v2/organizations fails", func() {
 			fakeAPI.GetOrgsReturns(nil, errors.New("Bad Things"))
-			_, err := cmd.getOrgs("")
+			_, err := cmd.getOrgs(ctx, selection{})
 			Expect(err).ToNot(BeNil())
 		})
 
@@ -42,19 +50,19 @@ var _ = Describe("Usagereport", func() {
 
 			It("should return an error if cf curl /v2/organizations/{guid}/memory_usage fails", func() {
 				fakeAPI.GetOrgMemoryUsageReturns(0, errors.New("Bad Things"))
-				_, err := cmd.getOrgs("")
+				_, err := cmd.getOrgs(ctx, selection{})
 				Expect(err).ToNot(BeNil())
 			})
 
 			It("sholud return an error if cf curl to the quota url fails", func() {
 				fakeAPI.GetQuotaMemoryLimitReturns(0, errors.New("Bad Things"))
-				_, err := cmd.getOrgs("")
+				_, err := cmd.getOrgs(ctx, selection{})
 				Expect(err).ToNot(BeNil())
 			})
 
 			It("should return an error if cf curl to get org spaces fails", func() {
 				fakeAPI.GetOrgSpacesReturns(nil, errors.New("Bad Things"))
-				_, err := cmd.getOrgs("")
+				_, err := cmd.getOrgs(ctx, selection{})
 				Expect(err).ToNot(BeNil())
 				Expect(fakeAPI.GetOrgSpacesCallCount()).To(Equal(1))
 			})
@@ -63,7 +71,7 @@ var _ = Describe("Usagereport", func() {
 				fakeAPI.GetOrgSpacesReturns(
 					[]apihelper.Space{apihelper.Space{AppsURL: "/v2/apps"}}, nil)
 				fakeAPI.GetSpaceAppsReturns(nil, errors.New("Bad Things"))
-				_, err := cmd.getOrgs("")
+				_, err := cmd.getOrgs(ctx, selection{})
 				Expect(err).ToNot(BeNil())
 				Expect(fakeAPI.GetSpaceAppsCallCount()).To(Equal(1))
 			})
@@ -84,7 +92,7 @@ var _ = Describe("Usagereport", func() {
 		It("should return two one org using 1 mb of 2 mb quota", func() {
 			fakeAPI.GetOrgMemoryUsageReturns(float64(1), nil)
 			fakeAPI.GetQuotaMemoryLimitReturns(float64(2), nil)
-			orgs, err := cmd.getOrgs("")
+			orgs, err := cmd.getOrgs(ctx, selection{})
 			Expect(err).To(BeNil())
 			Expect(len(orgs)).To(Equal(1))
 			org := orgs[0]
@@ -95,14 +103,14 @@ var _ = Describe("Usagereport", func() {
 		It("Should return an org with 1 space", func() {
 			fakeAPI.GetOrgSpacesReturns(
 				[]apihelper.Space{apihelper.Space{}, apihelper.Space{}}, nil)
-			orgs, _ := cmd.getOrgs("")
+			orgs, _ := cmd.getOrgs(ctx, selection{})
 			Expect(len(orgs[0].Spaces)).To(Equal(2))
 		})
 
 		It("Should not choke on an org with no spaces", func() {
 			fakeAPI.GetOrgSpacesReturns(
 				[]apihelper.Space{}, nil)
-			orgs, _ := cmd.getOrgs("")
+			orgs, _ := cmd.getOrgs(ctx, selection{})
 			Expect(len(orgs[0].Spaces)).To(Equal(0))
 		})
 
@@ -117,7 +125,7 @@ var _ = Describe("Usagereport", func() {
 					apihelper.App{},
 				},
 				nil)
-			orgs, _ := cmd.getOrgs("")
+			orgs, _ := cmd.getOrgs(ctx, selection{})
 			org := orgs[0]
 			space := org.Spaces[0]
 			apps := space.Apps
@@ -135,7 +143,7 @@ var _ = Describe("Usagereport", func() {
 				},
 				nil)
 
-			orgs, _ := cmd.getOrgs("")
+			orgs, _ := cmd.getOrgs(ctx, selection{})
 			org := orgs[0]
 			space := org.Spaces[0]
 			apps := space.Apps
@@ -144,6 +152,364 @@ var _ = Describe("Usagereport", func() {
 		})
 	})
 
+	Describe("parallel org and space lookups", func() {
+		BeforeEach(func() {
+			cmd.parallelism = 4
+			fakeAPI.GetOrgsReturns([]apihelper.Organization{
+				apihelper.Organization{Name: "org-1", SpacesURL: "/v2/organizations/1/spaces"},
+				apihelper.Organization{Name: "org-2", SpacesURL: "/v2/organizations/2/spaces"},
+				apihelper.Organization{Name: "org-3", SpacesURL: "/v2/organizations/3/spaces"},
+			}, nil)
+			fakeAPI.GetOrgSpacesStub = func(ctx context.Context, spacesURL string) ([]apihelper.Space, error) {
+				return []apihelper.Space{
+					apihelper.Space{Name: spacesURL + "/a", AppsURL: spacesURL + "/a/apps"},
+					apihelper.Space{Name: spacesURL + "/b", AppsURL: spacesURL + "/b/apps"},
+				}, nil
+			}
+			fakeAPI.GetSpaceAppsStub = func(ctx context.Context, appsURL string) ([]apihelper.App, error) {
+				return []apihelper.App{apihelper.App{Name: appsURL}}, nil
+			}
+		})
+
+		It("keeps the order of orgs, spaces and apps", func() {
+			orgs, err := cmd.getOrgs(ctx, selection{})
+			Expect(err).To(BeNil())
+			Expect(orgs).To(HaveLen(3))
+			for i, org := range orgs {
+				spacesURL := fmt.Sprintf("/v2/organizations/%d/spaces", i+1)
+				Expect(org.Name).To(Equal(fmt.Sprintf("org-%d", i+1)))
+				Expect(org.Spaces).To(HaveLen(2))
+				Expect(org.Spaces[0].Name).To(Equal(spacesURL + "/a"))
+				Expect(org.Spaces[1].Apps[0].Name).To(Equal(spacesURL + "/b/apps"))
+			}
+		})
+
+		It("returns the first error", func() {
+			fakeAPI.GetSpaceAppsStub = func(ctx context.Context, appsURL string) ([]apihelper.App, error) {
+				return nil, errors.New("Bad Things")
+			}
+			orgs, err := cmd.getOrgs(ctx, selection{})
+			Expect(err).ToNot(BeNil())
+			Expect(orgs).To(BeNil())
+		})
+
+		It("returns the complete orgs when cancelled", func() {
+			cmd.parallelism = 1
+			cancelCtx, cancel := context.WithCancel(ctx)
+			fakeAPI.GetSpaceAppsStub = func(ctx context.Context, appsURL string) ([]apihelper.App, error) {
+				if appsURL == "/v2/organizations/2/spaces/a/apps" {
+					cancel()
+				}
+				if nil != ctx.Err() {
+					return nil, ctx.Err()
+				}
+				return []apihelper.App{apihelper.App{Name: appsURL}}, nil
+			}
+			orgs, err := cmd.getOrgs(cancelCtx, selection{})
+			Expect(err).To(Equal(context.Canceled))
+			Expect(orgs).To(HaveLen(1))
+			Expect(orgs[0].Name).To(Equal("org-1"))
+			Expect(orgs[0].Spaces[1].Apps).To(HaveLen(1))
+		})
+	})
+
+	Describe("partial mode", func() {
+		BeforeEach(func() {
+			cmd.partial = true
+			fakeAPI.GetOrgsReturns([]apihelper.Organization{
+				apihelper.Organization{Name: "org-1", SpacesURL: "/v2/organizations/1/spaces"},
+				apihelper.Organization{Name: "org-2", SpacesURL: "/v2/organizations/2/spaces"},
+			}, nil)
+			fakeAPI.GetQuotaMemoryLimitReturns(float64(1024), nil)
+			fakeAPI.GetOrgSpacesStub = func(ctx context.Context, spacesURL string) ([]apihelper.Space, error) {
+				return []apihelper.Space{
+					apihelper.Space{Name: "dev", AppsURL: spacesURL + "/dev/apps"},
+					apihelper.Space{Name: "prod", AppsURL: spacesURL + "/prod/apps"},
+				}, nil
+			}
+		})
+
+		It("skips orgs which fail and warns about them", func() {
+			fakeAPI.GetOrgMemoryUsageStub = func(ctx context.Context, org apihelper.Organization) (float64, error) {
+				if org.Name == "org-1" {
+					return 0, errors.New("You are not authorized")
+				}
+				return 512, nil
+			}
+			orgs, err := cmd.getOrgs(ctx, selection{})
+			Expect(err).To(BeNil())
+			Expect(orgs).To(HaveLen(1))
+			Expect(orgs[0].Name).To(Equal("org-2"))
+			Expect(cmd.warnings).To(Equal([]models.Warning{
+				models.Warning{OrgName: "org-1", Message: "You are not authorized"},
+			}))
+		})
+
+		It("skips spaces which fail and warns about them", func() {
+			fakeAPI.GetSpaceAppsStub = func(ctx context.Context, appsURL string) ([]apihelper.App, error) {
+				if appsURL == "/v2/organizations/2/spaces/dev/apps" {
+					return nil, errors.New("Bad Things")
+				}
+				return []apihelper.App{apihelper.App{Name: appsURL}}, nil
+			}
+			orgs, err := cmd.getOrgs(ctx, selection{})
+			Expect(err).To(BeNil())
+			Expect(orgs).To(HaveLen(2))
+			Expect(orgs[0].Spaces).To(HaveLen(2))
+			Expect(orgs[1].Spaces).To(HaveLen(1))
+			Expect(orgs[1].Spaces[0].Name).To(Equal("prod"))
+			Expect(cmd.warnings).To(Equal([]models.Warning{
+				models.Warning{OrgName: "org-2", SpaceName: "dev", Message: "Bad Things"},
+			}))
+		})
+
+		It("skips orgs without a known quota in bulk mode", func() {
+			cmd.bulk = true
+			fakeAPI.GetOrgsReturns([]apihelper.Organization{
+				apihelper.Organization{GUID: "o1", Name: "org-1", QuotaGUID: "q1"},
+				apihelper.Organization{GUID: "o2", Name: "org-2", QuotaGUID: "deleted"},
+			}, nil)
+			fakeAPI.GetQuotaMapReturns(map[string]apihelper.Quota{
+				"q1": apihelper.Quota{GUID: "q1", MemoryLimit: 4096},
+			}, nil)
+			Expect(cmd.createQueryCache(ctx)).To(Succeed())
+			orgs, err := cmd.getOrgs(ctx, selection{})
+			Expect(err).To(BeNil())
+			Expect(orgs).To(HaveLen(1))
+			Expect(cmd.warnings).To(HaveLen(1))
+			Expect(cmd.warnings[0].OrgName).To(Equal("org-2"))
+		})
+
+		It("skips orgs selected by name which fail and warns about them", func() {
+			fakeAPI.GetOrgStub = func(ctx context.Context, name string) (apihelper.Organization, error) {
+				if name == "org-1" {
+					return apihelper.Organization{}, apihelper.ErrOrgNotFound
+				}
+				return apihelper.Organization{Name: name, SpacesURL: "/v2/organizations/2/spaces"}, nil
+			}
+			orgs, err := cmd.getOrg(ctx, []string{"org-1", "org-2"}, selection{})
+			Expect(err).To(BeNil())
+			Expect(orgs).To(HaveLen(1))
+			Expect(orgs[0].Name).To(Equal("org-2"))
+			Expect(cmd.warnings).To(Equal([]models.Warning{
+				models.Warning{OrgName: "org-1", Message: apihelper.ErrOrgNotFound.Error()},
+			}))
+		})
+
+		It("prints the warnings into the CSV output", func() {
+			var out, errOut bytes.Buffer
+			cmd.out, cmd.errOut = &out, &errOut
+			cmd.printWarnings(&models.Report{Warnings: []models.Warning{
+				models.Warning{OrgName: "org-1", Message: "You are not authorized"},
+			}}, "csv")
+			Expect(out.String()).To(Equal("OrgName,SpaceName,Warning\norg-1,,You are not authorized\n\n"))
+			Expect(errOut.String()).To(BeEmpty())
+		})
+
+		It("still fails for errors of a single org without partial mode", func() {
+			cmd.partial = false
+			fakeAPI.GetOrgReturns(apihelper.Organization{}, errors.New("Bad Things"))
+			_, err := cmd.getOrg(ctx, []string{"test"}, selection{})
+			Expect(err).ToNot(BeNil())
+		})
+	})
+
+	Describe("bulk inventory", func() {
+		BeforeEach(func() {
+			cmd.bulk = true
+			fakeAPI.GetOrgsReturns([]apihelper.Organization{
+				apihelper.Organization{GUID: "o1", Name: "org-1", QuotaGUID: "q1"},
+				apihelper.Organization{GUID: "o2", Name: "org-2", QuotaGUID: "q2"},
+			}, nil)
+			fakeAPI.GetSpacesReturns([]apihelper.SpaceDetails{
+				apihelper.SpaceDetails{GUID: "s1", Name: "dev", OrgGUID: "o1"},
+				apihelper.SpaceDetails{GUID: "s2", Name: "prod", OrgGUID: "o2"},
+				apihelper.SpaceDetails{GUID: "s3", Name: "dev", OrgGUID: "o2"},
+			}, nil)
+			fakeAPI.GetAppsReturns([]apihelper.App{
+				apihelper.App{Name: "app-1", SpaceGUID: "s1", Instances: 2, RAM: 512, Running: true},
+				apihelper.App{Name: "app-2", SpaceGUID: "s2", Instances: 1, RAM: 1024, Running: true},
+				apihelper.App{Name: "app-3", SpaceGUID: "s3", Instances: 4, RAM: 128, Running: true},
+				apihelper.App{Name: "app-4", SpaceGUID: "s3", Instances: 1, RAM: 2048, Running: false},
+			}, nil)
+			fakeAPI.GetQuotaMapReturns(map[string]apihelper.Quota{
+				"q1": apihelper.Quota{GUID: "q1", MemoryLimit: 4096},
+				"q2": apihelper.Quota{GUID: "q2", MemoryLimit: 10240},
+			}, nil)
+		})
+
+		It("joins orgs, spaces, apps and quotas without per org and space requests", func() {
+			Expect(cmd.createQueryCache(ctx)).To(Succeed())
+			orgs, err := cmd.getOrgs(ctx, selection{})
+			Expect(err).To(BeNil())
+			Expect(orgs).To(HaveLen(2))
+
+			Expect(orgs[0].MemoryQuota).To(Equal(4096))
+			Expect(orgs[0].MemoryUsage).To(Equal(1024))
+			Expect(orgs[1].MemoryQuota).To(Equal(10240))
+			Expect(orgs[1].MemoryUsage).To(Equal(1536))
+			Expect(orgs[1].Spaces).To(HaveLen(2))
+			Expect(orgs[1].Spaces[0].Name).To(Equal("prod"))
+			Expect(orgs[1].Spaces[1].Apps).To(HaveLen(2))
+
+			Expect(fakeAPI.GetOrgMemoryUsageCallCount()).To(Equal(0))
+			Expect(fakeAPI.GetQuotaMemoryLimitCallCount()).To(Equal(0))
+			Expect(fakeAPI.GetOrgSpacesCallCount()).To(Equal(0))
+			Expect(fakeAPI.GetSpaceAppsCallCount()).To(Equal(0))
+		})
+
+		It("filters spaces but counts the usage of the whole org", func() {
+			Expect(cmd.createQueryCache(ctx)).To(Succeed())
+			orgs, err := cmd.getOrgs(ctx, selection{spaces: []spacePattern{{space: pattern{text: "dev"}}}})
+			Expect(err).To(BeNil())
+			Expect(orgs[1].Spaces).To(HaveLen(1))
+			Expect(orgs[1].Spaces[0].Name).To(Equal("dev"))
+			Expect(orgs[1].MemoryUsage).To(Equal(1536))
+		})
+
+		It("sums the memory of all processes and adds their sidecars", func() {
+			fakeAPI.GetAppsReturns([]apihelper.App{
+				apihelper.App{Name: "app-1", GUID: "a1", SpaceGUID: "s1", Instances: 2, RAM: 512, Running: true, Processes: []apihelper.Process{
+					{Type: "web", Instances: 2, RAM: 512},
+					{Type: "worker", Instances: 1, RAM: 1024},
+				}},
+			}, nil)
+			fakeAPI.GetAppSidecarsReturns([]apihelper.Sidecar{{Name: "proxy", RAM: 64, ProcessTypes: []string{"worker"}}}, nil)
+			Expect(cmd.createQueryCache(ctx)).To(Succeed())
+			orgs, err := cmd.getOrgs(ctx, selection{})
+			Expect(err).To(BeNil())
+			Expect(orgs[0].MemoryUsage).To(Equal(2048))
+			Expect(orgs[0].Spaces[0].ConsumedMemory()).To(Equal(2048))
+
+			Expect(cmd.addSidecars(ctx, orgs)).To(Succeed())
+			processes := orgs[0].Spaces[0].Apps[0].Processes
+			Expect(processes[0].Sidecars).To(BeEmpty())
+			Expect(processes[1].Sidecars).To(Equal([]models.Sidecar{{Name: "proxy", Ram: 64}}))
+			_, appGUID := fakeAPI.GetAppSidecarsArgsForCall(0)
+			Expect(appGUID).To(Equal("a1"))
+		})
+
+		It("takes the builds of the apps from their current droplets", func() {
+			orgs := []models.Org{{Spaces: []models.Space{{Apps: []models.App{
+				{GUID: "a1", Build: models.Build{Stack: "cflinuxfs4", Buildpacks: []models.Buildpack{{Name: "ruby_buildpack"}}}},
+				{GUID: "a2", Build: models.Build{Stack: "cflinuxfs4"}},
+			}}}}}
+			fakeAPI.GetAppBuildStub = func(ctx context.Context, appGUID string) (apihelper.Build, error) {
+				if appGUID == "a2" {
+					return apihelper.Build{}, apihelper.ErrNoDroplet
+				}
+				return apihelper.Build{AppGUID: appGUID, Stack: "cflinuxfs3", Buildpacks: []apihelper.Buildpack{{Name: "ruby_buildpack", Version: "1.8.0"}}}, nil
+			}
+			Expect(cmd.addCurrentBuilds(ctx, orgs)).To(Succeed())
+			apps := orgs[0].Spaces[0].Apps
+			Expect(apps[0].Build).To(Equal(models.Build{Stack: "cflinuxfs3", Buildpacks: []models.Buildpack{{Name: "ruby_buildpack", Version: "1.8.0"}}}))
+			Expect(apps[1].Build).To(Equal(models.Build{Stack: "cflinuxfs4"}))
+			Expect(fakeAPI.GetAppBuildCallCount()).To(Equal(2))
+		})
+
+		It("reads the stats of every process of the started apps", func() {
+			orgs := []models.Org{{Spaces: []models.Space{{Apps: []models.App{
+				{GUID: "a1", Running: true, Processes: []models.Process{{Type: "web", Instances: 1, Ram: 256}, {Type: "worker", Instances: 1, Ram: 512}}},
+				{GUID: "a2"},
+			}}}}}
+			fakeAPI.GetAppStatsStub = func(ctx context.Context, appGUID, processType string) ([]apihelper.InstanceStats, error) {
+				return []apihelper.InstanceStats{{Memory: 64 * bytesPerMB}}, nil
+			}
+			Expect(cmd.addStats(ctx, orgs)).To(Succeed())
+			Expect(orgs[0].Spaces[0].Apps[0].Stats).To(Equal([]models.InstanceStats{
+				{ProcessType: "web", MemoryUsed: 64},
+				{ProcessType: "worker", MemoryUsed: 64},
+			}))
+			Expect(fakeAPI.GetAppStatsCallCount()).To(Equal(2))
+		})
+
+		It("prints the orgs in the standard memory report", func() {
+			var out bytes.Buffer
+			cmd.out = &out
+			report, err := cmd.gatherReport(ctx, flagVal{})
+			Expect(err).To(BeNil())
+			cmd.printReport(ctx, flagVal{Format: "csv"}, report, nil)
+			Expect(out.String()).To(ContainSubstring("\norg-1, dev, 1024, 4096, "))
+			Expect(out.String()).To(ContainSubstring("\norg-2, prod, 1024, 10240, "))
+		})
+
+		It("does not load apps and quotas otherwise", func() {
+			cmd.bulk = false
+			Expect(cmd.createQueryCache(ctx)).To(Succeed())
+			Expect(fakeAPI.GetAppsCallCount()).To(Equal(0))
+		})
+
+		It("fails for an unknown quota", func() {
+			fakeAPI.GetQuotaMapReturns(map[string]apihelper.Quota{}, nil)
+			Expect(cmd.createQueryCache(ctx)).To(Succeed())
+			_, err := cmd.getOrgs(ctx, selection{})
+			Expect(err).ToNot(BeNil())
+		})
+	})
+
+	Describe("runParallel", func() {
+		It("calls fn for every index", func() {
+			var calls int32
+			results := make([]int, 100)
+			err := runParallel(ctx, 100, 8, func(ctx context.Context, i int) error {
+				atomic.AddInt32(&calls, 1)
+				results[i] = i * i
+				return nil
+			})
+			Expect(err).To(BeNil())
+			Expect(atomic.LoadInt32(&calls)).To(Equal(int32(100)))
+			Expect(results[99]).To(Equal(99 * 99))
+		})
+
+		It("never runs more than workers calls at once", func() {
+			var running, max int32
+			runParallel(ctx, 50, 3, func(ctx context.Context, i int) error {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					m := atomic.LoadInt32(&max)
+					if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			})
+			Expect(atomic.LoadInt32(&max)).To(BeNumerically("<=", 3))
+		})
+
+		It("stops starting new calls after the first error", func() {
+			var calls int32
+			err := runParallel(ctx, 1000, 2, func(ctx context.Context, i int) error {
+				atomic.AddInt32(&calls, 1)
+				return errors.New("Bad Things")
+			})
+			Expect(err).ToNot(BeNil())
+			Expect(atomic.LoadInt32(&calls)).To(BeNumerically("<", 10))
+		})
+
+		It("cancels the running calls after the first error", func() {
+			started := make(chan struct{})
+			var cancelled int32
+			err := runParallel(ctx, 2, 2, func(ctx context.Context, i int) error {
+				if i == 0 {
+					<-started
+					return errors.New("Bad Things")
+				}
+				close(started)
+				select {
+				case <-ctx.Done():
+					atomic.StoreInt32(&cancelled, 1)
+					return ctx.Err()
+				case <-time.After(5 * time.Second):
+					return nil
+				}
+			})
+			Expect(err).To(MatchError("Bad Things"))
+			Expect(atomic.LoadInt32(&cancelled)).To(Equal(int32(1)))
+		})
+	})
+
 	Describe("PCF service type discovery", func() {
 		var siMap map[string]apihelper.ServiceInstance
 		var spMap map[string]apihelper.ServicePlan